@@ -0,0 +1,219 @@
+package yaml
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+)
+
+// anchorName reads the anchor of the node at the current position, if any.
+func (d *Decoder) anchorName(name string) (string, error) {
+	i := d.off
+	for i < len(d.data) && d.data[i] == ' ' {
+		i++
+	}
+	if i == len(d.data) || d.data[i] != '&' {
+		return "", nil
+	}
+	start := i + 1
+	for i < len(d.data) && !isBlank(d.data, i) {
+		i++
+	}
+	if i == start {
+		return "", d.error(name, "expect anchor name")
+	}
+	d.off = i
+	return string(d.data[start:i]), nil
+}
+
+// properties reads the tag and the anchor, in either order, in front
+// of the node at the current position.
+func (d *Decoder) properties(name string) (tag, anchor string, err error) {
+	if anchor, err = d.anchorName(name); err != nil {
+		return "", "", err
+	}
+	if tag, err = d.tag(name); err != nil {
+		return "", "", err
+	}
+	if anchor == "" {
+		anchor, err = d.anchorName(name)
+	}
+	return tag, anchor, err
+}
+
+// rootProperties reads the tag and the anchor in front of the root
+// node, on its line or alone on it, past the blank lines and the
+// comments before them.
+func (d *Decoder) rootProperties(name string) (tag, anchor string, err error) {
+	save := d.off
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			break
+		}
+		if content := bytes.TrimLeft(line, " "); len(bytes.TrimSpace(content)) != 0 {
+			if content[0] != '!' && content[0] != '&' {
+				break
+			}
+			d.off += len(line) - len(content)
+			return d.properties(name)
+		}
+		d.off = pos
+	}
+	d.off = save
+	return "", "", nil
+}
+
+// aliasName reads the alias at the current position, if any,
+// and returns the value of its anchor.
+func (d *Decoder) aliasName(name string) (anchor string, v reflect.Value, ok bool, err error) {
+	i := d.off
+	for i < len(d.data) && d.data[i] == ' ' {
+		i++
+	}
+	if i == len(d.data) || d.data[i] != '*' {
+		return "", reflect.Value{}, false, nil
+	}
+	start := i + 1
+	for i < len(d.data) && !isBlank(d.data, i) {
+		i++
+	}
+	anchor = string(d.data[start:i])
+	v, ok = d.anchors[anchor]
+	if !ok {
+		d.off = start - 1
+		return "", reflect.Value{}, false, d.error(name, "undefined anchor "+anchor)
+	}
+	d.off = i
+	line, pos := d.peekLine()
+	if len(bytes.TrimSpace(line)) != 0 {
+		return "", reflect.Value{}, false, d.error(name, "unexpected "+string(bytes.TrimSpace(line))+" after alias")
+	}
+	d.off = pos
+
+	if err := d.countAlias(name); err != nil {
+		return "", reflect.Value{}, false, err
+	}
+	return anchor, v, true, nil
+}
+
+// alias decodes the alias at the current position into val,
+// and reports whether there is one.
+func (d *Decoder) alias(name string, val reflect.Value) (bool, error) {
+	at := d.off
+	anchor, v, ok, err := d.aliasName(name)
+	if !ok || err != nil {
+		return false, err
+	}
+	if v.Kind() == reflect.Ptr && val.Kind() != reflect.Ptr {
+		v = v.Elem()
+	}
+	switch {
+	case v.Type().AssignableTo(val.Type()):
+		val.Set(v)
+	case v.Type().ConvertibleTo(val.Type()):
+		val.Set(v.Convert(val.Type()))
+	default:
+		return false, d.typeError(name, "can not decode alias *"+anchor+" into "+val.Type().String(), nil, val.Type(), at)
+	}
+	return true, nil
+}
+
+// setAnchor makes anchor refer to the value about to be decoded
+// into val. The anchor is set before the value is decoded, so that
+// the value may contain aliases to itself, through pointers.
+func (d *Decoder) setAnchor(anchor string, val reflect.Value) error {
+	if d.anchors == nil {
+		d.anchors = make(map[string]reflect.Value)
+	}
+	switch {
+	case val.Kind() == reflect.Ptr:
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		d.anchors[anchor] = reflect.ValueOf(val.Interface())
+	case val.CanAddr():
+		d.anchors[anchor] = val.Addr()
+	default:
+		return d.error(anchor, "can not anchor an unaddressable value")
+	}
+	return nil
+}
+
+// A ref identifies the value a pointer points to.
+type ref struct {
+	p uintptr
+	t reflect.Type
+}
+
+// countRefs counts the pointers to every value reachable from val.
+func (e *Encoder) countRefs(val reflect.Value) {
+	switch val.Kind() {
+	case reflect.Ptr:
+		if val.IsNil() {
+			return
+		}
+		r := ref{val.Pointer(), val.Type()}
+		e.refs[r]++
+		if e.refs[r] == 1 {
+			e.countRefs(val.Elem())
+		}
+	case reflect.Interface:
+		e.countRefs(val.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			e.countRefs(val.Index(i))
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			e.countRefs(iter.Value())
+		}
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			if val.Type().Field(i).PkgPath == "" {
+				e.countRefs(val.Field(i))
+			}
+		}
+	}
+}
+
+// pointer encodes the value pointed to by val, with an anchor when
+// there are other pointers to it, or as an alias when it is written.
+func (e *Encoder) pointer(p *plan, val reflect.Value, indent, state int) error {
+	if e.refs == nil {
+		return e.encode(p.elem, val.Elem(), indent, state)
+	}
+
+	r := ref{val.Pointer(), val.Type()}
+	if anchor, ok := e.anchors[r]; ok {
+		e.buf.WriteString("*" + anchor + "\n")
+		return nil
+	}
+	if e.refs[r] < 2 {
+		return e.encode(p.elem, val.Elem(), indent, state)
+	}
+
+	anchor := "a" + strconv.Itoa(len(e.anchors)+1)
+	e.anchors[r] = anchor
+	e.buf.WriteString("&" + anchor + " ")
+	if state != stateObjectValue && isCollectionValue(reflect.Indirect(val.Elem())) {
+		// The collection can not follow the anchor on its line.
+		e.blockStart()
+		e.indent(indent)
+	}
+	return e.encode(p.elem, val.Elem(), indent, state)
+}
+
+// isCollectionValue reports whether val is encoded as a collection.
+func isCollectionValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Slice:
+		return val.Type() != rawMessageType
+	case reflect.Map:
+		return true
+	case reflect.Struct:
+		return val.Type() != timeType
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Command yaml2go generates the Go types of the documents like some
+// example documents.
+//
+// Usage:
+//
+//	yaml2go [flags] [file ...]
+//
+// Without files, it reads the examples from the standard input. The
+// flags are:
+//
+//	-pkg name
+//	        write a file of the package name, rather than the types only
+//	-type name
+//	        name of the type of the documents (default "Config")
+//	-o file
+//	        write the result to file rather than to the standard output
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/J5ive/yaml/yaml2go"
+)
+
+var (
+	pkg      = flag.String("pkg", "", "write a file of the package `name`, rather than the types only")
+	typeName = flag.String("type", "Config", "`name` of the type of the documents")
+	output   = flag.String("o", "", "write the result to `file` rather than to the standard output")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: yaml2go [flags] [file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var docs [][]byte
+	if flag.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(err)
+		}
+		docs = append(docs, data)
+	}
+	for _, name := range flag.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fatal(err)
+		}
+		docs = append(docs, data)
+	}
+
+	src, err := yaml2go.Generate(yaml2go.Options{Package: *pkg, Name: *typeName}, docs...)
+	if err != nil {
+		fatal(err)
+	}
+	if *output != "" {
+		err = os.WriteFile(*output, src, 0o644)
+	} else {
+		_, err = os.Stdout.Write(src)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "yaml2go:", err)
+	os.Exit(1)
+}
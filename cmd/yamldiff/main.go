@@ -0,0 +1,83 @@
+// Command yamldiff prints the differences between two YAML documents,
+// ignoring the order of the keys and the formatting.
+//
+// Usage:
+//
+//	yamldiff [flags] old.yaml new.yaml
+//
+// Each difference is printed on a line, like:
+//
+//	~ port: 80 -> 8080
+//	+ servers[2]: {"host":"c"}
+//	- debug: true
+//
+// The exit status is 0 when the documents are equal, 1 when they
+// differ and 2 on error. The flags are:
+//
+//	-q      print nothing, only set the exit status
+//	-l      print the line of each old and new value
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/J5ive/yaml"
+)
+
+var (
+	quiet = flag.Bool("q", false, "print nothing, only set the exit status")
+	lines = flag.Bool("l", false, "print the line of each old and new value")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: yamldiff [flags] old.yaml new.yaml")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	a, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	b, err := os.ReadFile(flag.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+	changes, err := yaml.Diff(a, b)
+	if err != nil {
+		fatal(err)
+	}
+
+	if !*quiet {
+		for _, c := range changes {
+			if *lines {
+				fmt.Printf("%s %s\t%s\n", position(flag.Arg(0), c.Old), position(flag.Arg(1), c.New), c.String())
+			} else {
+				fmt.Println(c.String())
+			}
+		}
+	}
+	if len(changes) != 0 {
+		os.Exit(1)
+	}
+}
+
+// position returns the position of n in the file name, or "-".
+func position(name string, n *yaml.Node) string {
+	if n == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s:%d", name, n.Line)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "yamldiff:", err)
+	os.Exit(2)
+}
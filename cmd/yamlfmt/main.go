@@ -0,0 +1,111 @@
+// Command yamlfmt formats YAML documents.
+//
+// Usage:
+//
+//	yamlfmt [flags] [file ...]
+//
+// Without files, it formats the standard input to the standard output.
+// The flags are:
+//
+//	-w      write the result to each file rather than to the standard output
+//	-l      list the files whose formatting differs
+//	-indent n
+//	        indent nested blocks by n spaces (default 2)
+//	-width n
+//	        wrap the strings longer than n columns (default 0, no wrapping)
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/J5ive/yaml"
+)
+
+var (
+	write  = flag.Bool("w", false, "write the result to each file rather than to the standard output")
+	list   = flag.Bool("l", false, "list the files whose formatting differs")
+	indent = flag.Int("indent", 2, "indent nested blocks by `n` spaces")
+	width  = flag.Int("width", 0, "wrap the strings longer than `n` columns")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: yamlfmt [flags] [file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	opts := yaml.FormatOptions{Indent: *indent, LineWidth: *width}
+	if flag.NArg() == 0 {
+		if *write {
+			fatal("yamlfmt: can not use -w with the standard input")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fatal(err)
+		}
+		out, err := yaml.Format(data, opts)
+		if err != nil {
+			fatal("<stdin>: ", err)
+		}
+		if *list {
+			if !bytes.Equal(data, out) {
+				fmt.Println("<stdin>")
+			}
+			return
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	failed := false
+	for _, name := range flag.Args() {
+		if err := formatFile(name, opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func formatFile(name string, opts yaml.FormatOptions) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	out, err := yaml.Format(data, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	changed := !bytes.Equal(data, out)
+	if *list && changed {
+		fmt.Println(name)
+	}
+	if *write {
+		if !changed {
+			return nil
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		// The formatted text is written verbatim, atomically.
+		return yaml.WriteFileMode(name, yaml.RawMessage(out), info.Mode().Perm())
+	}
+	if !*list {
+		os.Stdout.Write(out)
+	}
+	return nil
+}
+
+func fatal(v ...interface{}) {
+	fmt.Fprint(os.Stderr, v...)
+	fmt.Fprintln(os.Stderr)
+	os.Exit(2)
+}
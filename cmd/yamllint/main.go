@@ -0,0 +1,63 @@
+// Command yamllint reports the problems of YAML documents.
+//
+// Usage:
+//
+//	yamllint [flags] [file ...]
+//
+// Without files, it checks the standard input. Each problem is printed
+// as file:line:column: severity: message (rule). The exit status is 1
+// when an error is found, or a warning with -strict, and 2 when a file
+// can not be read. The flags are:
+//
+//	-strict
+//	        exit with status 1 on warnings too
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/J5ive/yaml"
+)
+
+var strict = flag.Bool("strict", false, "exit with status 1 on warnings too")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: yamllint [flags] [file ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	status := 0
+	check := func(name string, data []byte) {
+		for _, p := range yaml.Lint(data) {
+			fmt.Printf("%s:%s\n", name, p.String())
+			if p.Severity == yaml.SeverityError || *strict {
+				status = 1
+			}
+		}
+	}
+
+	if flag.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		check("<stdin>", data)
+		os.Exit(status)
+	}
+
+	for _, name := range flag.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		check(name, data)
+	}
+	os.Exit(status)
+}
@@ -0,0 +1,125 @@
+package yaml
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// A Crypto decrypts the encrypted values of the documents decoded, and
+// encrypts the values of the secret fields of the documents encoded,
+// for integrations such as SOPS, age or a KMS.
+//
+// An encrypted value is decoded into a field with the "secret" tag
+// option, like `yaml:"password,secret"`, of a string, number or boolean
+// type, which the encoder encrypts again: a document decoded and
+// encoded again keeps its values encrypted. An encrypted value decoded
+// elsewhere is an error, as it would be written back in plaintext.
+type Crypto struct {
+	// An encrypted value is written with the tag Tag, like
+	// "!encrypted", or between Prefix and Suffix, like "ENC[" and "]".
+	// Decoding accepts both forms when both are set, and encoding
+	// writes the prefixed one.
+	Tag            string
+	Prefix, Suffix string
+
+	Decrypt func(ciphertext string) (string, error)
+	Encrypt func(plaintext string) (string, error)
+}
+
+// SetCrypto makes the decoder decrypt with c the encrypted scalars,
+// before they are converted to the type of their field.
+func (d *Decoder) SetCrypto(c *Crypto) {
+	d.crypto = c
+}
+
+// SetCrypto makes the encoder encrypt with c the values of the string,
+// number and boolean fields with the "secret" tag option, like
+// `yaml:"password,secret"`, rather than write them as set by
+// SetSecretStyle.
+func (e *Encoder) SetCrypto(c *Crypto) {
+	e.crypto = c
+}
+
+// decrypt returns the scalar str with the tag tag, read at offset
+// start, decrypted if it is encrypted, with the tag left to decode it.
+// Only the value of a secret field may be encrypted.
+func (d *Decoder) decrypt(name, str, tag string, start int, secret bool) (string, string, error) {
+	c := d.crypto
+	if c == nil || c.Decrypt == nil {
+		return str, tag, nil
+	}
+	switch {
+	case c.Tag != "" && tag == c.Tag:
+		tag = ""
+	case c.Prefix != "" && len(str) >= len(c.Prefix)+len(c.Suffix) &&
+		strings.HasPrefix(str, c.Prefix) && strings.HasSuffix(str, c.Suffix):
+		str = str[len(c.Prefix) : len(str)-len(c.Suffix)]
+	default:
+		return str, tag, nil
+	}
+	if !secret {
+		d.off = d.skipSpaces(start)
+		return "", "", d.error(name, "encrypted value in a field without the secret option")
+	}
+	plain, err := c.Decrypt(str)
+	if err != nil {
+		d.off = d.skipSpaces(start)
+		return "", "", d.syntaxError(name, "can not decrypt value: "+err.Error(), err)
+	}
+	return plain, tag, nil
+}
+
+// scalarText reads the scalar at the current position, with the tag
+// tag, and returns its value, the tag left to decode it and its offset.
+// secret tells whether it is the value of a secret field.
+func (d *Decoder) scalarText(name, tag string, indent int, secret bool) (string, string, int, error) {
+	start := d.off
+	str, err := d.string(indent)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if str, err = d.expand(name, str, start); err != nil {
+		return "", "", 0, err
+	}
+	str, tag, err = d.decrypt(name, str, tag, start, secret)
+	return str, tag, start, err
+}
+
+// encrypted returns the encrypted value written for the secret
+// field name of value v, and false if v is not encrypted.
+func (e *Encoder) encrypted(name string, v reflect.Value) (reflect.Value, bool, error) {
+	c := e.crypto
+	if c == nil || c.Encrypt == nil {
+		return v, false, nil
+	}
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	var plain string
+	switch v.Kind() {
+	case reflect.String:
+		if v.Len() == 0 {
+			return v, false, nil
+		}
+		plain = v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		plain = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		plain = strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		plain = e.formatFloat(v.Float(), v.Type().Bits())
+	case reflect.Bool:
+		plain = strconv.FormatBool(v.Bool())
+	default:
+		return v, false, nil
+	}
+	text, err := c.Encrypt(plain)
+	if err != nil {
+		return v, false, e.error("can not encrypt field " + name + ": " + err.Error())
+	}
+	if c.Prefix == "" && c.Tag != "" {
+		return reflect.ValueOf(Node{Kind: ScalarNode, Tag: c.Tag, Value: text}), true, nil
+	}
+	return reflect.ValueOf(c.Prefix + text + c.Suffix), true, nil
+}
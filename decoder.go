@@ -5,15 +5,20 @@ It only implements a subset of YAML.
 
 Supported type:
 	Type :=
-		string | int | int64 | float64
+		string | bool | int | int8 | int16 | int32 | int64
+		| uint | uint8 | uint16 | uint32 | uint64 | float32 | float64
+		| time.Time | time.Duration
+		| interface{}
 		| []Type
-		| map[string]Type
+		| map[string]Type | MapSlice
 		| struct (with fields having Type)
+		| *Type
 
 Unsupported specification:
-	- Document marker ( --- );
+	- Several documents decoded by a call to Decode, which stops
+	  at the end of the first one;
 	- Inline format (json pattern);
-	- Quoted scalar;
+	- Quoted scalar spanning several lines;
 	- Comment in Multi-line scalar. For example:
 
 		OK: # this is comment
@@ -29,60 +34,409 @@ package yaml
 
 import (
 	"bytes"
-	"fmt"
-	"io/ioutil"
+	"encoding"
+	"io"
+	"io/fs"
+	"math"
+	"os"
 	"reflect"
-	"runtime"
 	"strconv"
 	"strings"
 )
 
 func Unmarshal(data []byte, v interface{}) error {
-	return NewDecoder(data).Decode(v)
+	d := getDecoder(data)
+	defer putDecoder(d)
+	return d.Decode(v)
 }
 
+// UnmarshalStrict is Unmarshal with a strict decoder, see SetStrict.
+func UnmarshalStrict(data []byte, v interface{}) error {
+	return NewDecoderBytes(data, WithStrict(true)).Decode(v)
+}
+
+// ReadFile decodes the first document of the file filename into v.
+// The file is read up to the end of the document.
 func ReadFile(filename string, v interface{}) error {
-	data, err := ioutil.ReadFile(filename)
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	err = NewDecoder(f).Decode(v)
+	if err == io.EOF {
+		return nil // no document, as in an empty file
+	}
+	return err
+}
+
+// Valid reports whether the documents of data are well-formed.
+func Valid(data []byte) bool {
+	d := NewDecoderBytes(data)
+	for {
+		if d.Skip() != nil {
+			return false
+		}
+		if !hasContent(d.Buffered()) {
+			return true
+		}
+	}
+}
+
+// ReadFileFS decodes the file name of fsys into v, as ReadFile does.
+func ReadFileFS(fsys fs.FS, name string, v interface{}) error {
+	data, err := fs.ReadFile(fsys, name)
 	if err != nil {
 		return err
 	}
-	return NewDecoder(data).Decode(v)
+	return NewDecoderBytes(data).Decode(v)
 }
 
 type Decoder struct {
-	data []byte
-	off  int
+	data     []byte
+	off      int
+	split    *splitter // of the reader, if any
+	base     int64     // offset of data in the reader
+	baseLine int       // lines of the reader before data
+
+	schema      Schema
+	allowEnv    bool
+	expandEnv   bool
+	envAllow    []string // see ExpandEnv
+	envDeny     []string
+	includeRoot string
+	includes    []string // files being included, to detect cycles
+
+	tagHandles map[string]string // %TAG directives of the document
+
+	tabWidth int
+	expanded bool
+
+	tmpl      *templateConfig // see SetTemplate
+	templated bool            // whether data is the output of tmpl
+
+	validUTF8 bool
+	validated bool // whether data was checked, see SetValidUTF8
+
+	quoted     bool // whether the last scalar read was quoted
+	strict     bool
+	weak       bool
+	zeroCopy   bool
+	jsonCompat bool
+
+	anchors map[string]reflect.Value // values of the anchors, for aliases
+
+	lines    lineTable         // of data, when decoding
+	interned map[string]string // see intern
+
+	keyOff int        // offset of the last key read
+	path   []pathElem // of the node being decoded, for errors
+
+	crypto    *Crypto
+	secret    bool // while decoding the value of a secret field
+	allErrors bool
+	errs      []error // recorded, see SetAllErrors
+	warn      func(*Warning)
+
+	hooks    []DecodeHook
+	hooking  bool // while decoding a node for the hooks
+	skipping bool // while reading a document for Skip, without keeping the nodes
+
+	events func(Event) // of the nodes decoded, for a Scanner
+
+	limits  Limits
+	depth   int // of the current node
+	keys    int // keys read in the document
+	aliases int // aliases decoded in the document
+}
+
+// NewDecoder returns a decoder reading r. Each call to Decode reads
+// the next document of r, separated from the others by --- or ...
+// markers, and returns io.EOF when there are no more documents.
+// Only the document being decoded is kept in memory. The text may
+// be encoded in UTF-8 or UTF-16 and may start with a byte order mark.
+func NewDecoder(r io.Reader, opts ...Option) *Decoder {
+	d := &Decoder{split: newSplitter(r)}
+	d.apply(opts)
+	return d
+}
+
+// NewDecoderBytes returns a decoder reading data, which may be encoded
+// in UTF-8 or UTF-16 and may start with a byte order mark.
+func NewDecoderBytes(data []byte, opts ...Option) *Decoder {
+	d := &Decoder{data: toUTF8(data)}
+	d.apply(opts)
+	return d
+}
+
+// SetSchema sets the schema resolving the type of untagged plain
+// scalars decoded into interface{} values. The default is CoreSchema.
+func (d *Decoder) SetSchema(s Schema) {
+	d.schema = s
+}
+
+// AllowEnv enables the !env tag, which replaces a scalar with the value
+// of the environment variable it names:
+//
+//	password: !env DB_PASSWORD
+//
+// It is disabled by default, as it lets the document read the
+// environment of the process.
+func (d *Decoder) AllowEnv() {
+	d.allowEnv = true
 }
 
-func NewDecoder(data []byte) *Decoder {
-	return &Decoder{data, 0}
+// AllowInclude enables the !include tag, which replaces a node with
+// the first document read from another file, as Decode reads it:
+//
+//	database: !include db.yaml
+//
+// File names are relative to root, and files outside root, even
+// through symbolic links, can not be included. The errors of an
+// included file follow its name.
+func (d *Decoder) AllowInclude(root string) {
+	d.includeRoot = root
+}
+
+// ExpandTabs makes the decoder accept tabs in indentation, which YAML
+// forbids, by expanding them to the next multiple of width spaces.
+// As any leading tab is expanded, tabs at the start of the lines of
+// block scalars are expanded too.
+func (d *Decoder) ExpandTabs(width int) {
+	d.tabWidth = width
+}
+
+// clone returns a decoder with the options of d, sharing none of
+// the state of its decoding, so that both may decode at the same time.
+func (d *Decoder) clone() *Decoder {
+	c := *d
+	c.lines = lineTable{}
+	c.interned = nil
+	c.path = nil
+	c.errs = nil
+	c.anchors = nil
+	c.tagHandles = nil
+	c.includes = append([]string(nil), d.includes...)
+	return &c
 }
 
 func (d *Decoder) Reset(data []byte) {
-	d.data = data
+	d.split, d.base, d.baseLine = nil, 0, 0
+	d.load(data)
+}
+
+// load makes data the text decoded, from its start.
+func (d *Decoder) load(data []byte) {
+	d.data = toUTF8(data)
 	d.off = 0
+	d.expanded = false
+	d.templated = false
+	d.validated = false
+	d.tagHandles = nil
+	d.anchors = nil
+	d.lines.starts = d.lines.starts[:0]
 }
 
 func (d *Decoder) Decode(i interface{}) (err error) {
+	defer d.collected(&err)
+
+	if d.split != nil && !hasContent(d.data[d.off:]) {
+		if err := d.next(); err != nil {
+			return err
+		}
+	}
+	val, err := d.begin(i)
+	if err != nil {
+		return err
+	}
+
+	// The document is decoded alone, up to its end marker if any.
+	data := d.data
+	end, next := documentEnd(data, d.off)
+	d.data = data[:end]
 	defer func() {
-		if r := recover(); r != nil {
-			if _, ok := r.(runtime.Error); ok {
-				panic(err)
-			}
-			err = r.(error)
+		d.data = data
+		if err == nil {
+			d.off = next
 		}
 	}()
 
+	return d.value("", val, 0, stateDefault)
+}
+
+// InputOffset returns the offset in the input of the end of the last
+// document decoded, after its end marker if any. A --- marker starting
+// a document on its line is left in the input.
+// The offsets are those of the input transcoded to UTF-8, without
+// byte order mark.
+func (d *Decoder) InputOffset() int64 {
+	if d.markerRead() {
+		return d.split.off
+	}
+	return d.base + int64(d.off)
+}
+
+// Buffered returns the input following the last document decoded,
+// such as the text of a file following its front matter.
+func (d *Decoder) Buffered() []byte {
+	if d.split != nil {
+		rest := d.split.buffered()
+		if d.markerRead() {
+			rest = rest[len(d.split.pending):]
+		}
+		return append(append([]byte(nil), d.data[d.off:]...), rest...)
+	}
+	return d.data[d.off:]
+}
+
+// markerRead reports whether the reader has read ahead the --- line
+// ending the document decoded, which is part of it for InputOffset.
+func (d *Decoder) markerRead() bool {
+	return d.split != nil && d.split.pending != nil && d.off == len(d.data)
+}
+
+// Skip reads the next document without storing it into a value, and
+// returns its syntax error, if any. Only the nodes being read are held,
+// and as the flow collections are decoded as strings, the plain scalars
+// starting with [ or { are checked to be complete flow collections.
+func (d *Decoder) Skip() error {
+	d.skipping = true
+	defer func() { d.skipping = false }()
+	var n Node
+	return d.Decode(&n)
+}
+
+// checkFlow fails when the plain scalar s starts with an indicator
+// which can not start it, or with [ or { without being a complete flow
+// collection, for Skip.
+func (d *Decoder) checkFlow(name, s string) error {
+	if s == "" {
+		return nil
+	}
+	switch s[0] {
+	case ']', '}', ',', '@', '`':
+		return d.error(name, "unexpected "+strconv.Quote(s[:1]))
+	case '[', '{':
+	default:
+		return nil
+	}
+
+	var open []byte // brackets not closed
+	var quote byte  // of the quoted scalar being read, if any
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case i > 0 && len(open) == 0:
+			return d.error(name, "unexpected text after flow collection")
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			open = append(open, c+2) // ] or }
+		case c == ']' || c == '}':
+			if c != open[len(open)-1] {
+				return d.error(name, "unexpected "+strconv.Quote(string(c)))
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) != 0 || quote != 0 {
+		return d.error(name, "unterminated flow collection")
+	}
+	return nil
+}
+
+// More reports whether the input holds another document, with more
+// than comments and directives, for Decode to decode. A decoder reading
+// a reader reads the next document ahead; when it can not, More reports
+// true, so that Decode returns the error.
+func (d *Decoder) More() bool {
+	if hasContent(d.data[d.off:]) {
+		return true
+	}
+	if d.split == nil {
+		return false
+	}
+	if err := d.next(); err != nil {
+		return err != io.EOF
+	}
+	return true
+}
+
+// next loads the next document of the reader.
+func (d *Decoder) next() error {
+	for {
+		data, line, off, err := d.split.next(d.maxDocumentSize())
+		if err != nil {
+			return err
+		}
+		if d.split.continued {
+			// The --- line ended the previous document, as
+			// documentEnd ends it, rather than starting this one.
+			n := bytes.IndexByte(data, '\n') + 1
+			if n == 0 {
+				n = len(data)
+			}
+			data, line, off = data[n:], line+1, off+int64(n)
+			if !hasContent(data) {
+				continue
+			}
+		}
+		d.base, d.baseLine = off, line-1
+		d.load(data)
+		return nil
+	}
+}
+
+// begin prepares the decoding of a document into i,
+// and returns the value pointed to by i.
+func (d *Decoder) begin(i interface{}) (reflect.Value, error) {
 	val := reflect.ValueOf(i)
 	if val.Kind() != reflect.Ptr || val.IsNil() {
-		d.error("", "expect ptr")
+		return reflect.Value{}, d.error("", "expect ptr")
 	}
-	d.value("", val.Elem(), 0, stateDefault)
-	return
+	d.depth, d.keys, d.aliases = 0, 0, 0
+	d.path = d.path[:0]
+	d.anchors = nil // an alias refers to an anchor of its document
+	d.hooking = false
+	return val.Elem(), d.prepare()
 }
 
-func (d *Decoder) error(name, info string) {
-	panic(fmt.Errorf("%s %s at %d", name, info, d.off))
+// prepare prepares the text of the document at the current position,
+// and reads its directives.
+func (d *Decoder) prepare() error {
+	if d.tmpl != nil && !d.templated {
+		if err := d.execTemplate(); err != nil {
+			return err
+		}
+		d.templated = true
+	}
+	if d.tabWidth > 0 && !d.expanded {
+		d.data = expandTabs(d.data, d.tabWidth)
+		d.expanded = true
+	}
+	// Of the text decoded, after the template.
+	if err := d.checkSize(len(d.data) - d.off); err != nil {
+		return err
+	}
+	if len(d.lines.starts) == 0 {
+		d.scanLines()
+	}
+	if err := d.checkUTF8(); err != nil {
+		return err
+	}
+	return d.directives()
+}
+
+// line returns the 1-based line number of offset off.
+func (d *Decoder) line(off int) int {
+	line, _ := d.position(off)
+	return line
 }
 
 // parse state
@@ -92,121 +446,833 @@ const (
 	stateObjectValue	// The left of current line may be ignored.
 )
 
-func (d *Decoder) value(name string, val reflect.Value, indent, state int) {
-	switch val.Kind() {
-	case reflect.Int, reflect.Int64:
-		i, err := strconv.ParseInt(d.string(indent), 10, val.Type().Bits())
-		if err != nil {
-			d.error(name, err.Error())
+func (d *Decoder) value(name string, val reflect.Value, indent, state int) error {
+	return d.decode(planOf(val.Type()), name, val, indent, state)
+}
+
+// decode decodes the value at the current position into val,
+// whose type has the plan p.
+func (d *Decoder) decode(p *plan, name string, val reflect.Value, indent, state int) error {
+	if err := d.enter(name); err != nil {
+		return err
+	}
+	if err := d.decodeValue(p, name, val, indent, state); err != nil {
+		return err
+	}
+	d.leave()
+	return nil
+}
+
+func (d *Decoder) decodeValue(p *plan, name string, val reflect.Value, indent, state int) error {
+	// Only the scalar of a secret field, through pointers, is decrypted.
+	secret := d.secret
+	d.secret = false
+	if d.hooks != nil {
+		if ok, err := d.hook(p, name, val, indent, state); ok || err != nil {
+			return err
 		}
-		val.SetInt(i)
+	}
+	if p.class == planRaw {
+		d.rawMessage(name, val, indent, state)
+		return nil
+	}
+	if p.class == planNode {
+		return d.node(name, val.Addr().Interface().(*Node), indent, state)
+	}
 
-	case reflect.Float64:
-		f, err := strconv.ParseFloat(d.string(indent), 64)
+	var tag, anchor string
+	var err error
+	if state != stateDefault {
+		tag, anchor, err = d.properties(name)
+	} else {
+		tag, anchor, err = d.rootProperties(name)
+	}
+	if err != nil {
+		return err
+	}
+	if anchor != "" {
+		if err := d.setAnchor(anchor, val); err != nil {
+			return err
+		}
+	}
+	if d.hooking && !isCoreTag(tag) {
+		return d.error(name, "tag "+tag+" is not decoded for the hooks")
+	}
+	if state != stateDefault {
+		if ok, err := d.alias(name, val); ok || err != nil {
+			return err
+		}
+	}
+	if tag == tagInclude {
+		file, err := d.string(indent)
 		if err != nil {
-			d.error(name, err.Error())
+			return err
 		}
-		val.SetFloat(f)
+		return d.include(name, val, file)
+	}
+	if tag == tagEnv {
+		start := d.off
+		key, err := d.string(indent)
+		if err != nil {
+			return err
+		}
+		str, err := d.env(name, key)
+		if err != nil {
+			return err
+		}
+		return d.scalar(name, val, "", str, start)
+	}
+	if f := lookupTag(tag); f != nil {
+		start := d.off
+		str, err := d.string(indent)
+		if err != nil {
+			return err
+		}
+		if err := f(str, val); err != nil {
+			return d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+		return nil
+	}
 
-	case reflect.String:
-		val.SetString(d.string(indent))
+	switch p.class {
+	case planMapSlice:
+		if err := d.checkTag(name, tag, val.Type(), tagMap); err != nil {
+			return err
+		}
+		return d.mapSlice(name, val, indent, state)
 
-	case reflect.Bool:
-		b, err := strconv.ParseBool(d.string(indent))
+	case planTime:
+		str, _, start, err := d.scalarText(name, tag, indent, false)
 		if err != nil {
-			d.error(name, err.Error())
+			return err
 		}
-		val.SetBool(b)
+		return d.time(name, val, str, start)
 
-	case reflect.Slice:
+	case planScalar:
+		str, tag, start, err := d.scalarText(name, tag, indent, secret)
+		if err != nil {
+			return err
+		}
+		return d.scalar(name, val, tag, str, start)
+
+	case planPtr:
+		if d.nodeKind(indent, state) == reflect.String {
+			save := d.off
+			str, err := d.string(indent)
+			if err != nil {
+				return err
+			}
+			if !d.quoted && isNull(str) {
+				val.Set(reflect.Zero(val.Type()))
+				return nil
+			}
+			d.off = save
+		}
+		if val.IsNil() {
+			val.Set(reflect.New(val.Type().Elem()))
+		}
+		d.secret = secret
+		return d.decode(p.elem, name, val.Elem(), indent, state)
+
+	case planInterface:
+		if val.NumMethod() != 0 {
+			if err := d.checkTag(name, tag, val.Type(), tagMap); err != nil {
+				return err
+			}
+			return d.kind(name, val, indent, state)
+		}
+
+		// The Go type of the value depends on the node.
+		var v reflect.Value
+		switch d.nodeKind(indent, state) {
+		case reflect.Slice:
+			if err := d.checkTag(name, tag, val.Type(), tagSeq); err != nil {
+				return err
+			}
+			v = reflect.New(sliceType).Elem()
+		case reflect.Map:
+			if err := d.checkTag(name, tag, val.Type(), tagMap); err != nil {
+				return err
+			}
+			v = reflect.New(mapType).Elem()
+		default:
+			str, tag, start, err := d.scalarText(name, tag, indent, false)
+			if err != nil {
+				return err
+			}
+			if d.quoted && tag == "" {
+				tag = tagStr
+			}
+			return d.scalar(name, val, tag, str, start)
+		}
+		if err := d.value(name, v, indent, state); err != nil {
+			return err
+		}
+		val.Set(v)
+
+	case planSlice:
+		if err := d.checkTag(name, tag, val.Type(), tagSeq); err != nil {
+			return err
+		}
+		if d.weak {
+			if ok, err := d.weakSlice(name, val, p.elem, indent, state); ok || err != nil {
+				return err
+			}
+		}
+		if empty, err := d.emptyFlow(name, "[]", state); empty || err != nil {
+			if empty {
+				val.Set(reflect.MakeSlice(val.Type(), 0, 0))
+			}
+			return err
+		}
 		if state == stateObjectValue {
 			d.nextLine()
+			if indent, err = d.sequenceIndent(indent); err != nil {
+				return err
+			}
 		}
 
-		t := val.Type()
-		elemType := t.Elem()
 		if !val.IsNil() {
 			val.SetLen(0)
 		} /* else {
 			val.Set(reflect.MakeSlice(t, 0, 0))
 		}*/
 
-		ok := d.sliceElem(name, val, elemType, indent, state)
+		ok, err := d.sliceElem(name, val, p.elem, indent, state)
 		for ok {
-			ok = d.sliceElem(name, val, elemType, indent, stateDefault)
+			ok, err = d.sliceElem(name, val, p.elem, indent, stateDefault)
 		}
+		return err
 
-	case reflect.Map:
+	case planMap:
+		if err := d.checkTag(name, tag, val.Type(), tagMap); err != nil {
+			return err
+		}
+		if empty, err := d.emptyFlow(name, "{}", state); empty || err != nil {
+			if empty && val.IsNil() {
+				val.Set(reflect.MakeMap(val.Type()))
+			}
+			return err
+		}
 		if state == stateObjectValue {
 			d.nextLine()
+			if indent, err = d.blockIndent(indent - 2); err != nil {
+				return err
+			}
 		}
 
 		t := val.Type()
-		elemType := t.Elem()
 		if val.IsNil() {
 			val.Set(reflect.MakeMap(t))
 		}
 
-		var elem reflect.Value
-		key := d.key(name, indent, state)
-		for key != "" {
-			if !elem.IsValid() {
-				elem = reflect.New(elemType).Elem()
-			} else {
-				elem.Set(reflect.Zero(elemType))
+		seen := d.keySet()
+		for {
+			key, err := d.key(name, indent, state)
+			if key == "" || err != nil {
+				return err
+			}
+			if err := d.duplicate(name, seen, key); err != nil {
+				return err
+			}
+			// Every entry has its own value, which an anchor may refer to.
+			elem := reflect.New(p.elem.t).Elem()
+			d.pushKey(key)
+			k, err := d.mapKey(key, t.Key(), d.off)
+			if err != nil {
+				return err
 			}
-			d.value(key, elem, indent+2, stateObjectValue)
-			val.SetMapIndex(reflect.ValueOf(key), elem)
-			key = d.key(name, indent, stateDefault)
+			if err := d.decode(p.elem, key, elem, indent+2, stateObjectValue); err != nil {
+				return err
+			}
+			d.pop()
+			val.SetMapIndex(k, elem)
+			state = stateDefault
 		}
 
-	case reflect.Struct:
+	case planStruct:
+		if err := d.checkTag(name, tag, val.Type(), tagMap); err != nil {
+			return err
+		}
+		start := d.off
+		if empty, err := d.emptyFlow(name, "{}", state); empty || err != nil {
+			if empty {
+				return d.required(p.st, nil, val.Type(), start)
+			}
+			return err
+		}
 		if state == stateObjectValue {
 			d.nextLine()
+			if indent, err = d.blockIndent(indent - 2); err != nil {
+				return err
+			}
 		}
 
-		fields := structFileds(val)
-		key := d.key(name, indent, state)
-		for key != "" {
-			if f, ok := fields[key]; ok {
-				d.value(key, f, indent+2, stateObjectValue)
+		start = d.off
+		seen := d.keySet()
+		found := d.fieldSet(p.st)
+		for {
+			key, err := d.key(name, indent, state)
+			if err != nil {
+				return err
+			}
+			if key == "" {
+				break
+			}
+			if err := d.duplicate(name, seen, key); err != nil {
+				return err
+			}
+			if i, ok := p.st.byName[key]; ok {
+				if found != nil {
+					found[i] = true
+				}
+				d.pushKey(key)
+				if p.st.tags[i].deprecated {
+					d.deprecated(p.st.tags[i], d.keyOff)
+				}
+				d.secret = p.st.tags[i].secret
+				if err := d.decode(p.fields[i], key, val.Field(p.st.index[i]), indent+2, stateObjectValue); err != nil {
+					return err
+				}
+				d.pop()
 			} else {
-				d.error(name, "undefined field "+key)
+				if err := d.unknownField(key, val.Type(), d.keyOff); err != nil {
+					return err
+				}
+				d.skipValue(indent)
 			}
-			key = d.key(name, indent, stateDefault)
+			state = stateDefault
 		}
+		return d.required(p.st, found, val.Type(), start)
 
 	default:
-		d.error(name, "unsupported type "+val.Type().String())
+		return d.typeError(name, "unsupported type "+val.Type().String(), ErrUnsupportedType, val.Type(), d.off)
 
 	}
+	return nil
+}
+
+// scalar stores the scalar str, read at offset start, into val.
+func (d *Decoder) scalar(name string, val reflect.Value, tag, str string, start int) error {
+	return d.fieldError(d.setScalar(name, val, tag, str, start))
 }
 
-func (d *Decoder) key(name string, indent, state int) string {
-	if !d.tryLine(indent, state) {
-		return ""
+// setScalar stores the scalar str into val, for scalar.
+func (d *Decoder) setScalar(name string, val reflect.Value, tag, str string, start int) error {
+	if err := d.coerced(name, val, tag, str, start); err != nil {
+		return err
+	}
+	if d.weak {
+		str = weakScalar(val.Kind(), str)
 	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if err := d.checkTag(name, tag, val.Type(), tagInt); err != nil {
+			return err
+		}
+		return d.setInt(name, val, str, start)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if err := d.checkTag(name, tag, val.Type(), tagInt); err != nil {
+			return err
+		}
+		return d.setUint(name, val, str, start)
+
+	case reflect.Float32, reflect.Float64:
+		if err := d.checkTag(name, tag, val.Type(), tagFloat, tagInt); err != nil {
+			return err
+		}
+		f, err := parseFloat(str)
+		if err != nil {
+			if err.(*strconv.NumError).Err != strconv.ErrRange {
+				return d.typeError(name, err.Error(), err, val.Type(), start)
+			}
+			return d.rangeError(name, str, val.Type(), start)
+		}
+		if val.OverflowFloat(f) {
+			return d.rangeError(name, str, val.Type(), start)
+		}
+		val.SetFloat(f)
+
+	case reflect.String:
+		if err := d.checkTag(name, tag, val.Type(), tagStr); err != nil {
+			return err
+		}
+		val.SetString(str)
+
+	case reflect.Bool:
+		if err := d.checkTag(name, tag, val.Type(), tagBool); err != nil {
+			return err
+		}
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+		val.SetBool(b)
+
+	case reflect.Interface:
+		if val.NumMethod() != 0 {
+			return d.typeError(name, "unsupported type "+val.Type().String(), ErrUnsupportedType, val.Type(), start)
+		}
+		v, err := resolve(d.schema, tag, str)
+		if err != nil {
+			return d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+		if d.jsonCompat {
+			if v, err = d.jsonNumber(name, v, str, val.Type(), start); err != nil {
+				return err
+			}
+		}
+		if v == nil {
+			val.Set(reflect.Zero(val.Type()))
+		} else {
+			val.Set(reflect.ValueOf(v))
+		}
+
+	default:
+		return d.typeError(name, "unsupported type "+val.Type().String(), ErrUnsupportedType, val.Type(), start)
+	}
+	return nil
+}
+
+func (d *Decoder) setInt(name string, val reflect.Value, s string, start int) error {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			return d.rangeError(name, s, val.Type(), start)
+		}
+		// An integral float such as 1e3 is accepted,
+		// anything with a fractional part is not.
+		f, ferr := parseFloat(s)
+		if ferr != nil {
+			return d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return d.rangeError(name, s, val.Type(), start)
+		}
+		i = int64(f)
+	}
+	if val.OverflowInt(i) {
+		return d.rangeError(name, s, val.Type(), start)
+	}
+	val.SetInt(i)
+	return nil
+}
+
+func (d *Decoder) setUint(name string, val reflect.Value, s string, start int) error {
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		if err.(*strconv.NumError).Err == strconv.ErrRange {
+			return d.rangeError(name, s, val.Type(), start)
+		}
+		f, ferr := parseFloat(s)
+		if ferr != nil {
+			return d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return d.rangeError(name, s, val.Type(), start)
+		}
+		u = uint64(f)
+	}
+	if val.OverflowUint(u) {
+		return d.rangeError(name, s, val.Type(), start)
+	}
+	val.SetUint(u)
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// mapKey converts key, read at offset start, to the key type t of a map.
+func (d *Decoder) mapKey(key string, t reflect.Type, start int) (reflect.Value, error) {
+	k := reflect.New(t)
+	if t.Kind() != reflect.String && reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		if err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+			return reflect.Value{}, d.typeError(key, err.Error(), err, t, start)
+		}
+		return k.Elem(), nil
+	}
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(key).Convert(t), nil
+	}
+	return k.Elem(), d.scalar(key, k.Elem(), "", key, start)
+}
+
+// emptyFlow consumes the empty flow collection flow, "[]" or "{}",
+// standing for a block collection. Other content on the line of
+// a key is rejected, as a block collection starts on the next line.
+func (d *Decoder) emptyFlow(name, flow string, state int) (bool, error) {
+	if state == stateDefault {
+		return false, nil
+	}
+	line, pos := d.peekLine()
+	line = bytes.TrimSpace(line)
+	if string(line) == flow {
+		d.off = pos
+		return true, nil
+	}
+	if state == stateObjectValue && len(line) != 0 {
+		return false, d.error(name, "unexpected "+string(line))
+	}
+	return false, nil
+}
+
+// tag consumes the tag property, if any, in front of the value
+// at the current position.
+func (d *Decoder) tag(name string) (string, error) {
+	i := d.off
+	for i < len(d.data) && d.data[i] == ' ' {
+		i++
+	}
+	if i == len(d.data) || d.data[i] != '!' {
+		return "", nil
+	}
+
+	start := i
+	if i+1 < len(d.data) && d.data[i+1] == '<' {
+		for i < len(d.data) && d.data[i] != '>' && d.data[i] != '\n' {
+			i++
+		}
+		if i == len(d.data) || d.data[i] != '>' {
+			return "", d.error(name, "unterminated verbatim tag")
+		}
+		i++
+	} else {
+		for i < len(d.data) && d.data[i] != ' ' && d.data[i] != '\n' {
+			i++
+		}
+	}
+	d.off = i
+	tag, err := d.expandTag(name, string(d.data[start:i]))
+	return shortTag(tag), err
+}
+
+// expandTag replaces the handle of tag with the prefix
+// declared by a %TAG directive.
+func (d *Decoder) expandTag(name, tag string) (string, error) {
+	if strings.HasPrefix(tag, "!<") {
+		return tag, nil
+	}
+	handle := "!"
+	if i := strings.IndexByte(tag[1:], '!'); i != -1 {
+		handle = tag[:i+2]
+	}
+	prefix, ok := d.tagHandles[handle]
+	if !ok {
+		if handle != "!" && handle != "!!" {
+			return "", d.error(name, "undefined tag handle "+handle)
+		}
+		return tag, nil
+	}
+	return "!<" + prefix + tag[len(handle):] + ">", nil
+}
+
+// directives reads the directives in front of the document,
+// up to the document start marker.
+func (d *Decoder) directives() error {
+	seen := false
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			break
+		}
+		line = bytes.TrimRight(line, " \t\r")
+		switch {
+		case len(line) == 0:
+
+		case line[0] == '%':
+			seen = true
+			fields := strings.Fields(string(line[1:]))
+			if len(fields) == 0 {
+				return d.error("", "expect directive name")
+			}
+			switch fields[0] {
+			case "YAML":
+				if len(fields) != 2 || !strings.HasPrefix(fields[1], "1.") {
+					return d.error("", "unsupported %YAML directive "+string(line))
+				}
+			case "TAG":
+				if len(fields) != 3 {
+					return d.error("", "invalid %TAG directive "+string(line))
+				}
+				if d.tagHandles == nil {
+					d.tagHandles = make(map[string]string)
+				}
+				d.tagHandles[fields[1]] = fields[2]
+			}
+			// Reserved directives are ignored.
+
+		case string(line) == "---":
+			d.off = pos
+			return nil
+
+		default:
+			if seen {
+				return d.error("", "expect document start")
+			}
+			return nil
+		}
+		d.off = pos
+	}
+	if seen {
+		return d.error("", "expect document start")
+	}
+	return nil
+}
+
+// documentEnd returns the end of the document starting at offset off
+// of data, before the line of its end marker or of the start marker of
+// the next document, and the offset following the document. An end
+// marker, or a start marker alone on its line, is part of the document.
+func documentEnd(data []byte, off int) (end, next int) {
+	for i := off; i < len(data); {
+		j := bytes.IndexByte(data[i:], '\n') + 1
+		if j == 0 {
+			j = len(data)
+		} else {
+			j += i
+		}
+		line := bytes.TrimRight(data[i:j], " \t\r\n")
+		switch {
+		case string(line) == "---", bytes.HasPrefix(line, []byte("...")) && isBlank(line, 3):
+			return i, j
+		case bytes.HasPrefix(line, []byte("---")) && isBlank(line, 3) && i != off:
+			// The marker of the document itself is the one at off.
+			return i, i
+		}
+		i = j
+	}
+	return len(data), len(data)
+}
+
+// hasContent reports whether data holds more than blank lines,
+// comments and directives.
+func hasContent(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) != 0 && line[0] != '#' && line[0] != '%' {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Decoder) checkTag(name, tag string, t reflect.Type, allowed ...string) error {
+	if tag == "" {
+		return nil
+	}
+	for _, a := range allowed {
+		if tag == a {
+			return nil
+		}
+	}
+	return d.typeError(name, "tag "+tag+" conflicts with "+t.String(), nil, t, d.off)
+}
+
+func (d *Decoder) key(name string, indent, state int) (string, error) {
+	if ok, err := d.tryLine(indent, state); !ok || err != nil {
+		return "", err
+	}
+	if err := d.countKey(name); err != nil {
+		return "", err
+	}
+	d.keyOff = d.off
 
 	if d.off < len(d.data) && d.data[d.off] == '"' {
 		return d.quotedKey(name)
 	}
+	if d.indicator('?') {
+		return d.explicitKey(name, indent)
+	}
 
+	// Only a colon followed by a space or the end of line ends the key,
+	// so that keys like "http://host:80" or "C:\dir" are allowed.
 	for i := d.off; i < len(d.data); i++ {
 		c := d.data[i]
-		if c == ':' {
+		if c == ':' && isBlank(d.data, i+1) {
 			start := d.off
 			d.off = i + 1
-			return string(bytes.TrimSpace(d.data[start:i]))
-		} else if c == '\n' {
+			return d.intern(bytes.TrimSpace(d.data[start:i])), nil
+		} else if c == '\n' || c == '#' && i > d.off && isBlank(d.data, i-1) {
 			break
 		}
 	}
 
-	d.error(name, "expect key")
-	return ""
+	return "", d.error(name, "expect key")
+}
+
+// indicator reports whether the current position holds the
+// indicator c, followed by a space or the end of line.
+func (d *Decoder) indicator(c byte) bool {
+	i := d.off
+	if i >= len(d.data) || d.data[i] != c {
+		return false
+	}
+	i++
+	return i == len(d.data) || d.data[i] == ' ' || d.data[i] == '\n' || d.data[i] == '\r'
+}
+
+// explicitKey reads a key given with the "? " indicator, whose
+// value follows on a line starting with ": " at the same indentation.
+// A collection as a key is read as its text in JSON, like ["a","b"].
+func (d *Decoder) explicitKey(name string, indent int) (string, error) {
+	d.off++
+	line, _ := d.peekLine()
+	if len(bytes.TrimSpace(line)) == 0 {
+		save := d.off
+		d.nextLine()
+		ok, err := d.tryLine(indent+1, stateDefault)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			line, _ = d.peekLine()
+		}
+		d.off = save
+	}
+	if !isCollection(bytes.TrimSpace(line)) {
+		key, err := d.string(indent + 2)
+		if err != nil {
+			return "", err
+		}
+		return key, d.explicitValue(indent)
+	}
+
+	k := &Node{}
+	if err := d.enter(name); err != nil {
+		return "", err
+	}
+	entryIndent, err := d.entryIndent(indent)
+	if err != nil {
+		return "", err
+	}
+	if err := d.node(name, k, entryIndent, stateListElem); err != nil {
+		return "", err
+	}
+	d.leave()
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, k); err != nil {
+		return "", d.error(name, "complex key not supported")
+	}
+	return buf.String(), d.explicitValue(indent)
+}
+
+// explicitValue moves to the value of an explicit key,
+// given on a line starting with ": " at column indent.
+func (d *Decoder) explicitValue(indent int) error {
+	save := d.off
+	ok, err := d.tryLine(indent, stateDefault)
+	if err != nil {
+		return err
+	}
+	if ok && d.indicator(':') {
+		d.off++
+		return nil
+	}
+	// A key without value has an empty value.
+	d.off = save
+	if save > 0 && d.data[save-1] == '\n' {
+		d.off--
+	}
+	return nil
+}
+
+var (
+	sliceType = reflect.TypeOf([]interface{}(nil))
+	mapType   = reflect.TypeOf(map[string]interface{}(nil))
+)
+
+// nodeKind tells whether the node at the current position is a
+// sequence (reflect.Slice), a mapping (reflect.Map) or a scalar
+// (reflect.String), without consuming it.
+func (d *Decoder) nodeKind(indent, state int) reflect.Kind {
+	line, _ := d.peekLine()
+	line = bytes.TrimSpace(line)
+	switch {
+	case state == stateObjectValue && len(line) != 0:
+		switch string(line) {
+		case "[]":
+			return reflect.Slice
+		case "{}":
+			return reflect.Map
+		}
+		return reflect.String
+	case state == stateListElem && len(line) != 0:
+		return lineKind(line)
+	}
+
+	// The node is a block on the following lines,
+	// more indented than its parent.
+	parent := -1
+	switch state {
+	case stateObjectValue:
+		parent = indent - 2
+	case stateListElem:
+		parent = indent - 1
+	}
+
+	save := d.off
+	defer func() { d.off = save }()
+	if state != stateDefault {
+		d.nextLine()
+	}
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			return reflect.String
+		}
+		content := bytes.TrimLeft(line, " ")
+		if len(bytes.TrimSpace(content)) != 0 {
+			n := len(line) - len(content)
+			content = bytes.TrimSpace(content)
+			switch {
+			case n > parent:
+				return lineKind(content)
+			case n == parent && state == stateObjectValue && isSequence(content):
+				// The entries may be as indented as the key.
+				return reflect.Slice
+			}
+			return reflect.String
+		}
+		d.off = pos
+	}
+}
+
+// lineKind tells whether the content of line starts
+// a sequence, a mapping or a scalar.
+func lineKind(line []byte) reflect.Kind {
+	switch {
+	case isSequence(line) || string(line) == "[]":
+		return reflect.Slice
+	case isMapping(line) || string(line) == "{}":
+		return reflect.Map
+	}
+	return reflect.String
+}
+
+func isSequence(line []byte) bool {
+	return len(line) > 0 && line[0] == '-' && (len(line) == 1 || line[1] == ' ')
+}
+
+func isMapping(line []byte) bool {
+	if len(line) > 0 && line[0] == '?' && (len(line) == 1 || line[1] == ' ') {
+		return true
+	}
+	for i, c := range line {
+		if c == ':' && isBlank(line, i+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCollection reports whether the content of line starts
+// a sequence entry or a mapping entry.
+func isCollection(line []byte) bool {
+	return isSequence(line) || isMapping(line)
 }
 
-func (d *Decoder) quotedKey(name string) string {
+func (d *Decoder) quotedKey(name string) (string, error) {
 LOOP:
 	for i := d.off+1; i < len(d.data); i++ {
 		switch c := d.data[i]; c {
@@ -220,16 +1286,16 @@ LOOP:
 			}
 
 		case '"':
-			key, err := strconv.Unquote(string(d.data[d.off:i+1]))
+			key, err := strconv.Unquote(d.str(d.data[d.off : i+1]))
 			if err != nil {
-				d.error(name, err.Error())
+				return "", d.error(name, err.Error())
 			}
 			for i++; i<len(d.data); i++ {
 				switch c = d.data[i]; c {
 				case ' ', '\t':
 
 				case ':':
-					d.off = i+1; return key
+					d.off = i+1; return key, nil
 				default:
 					break LOOP
 				}
@@ -237,18 +1303,17 @@ LOOP:
 		}
 	}
 
-	d.error(name, "expect key")
-	return ""
+	return "", d.error(name, "expect key")
 }
 
-func (d *Decoder) tryLine(indent, state int) bool {
+func (d *Decoder) tryLine(indent, state int) (bool, error) {
 	var line []byte
 	var pos int
 
 	if state == stateListElem {
 		line, pos = d.peekLine()
 		if len(bytes.TrimSpace(line)) != 0 {
-			return true
+			return true, nil
 		}
 		d.off = pos
 	}
@@ -256,7 +1321,7 @@ func (d *Decoder) tryLine(indent, state int) bool {
 	for {
 		line, pos = d.peekLine()
 		if d.off == pos { // at Eof
-			return false
+			return false, nil
 		}
 		if len(bytes.TrimSpace(line)) != 0 {
 			break
@@ -264,18 +1329,55 @@ func (d *Decoder) tryLine(indent, state int) bool {
 		d.off = pos
 	}
 
+	if err := d.checkTabs(line); err != nil {
+		return false, err
+	}
 	if hasIndent(line, indent) {
 		d.off += indent
+		return true, nil
+	}
+	return false, nil
+}
+
+// checkTabs rejects tabs in the indentation of line,
+// which starts at the current position.
+func (d *Decoder) checkTabs(line []byte) error {
+	for i, c := range line {
+		if c == '\t' {
+			d.off += i
+			return d.error("", "tab used for indentation")
+		}
+		if c != ' ' {
+			return nil
+		}
+	}
+	return nil
+}
+
+// isBlank reports whether data[i] is a space, a tab
+// or the end of a line.
+func isBlank(data []byte, i int) bool {
+	if i >= len(data) {
+		return true
+	}
+	switch data[i] {
+	case ' ', '\t', '\r', '\n':
 		return true
 	}
 	return false
 }
 
 func (d *Decoder) peekLine() ([]byte, int) {
+	if len(d.lines.starts) != 0 {
+		if line, pos, ok := d.tableLine(); ok {
+			return line, pos
+		}
+	}
 	end := len(d.data)
 	for i := d.off; i < len(d.data); i++ {
 		c := d.data[i]
-		if c == '#' {
+		if c == '#' && end == len(d.data) && (i == 0 || isBlank(d.data, i-1)) {
+			// A comment is separated from other tokens by white space.
 			end = i
 		} else if c == '\n' {
 			if i < end {
@@ -296,6 +1398,35 @@ func (d *Decoder) nextLine() {
 	}
 }
 
+// expandTabs returns a copy of data with the leading tabs
+// of every line expanded to spaces.
+func expandTabs(data []byte, width int) []byte {
+	if bytes.IndexByte(data, '\t') == -1 {
+		return data
+	}
+	buf := make([]byte, 0, len(data))
+	col, leading := 0, true
+	for _, c := range data {
+		switch {
+		case c == '\n':
+			col, leading = 0, true
+			buf = append(buf, c)
+		case c == '\t' && leading:
+			for n := width - col%width; n > 0; n-- {
+				buf = append(buf, ' ')
+				col++
+			}
+		case c == ' ' && leading:
+			col++
+			buf = append(buf, c)
+		default:
+			leading = false
+			buf = append(buf, c)
+		}
+	}
+	return buf
+}
+
 func hasIndent(line []byte, indent int) bool {
 	if len(line) <= indent {
 		return false
@@ -308,17 +1439,107 @@ func hasIndent(line []byte, indent int) bool {
 	return true
 }
 
-func (d *Decoder) sliceElem(name string, slice reflect.Value, elemType reflect.Type, indent, state int) (ok bool) {
-	if d.tryLine(indent, state) && d.data[d.off] == '-' {
-		d.off++
-		if d.off < len(d.data) && d.data[d.off] == ' ' {
+func (d *Decoder) sliceElem(name string, slice reflect.Value, elem *plan, indent, state int) (bool, error) {
+	save := d.off
+	ok, err := d.tryLine(indent, state)
+	if err != nil {
+		return false, err
+	}
+	if !ok || d.data[d.off] != '-' {
+		// Leave the line to the parent node.
+		d.off = save
+		return false, nil
+	}
+	d.off++
+	elemIndent, err := d.entryIndent(indent)
+	if err != nil {
+		return false, err
+	}
+	slice.Set(reflect.Append(slice, reflect.Zero(elem.t)))
+	d.pushIndex(slice.Len() - 1)
+	if err := d.decode(elem, name, slice.Index(slice.Len()-1), elemIndent, stateListElem); err != nil {
+		return false, err
+	}
+	d.pop()
+	return true, nil
+}
+
+// entryIndent returns the indentation of the content of an entry
+// whose indicator, at column indent, has just been consumed.
+func (d *Decoder) entryIndent(indent int) (int, error) {
+	// The content of an entry starting on the indicator line
+	// sets the indentation of the rest of the entry.
+	if line, _ := d.peekLine(); len(bytes.TrimSpace(line)) != 0 {
+		for d.data[d.off] == ' ' {
 			d.off++
 		}
-		slice.Set(reflect.Append(slice, reflect.Zero(elemType)))
-		d.value(name, slice.Index(slice.Len()-1), indent+2, stateListElem)
-		ok = true
+		return d.column(), nil
 	}
-	return
+	save := d.off
+	d.nextLine()
+	n, err := d.blockIndent(indent)
+	d.off = save
+	return n, err
+}
+
+// blockIndent returns the indentation of the block of children
+// starting at the next non-empty line. If that line is not more
+// indented than parent, there is no such block, and parent+2 is
+// returned, which matches none of the following lines.
+func (d *Decoder) blockIndent(parent int) (int, error) {
+	save := d.off
+	defer func() { d.off = save }()
+
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			return parent + 2, nil
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			if err := d.checkTabs(line); err != nil {
+				return 0, err
+			}
+			n := len(line) - len(bytes.TrimLeft(line, " "))
+			if n <= parent {
+				return parent + 2, nil
+			}
+			return n, nil
+		}
+		d.off = pos
+	}
+}
+
+// sequenceIndent returns the indentation of the entries of a sequence,
+// the value of a key at column indent-2, starting at the next line.
+func (d *Decoder) sequenceIndent(indent int) (int, error) {
+	// The entries may be as indented as the key.
+	if indent >= 2 {
+		if at, err := d.entryAt(indent - 2); at || err != nil {
+			return indent - 2, err
+		}
+	}
+	return d.blockIndent(indent - 2)
+}
+
+// entryAt reports whether the next non-empty line
+// is a sequence entry at column indent.
+func (d *Decoder) entryAt(indent int) (bool, error) {
+	save := d.off
+	defer func() { d.off = save }()
+
+	ok, err := d.tryLine(indent, stateDefault)
+	if !ok || err != nil || d.data[d.off] != '-' {
+		return false, err
+	}
+	return d.off+1 == len(d.data) || d.data[d.off+1] == ' ' || d.data[d.off+1] == '\n', nil
+}
+
+// column returns the column of the current position.
+func (d *Decoder) column() int {
+	if len(d.lines.starts) != 0 {
+		return d.off - d.lines.starts[d.lines.lineAt(d.off)]
+	}
+	return d.off - bytes.LastIndexByte(d.data[:d.off], '\n') - 1
 }
 
 
@@ -329,29 +1550,117 @@ const (
 	strPreserved
 )
 
-func (d *Decoder) string(indent int) string {
+// chomping indicator of block scalars
+const (
+	chompClip = iota
+	chompStrip
+	chompKeep
+)
+
+func (d *Decoder) string(indent int) (string, error) {
+	if s, ok, err := d.quotedString(); ok || err != nil {
+		return s, err
+	}
+
 	line, pos := d.peekLine()
 	line = bytes.TrimSpace(line)
 	d.off = pos
 
 	if len(line) == 0 {
-		return d.strMultiLine(indent, strDefault)
+		return d.strMultiLine(indent, strDefault, chompClip), nil
 	}
-	if len(line) == 1 {
-		switch line[0] {
-		case '>':
-			return d.strMultiLine(indent, strFolded)
-		case '|':
-			return d.strMultiLine(indent, strPreserved)
+	if line[0] == '>' || line[0] == '|' {
+		if m, chomp, ok := blockHeader(line[1:]); ok {
+			if m != 0 {
+				// The indentation indicator is relative
+				// to the indentation of the parent node.
+				indent -= 2
+				if indent < 0 {
+					indent = 0
+				}
+				indent += m
+			} else {
+				var err error
+				if indent, err = d.blockIndent(indent - 2); err != nil {
+					return "", err
+				}
+			}
+			if line[0] == '>' {
+				return d.strMultiLine(indent, strFolded, chomp), nil
+			}
+			return d.strMultiLine(indent, strPreserved, chomp), nil
 		}
 	}
 
 	// Thinking:
 	// return string(line) + d.strMultiLine(indent, strDefault)
-	return string(line)
+	return d.intern(line), nil
 }
 
-func (d *Decoder) strMultiLine(indent, mode int) string {
+// quotedString reads the scalar at the current position if it
+// is quoted. A quoted scalar must end on its first line.
+func (d *Decoder) quotedString() (string, bool, error) {
+	d.quoted = false
+	i := d.off
+	for i < len(d.data) && d.data[i] == ' ' {
+		i++
+	}
+	if i == len(d.data) || d.data[i] != '"' && d.data[i] != '\'' {
+		return "", false, nil
+	}
+
+	q := d.data[i]
+	for j := i + 1; j < len(d.data) && d.data[j] != '\n'; j++ {
+		switch c := d.data[j]; {
+		case c == '\\' && q == '"':
+			j++
+		case c == '\'' && q == '\'' && j+1 < len(d.data) && d.data[j+1] == '\'':
+			j++
+		case c == q:
+			raw := d.str(d.data[i : j+1])
+			d.off = j + 1
+			line, pos := d.peekLine()
+			if len(bytes.TrimSpace(line)) != 0 {
+				return "", false, d.error("", "unexpected "+string(bytes.TrimSpace(line))+" after quoted scalar")
+			}
+			d.off = pos
+			d.quoted = true
+
+			if q == '\'' {
+				return strings.ReplaceAll(raw[1:len(raw)-1], "''", "'"), true, nil
+			}
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return "", false, d.error("", "invalid quoted scalar "+raw)
+			}
+			return s, true, nil
+		}
+	}
+	return "", false, d.error("", "unterminated quoted scalar")
+}
+
+// blockHeader parses the indentation and chomping indicators,
+// in either order, following a block scalar indicator.
+func blockHeader(h []byte) (indent, chomp int, ok bool) {
+	if len(h) > 2 {
+		return 0, 0, false
+	}
+	for _, c := range h {
+		switch {
+		case '1' <= c && c <= '9' && indent == 0:
+			indent = int(c - '0')
+		case c == '-' && chomp == chompClip:
+			chomp = chompStrip
+		case c == '+' && chomp == chompClip:
+			chomp = chompKeep
+		default:
+			return 0, 0, false
+		}
+	}
+	return indent, chomp, true
+}
+
+func (d *Decoder) strMultiLine(indent, mode, chomp int) string {
 	var buf bytes.Buffer
 	needSpace, ln := false, 0
 
@@ -379,9 +1688,21 @@ func (d *Decoder) strMultiLine(indent, mode int) string {
 			}
 		}
 	}
-	if mode == strFolded && buf.Len() != 0 {
+	if mode == strDefault || buf.Len() == 0 {
+		return buf.String()
+	}
+
+	if mode == strFolded {
 		buf.WriteByte('\n')
 	}
+	switch chomp {
+	case chompStrip:
+		buf.Truncate(buf.Len() - 1)
+	case chompKeep:
+		for i := 0; i < ln; i++ {
+			buf.WriteByte('\n')
+		}
+	}
 	return buf.String()
 }
 
@@ -415,23 +1736,3 @@ func (d *Decoder) peekStringLine() ([]byte, int) {
 	return d.data[d.off:], len(d.data)
 }
 
-func structFileds(val reflect.Value) map[string]reflect.Value {
-	m := make(map[string]reflect.Value)
-	t := val.Type()
-	var name string
-	for i, n := 0, t.NumField(); i < n; i++ {
-		f := t.Field(i)
-		if f.PkgPath == "" {
-			name = f.Tag.Get("yaml")
-			if name == "" {
-				name = f.Name
-			} else {
-				if i := strings.Index(name, ","); i != -1 {
-					name = name[:i]
-				}
-			}
-			m[name] = val.Field(i)
-		}
-	}
-	return m
-}
@@ -1,8 +1,28 @@
 package yaml
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"text/template"
+	"time"
+	"unsafe"
 )
 
 func assertEqual(t *testing.T, x, y interface{}) {
@@ -42,10 +62,2915 @@ E :
 	}
 	
 	err := Unmarshal(data, &s)
-	assertEqual(err, nil)
+	assertEqual(t, err, nil)
 	assertEqual(t, s.A, 1)
 	assertEqual(t, s.B, "abc")
 	assertEqual(t, s.C, "abc def\n")
 	assertEqual(t, s.D, "")
 	assertEqual(t, s.E, []int{1,2,3})
 }
+
+func TestDecodeInfNaN(t *testing.T) {
+	data := []byte(`
+a: .inf
+b: -.Inf
+c: .nan
+`)
+
+	var s struct {
+		A float64 `yaml:"a"`
+		B float64 `yaml:"b"`
+		C float64 `yaml:"c"`
+	}
+
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, math.IsInf(s.A, 1), true)
+	assertEqual(t, math.IsInf(s.B, -1), true)
+	assertEqual(t, math.IsNaN(s.C), true)
+
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	s.A, s.B, s.C = 0, 0, 0
+	err = Unmarshal(out, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, math.IsInf(s.A, 1), true)
+	assertEqual(t, math.IsInf(s.B, -1), true)
+	assertEqual(t, math.IsNaN(s.C), true)
+}
+
+func TestDecodeRange(t *testing.T) {
+	var s struct {
+		A int8
+		B int
+		C uint16
+	}
+
+	err := Unmarshal([]byte("A: 300\n"), &s)
+	re, ok := err.(*RangeError)
+	assertEqual(t, ok, true)
+	assertEqual(t, re.Field, "A")
+	assertEqual(t, re.Line, 1)
+	assertEqual(t, re.Error(), "A 300 out of range of int8 at line 1, column 4")
+
+	err = Unmarshal([]byte("B: 1\n\nB: 1.5\n"), &s)
+	re, ok = err.(*RangeError)
+	assertEqual(t, ok, true)
+	assertEqual(t, re.Line, 3)
+
+	err = Unmarshal([]byte("B: 1e3\nC: -1\n"), &s)
+	_, ok = err.(*RangeError)
+	assertEqual(t, ok, true)
+	assertEqual(t, s.B, 1000)
+}
+
+func TestDecodeTag(t *testing.T) {
+	data := []byte(`
+a: !!str 8080
+b: 8080
+c: !!float 1
+d: !<tag:yaml.org,2002:str> true
+e: !!str 42
+`)
+
+	var s struct {
+		A interface{} `yaml:"a"`
+		B interface{} `yaml:"b"`
+		C interface{} `yaml:"c"`
+		D interface{} `yaml:"d"`
+		E string      `yaml:"e"`
+	}
+
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.A, "8080")
+	assertEqual(t, s.B, 8080)
+	assertEqual(t, s.C, 1.0)
+	assertEqual(t, s.D, "true")
+	assertEqual(t, s.E, "42")
+
+	var i struct{ Port int }
+	err = Unmarshal([]byte("Port: !!str 8080\n"), &i)
+	assertEqual(t, err != nil, true)
+}
+
+func TestDecodeCustomTag(t *testing.T) {
+	RegisterTag("!upper", func(value string, target reflect.Value) error {
+		target.SetString(strings.ToUpper(value))
+		return nil
+	})
+
+	var s struct{ Name string }
+	err := Unmarshal([]byte("Name: !upper abc\n"), &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Name, "ABC")
+}
+
+func TestDecodeEnv(t *testing.T) {
+	t.Setenv("YAML_TEST_PORT", "8080")
+	data := []byte("Port: !env YAML_TEST_PORT\n")
+
+	var s struct{ Port int }
+	err := Unmarshal(data, &s)
+	assertEqual(t, err != nil, true)
+
+	d := NewDecoderBytes(data)
+	d.AllowEnv()
+	err = d.Decode(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Port, 8080)
+}
+
+func TestDecodeInclude(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "db.yaml"), []byte("host: localhost\nport: 5432\n"), 0666)
+	os.WriteFile(filepath.Join(dir, "loop.yaml"), []byte("a: !include loop.yaml\n"), 0666)
+
+	var s struct {
+		Db struct {
+			Host string `yaml:"host"`
+			Port int    `yaml:"port"`
+		}
+	}
+	d := NewDecoderBytes([]byte("Db: !include db.yaml\n"))
+	d.AllowInclude(dir)
+	err := d.Decode(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Db.Host, "localhost")
+	assertEqual(t, s.Db.Port, 5432)
+
+	var m map[string]map[string]string
+	d = NewDecoderBytes([]byte("x: !include loop.yaml\n"))
+	d.AllowInclude(dir)
+	err = d.Decode(&m)
+	assertEqual(t, err != nil, true)
+
+	// The links are followed, inside the root only.
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.yaml"), []byte("key: x\n"), 0666)
+	os.Symlink(filepath.Join(outside, "secret.yaml"), filepath.Join(dir, "secret.yaml"))
+	os.Symlink(filepath.Join(dir, "db.yaml"), filepath.Join(dir, "link.yaml"))
+	m = nil
+	d = NewDecoderBytes([]byte("x: !include link.yaml\n"))
+	d.AllowInclude(dir)
+	assertEqual(t, d.Decode(&m), nil)
+	assertEqual(t, m["x"]["host"], "localhost")
+	d = NewDecoderBytes([]byte("x: !include secret.yaml\n"))
+	d.AllowInclude(dir)
+	err = d.Decode(&m)
+	assertEqual(t, err != nil && strings.Contains(err.Error(), "outside the include root"), true)
+
+	// An included file is a document, with its directives and markers.
+	os.WriteFile(filepath.Join(dir, "doc.yaml"), []byte("%YAML 1.2\n---\nhost: h\n...\n---\nhost: other\n"), 0666)
+	m = nil
+	d = NewDecoderBytes([]byte("x: !include doc.yaml\n"))
+	d.AllowInclude(dir)
+	assertEqual(t, d.Decode(&m), nil)
+	assertEqual(t, m["x"], map[string]string{"host": "h"})
+
+	os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("host: h\nport: x\n"), 0666)
+	d = NewDecoderBytes([]byte("Db: !include bad.yaml\n"))
+	d.AllowInclude(dir)
+	err = d.Decode(&s)
+	var te *TypeError
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, err.Error(), "bad.yaml: Db.port strconv.ParseInt: parsing \"x\": invalid syntax at line 2, column 7")
+}
+
+type testBackend interface {
+	Addr() string
+}
+
+type testHTTPBackend struct {
+	Kind string `yaml:"kind"`
+	URL  string `yaml:"url"`
+}
+
+func (b testHTTPBackend) Addr() string { return b.URL }
+
+type testFileBackend struct {
+	Kind string `yaml:"kind"`
+	Path string `yaml:"path"`
+}
+
+func (b *testFileBackend) Addr() string { return b.Path }
+
+func TestDecodeKind(t *testing.T) {
+	RegisterKind("kind", "http", testHTTPBackend{})
+	RegisterKind("kind", "file", testFileBackend{})
+
+	data := []byte(`
+backends:
+  - url: http://localhost
+    kind: http
+  - kind: file
+    path: /tmp/x
+`)
+
+	var s struct {
+		Backends []testBackend `yaml:"backends"`
+	}
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, len(s.Backends), 2)
+	assertEqual(t, s.Backends[0], testBackend(testHTTPBackend{"http", "http://localhost"}))
+	assertEqual(t, s.Backends[1].Addr(), "/tmp/x")
+
+	// The keys read for the kind are counted once.
+	d := NewDecoderBytes(data, WithLimits(Limits{MaxKeys: 5}))
+	assertEqual(t, d.Decode(&s), nil)
+	d = NewDecoderBytes(data, WithLimits(Limits{MaxKeys: 4}))
+	assertEqual(t, d.Decode(&s) != nil, true)
+}
+
+func TestDecodeSchema(t *testing.T) {
+	data := []byte("a: 1\nb: True\nc: ~\n")
+
+	var m map[string]interface{}
+	err := Unmarshal(data, &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]interface{}{"a": 1, "b": true, "c": nil})
+
+	m = nil
+	d := NewDecoderBytes(data)
+	d.SetSchema(FailsafeSchema)
+	err = d.Decode(&m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]interface{}{"a": "1", "b": "True", "c": "~"})
+
+	m = nil
+	d = NewDecoderBytes(data)
+	d.SetSchema(JSONSchema)
+	err = d.Decode(&m)
+	assertEqual(t, err != nil, true)
+}
+
+func TestDecodeDirectives(t *testing.T) {
+	data := []byte(`%YAML 1.2
+%TAG !y! tag:yaml.org,2002:
+---
+a: !y!str 1
+b: 2
+`)
+
+	var m map[string]interface{}
+	err := Unmarshal(data, &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]interface{}{"a": "1", "b": 2})
+
+	err = Unmarshal([]byte("%YAML 2.0\n---\na: 1\n"), &m)
+	assertEqual(t, err != nil, true)
+}
+
+func TestDecodeBlockHeader(t *testing.T) {
+	data := []byte(`
+a: |2
+    indented
+  text
+b: >-
+  folded
+  text
+c: |+
+  kept
+
+d: 1
+`)
+
+	type T struct {
+		A string `yaml:"a"`
+		B string `yaml:"b"`
+		C string `yaml:"c"`
+		D int    `yaml:"d"`
+	}
+	var s T
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.A, "  indented\ntext\n")
+	assertEqual(t, s.B, "folded text")
+	assertEqual(t, s.C, "kept\n\n")
+
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	var r T
+	err = Unmarshal(out, &r)
+	assertEqual(t, err, nil)
+	assertEqual(t, r, s)
+
+	// The raw nodes are not decoded, nor their tags.
+	data = []byte(`config:
+  key: !secret abc
+  home: !env HOME
+text: |
+  a
+  # b
+items:
+- - !x 1
+  - 2
+- - c
+name: n
+`)
+	var v struct {
+		Config RawMessage     `yaml:"config"`
+		Text   RawMessage     `yaml:"text"`
+		Items  [][]RawMessage `yaml:"items"`
+		Name   string         `yaml:"name"`
+	}
+	err = Unmarshal(data, &v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(v.Config), "key: !secret abc\nhome: !env HOME\n")
+	assertEqual(t, string(v.Text), "|\n  a\n  # b\n")
+	assertEqual(t, len(v.Items), 2)
+	assertEqual(t, string(v.Items[0][0]), "!x 1\n")
+	assertEqual(t, string(v.Items[1][0]), "c\n")
+	assertEqual(t, v.Name, "n")
+}
+
+func TestDecodeCompactMapping(t *testing.T) {
+	data := []byte(`
+servers:
+- name: web
+  port: 80
+  tags:
+  - a
+  - b
+-   name: db
+    port: 5432
+labels:
+  - k: 1
+    v: 2
+`)
+
+	type server struct {
+		Name string   `yaml:"name"`
+		Port int      `yaml:"port"`
+		Tags []string `yaml:"tags"`
+	}
+	var s struct {
+		Servers []server         `yaml:"servers"`
+		Labels  []map[string]int `yaml:"labels"`
+	}
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Servers, []server{{"web", 80, []string{"a", "b"}}, {"db", 5432, nil}})
+	assertEqual(t, s.Labels, []map[string]int{{"k": 1, "v": 2}})
+}
+
+func TestDecodeNestedSequence(t *testing.T) {
+	data := []byte(`
+- - a
+  - b
+-
+  - c
+`)
+
+	var a [][][]string
+	err := Unmarshal([]byte("- - - a\n    - b\n  - - c\n-\n  -\n    - d\n"), &a)
+	assertEqual(t, err, nil)
+	assertEqual(t, a, [][][]string{{{"a", "b"}, {"c"}}, {{"d"}}})
+
+	var l [][]string
+	err = Unmarshal(data, &l)
+	assertEqual(t, err, nil)
+	assertEqual(t, l, [][]string{{"a", "b"}, {"c"}})
+
+	out, err := Marshal([][]string{{"a"}, {}, {"b", "c"}})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "- - a\n- \n- - b\n  - c\n")
+}
+
+func TestDecodeIndentWidth(t *testing.T) {
+	data := []byte(`
+server:
+    host: localhost
+    ports:
+        - 80
+        - 443
+    routes:
+    -
+          path: /
+          script: |
+              echo hi
+name: x
+`)
+
+	type route struct {
+		Path   string `yaml:"path"`
+		Script string `yaml:"script"`
+	}
+	var s struct {
+		Server struct {
+			Host   string  `yaml:"host"`
+			Ports  []int   `yaml:"ports"`
+			Routes []route `yaml:"routes"`
+		} `yaml:"server"`
+		Name string `yaml:"name"`
+	}
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Server.Host, "localhost")
+	assertEqual(t, s.Server.Ports, []int{80, 443})
+	assertEqual(t, s.Server.Routes, []route{{"/", "echo hi\n"}})
+	assertEqual(t, s.Name, "x")
+}
+
+func TestDecodeTabs(t *testing.T) {
+	data := []byte("a:\n\tb: 1\n\tc: 2\n")
+
+	var m map[string]map[string]int
+	err := Unmarshal(data, &m)
+	assertEqual(t, err != nil && strings.Contains(err.Error(), "tab used for indentation at line 2"), true)
+
+	d := NewDecoderBytes(data)
+	d.ExpandTabs(4)
+	err = d.Decode(&m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]map[string]int{"a": {"b": 1, "c": 2}})
+}
+
+func TestDecodeEncoding(t *testing.T) {
+	var m map[string]string
+
+	err := Unmarshal([]byte("\xEF\xBB\xBFkey: value\n"), &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]string{"key": "value"})
+
+	m = nil
+	err = Unmarshal([]byte("\xFF\xFEk\x00:\x00 \x00\xE9\x00\n\x00"), &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]string{"k": "é"})
+
+	m = nil
+	err = Unmarshal([]byte("\x00k\x00:\x00 \x00v"), &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]string{"k": "v"})
+}
+
+func TestDecodeExplicitKey(t *testing.T) {
+	data := []byte(`
+? a
+: 1
+? |
+  multi
+  line
+: 2
+? empty
+b: 3
+`)
+
+	var m map[string]interface{}
+	err := Unmarshal(data, &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]interface{}{"a": 1, "multi\nline\n": 2, "empty": nil, "b": 3})
+
+	m = nil
+	err = Unmarshal([]byte("? - a\n  - b\n: 1\n?\n  k: v\n: 2\n"), &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]interface{}{`["a","b"]`: 1, `{"k":"v"}`: 2})
+
+	err = Unmarshal([]byte("?\n  ? - a\n  : 1\n: 2\n"), &m)
+	assertEqual(t, err != nil, true)
+}
+
+func TestDecodeColon(t *testing.T) {
+	data := []byte(`
+url: http://example.com:8080/#top # comment
+path: C:\dir
+http://host:80: ok
+`)
+
+	var m map[string]interface{}
+	err := Unmarshal(data, &m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m, map[string]interface{}{
+		"url":            "http://example.com:8080/#top",
+		"path":           `C:\dir`,
+		"http://host:80": "ok",
+	})
+
+	var s struct {
+		List []string `yaml:"list"`
+	}
+	err = Unmarshal([]byte("list:\n  - a:b\n  - 12:30\n"), &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.List, []string{"a:b", "12:30"})
+}
+
+func TestDecodeEmptyFlow(t *testing.T) {
+	type T struct {
+		Items  []string          `yaml:"items"`
+		Labels map[string]string `yaml:"labels"`
+		Lists  [][]int           `yaml:"lists"`
+	}
+	data := []byte("items: []\nlabels: {}\nlists:\n  - []\n")
+
+	var s T
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s, T{[]string{}, map[string]string{}, [][]int{{}}})
+
+	e := NewEncoder()
+	e.SetEmptyFlow(true)
+	out, err := e.Encode(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), string(data))
+
+	err = Unmarshal([]byte("items: a\n"), &s)
+	assertEqual(t, err != nil, true)
+}
+
+func TestDecodeMapKey(t *testing.T) {
+	data := []byte(`
+ports:
+  80: http
+  443: https
+hosts:
+  127.0.0.1: local
+flags:
+  true: yes
+`)
+
+	type T struct {
+		Ports map[int]string        `yaml:"ports"`
+		Hosts map[netip.Addr]string `yaml:"hosts"`
+		Flags map[bool]string       `yaml:"flags"`
+	}
+	var s T
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Ports, map[int]string{80: "http", 443: "https"})
+	assertEqual(t, s.Hosts, map[netip.Addr]string{netip.MustParseAddr("127.0.0.1"): "local"})
+	assertEqual(t, s.Flags, map[bool]string{true: "yes"})
+
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	var r T
+	err = Unmarshal(out, &r)
+	assertEqual(t, err, nil)
+	assertEqual(t, r, s)
+
+	var m map[int8]string
+	err = Unmarshal([]byte("300: x\n"), &m)
+	assertEqual(t, err != nil, true)
+}
+
+func TestDecodeMapSlice(t *testing.T) {
+	data := []byte("z: 1\na: x\nm: 2.5\n10: y\n")
+
+	var s MapSlice
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s, MapSlice{{"z", 1}, {"a", "x"}, {"m", 2.5}, {10, "y"}})
+
+	out, err := Marshal(s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "z: 1\na: x\nm: 2.5\n10: \"y\"\n")
+}
+
+func TestDecodeInterface(t *testing.T) {
+	data := []byte(`
+name: x
+extra:
+  a: 1
+  b:
+  - c
+  - d: 2.5
+    e: [] 
+  f: |
+    text
+list:
+- - 1
+  - 2
+- {}
+`)
+
+	var s struct {
+		Name  string      `yaml:"name"`
+		Extra interface{} `yaml:"extra"`
+		List  interface{} `yaml:"list"`
+	}
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, s.Extra, map[string]interface{}{
+		"a": 1,
+		"b": []interface{}{"c", map[string]interface{}{"d": 2.5, "e": []interface{}{}}},
+		"f": "text\n",
+	})
+	assertEqual(t, s.List, []interface{}{[]interface{}{1, 2}, map[string]interface{}{}})
+
+	var v interface{}
+	err = Unmarshal([]byte("- a\n- b\n"), &v)
+	assertEqual(t, err, nil)
+	assertEqual(t, v, []interface{}{"a", "b"})
+}
+
+func TestDecodeRawMessage(t *testing.T) {
+	data := []byte(`
+name: plugin
+config:
+    # plugin specific
+    url: http://x
+    retry:
+      - 1
+      - 2
+inline: |
+  text
+items:
+  - a: 1
+    b: 2
+`)
+
+	type T struct {
+		Name   string       `yaml:"name"`
+		Config RawMessage   `yaml:"config"`
+		Inline RawMessage   `yaml:"inline"`
+		Items  []RawMessage `yaml:"items"`
+	}
+	var s T
+	err := Unmarshal(data, &s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(s.Config), "# plugin specific\nurl: http://x\nretry:\n  - 1\n  - 2\n")
+	assertEqual(t, string(s.Inline), "|\n  text\n")
+	assertEqual(t, string(s.Items[0]), "a: 1\nb: 2\n")
+
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	var r T
+	err = Unmarshal(out, &r)
+	assertEqual(t, err, nil)
+	assertEqual(t, r, s)
+}
+
+func TestGet(t *testing.T) {
+	data := []byte(`
+server:
+  name: main
+  listeners:
+  - host: a
+    port: 80
+  -
+    host: b
+    port: 443
+    tls:
+      cert: x.pem
+other: 1
+`)
+
+	var port int
+	err := Get(data, "server.listeners[1].port", &port)
+	assertEqual(t, err, nil)
+	assertEqual(t, port, 443)
+
+	var tls map[string]string
+	err = Get(data, "server.listeners[1].tls", &tls)
+	assertEqual(t, err, nil)
+	assertEqual(t, tls, map[string]string{"cert": "x.pem"})
+
+	var other int
+	err = Get(data, "other", &other)
+	assertEqual(t, err, nil)
+	assertEqual(t, other, 1)
+
+	err = Get(data, "server.listeners[2]", &tls)
+	assertEqual(t, errors.Is(err, ErrNotFound), true)
+}
+
+func TestEncoderW(t *testing.T) {
+	var b strings.Builder
+	e := NewEncoderW(&b)
+	assertEqual(t, e.Encode(map[string]int{"a": 1}), nil)
+	assertEqual(t, b.String(), "a: 1\n")
+	assertEqual(t, e.Encode([]string{"x", "y"}), nil)
+	assertEqual(t, b.String(), "a: 1\n---\n- x\n- \"y\"\n")
+	assertEqual(t, e.Encode(make(chan int)) != nil, true)
+	assertEqual(t, e.EncodeAll([]interface{}{1, 2}), nil)
+	assertEqual(t, e.Flush(), nil)
+	assertEqual(t, b.String(), "a: 1\n---\n- x\n- \"y\"\n---\n1\n---\n2\n")
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	e = NewEncoderW(w, WithDocumentStart(true))
+	assertEqual(t, e.EncodeAll([]interface{}{"a", "b"}), nil)
+	assertEqual(t, buf.String(), "")
+	assertEqual(t, e.Flush(), nil)
+	assertEqual(t, buf.String(), "---\na\n---\nb\n")
+}
+
+func TestScanner(t *testing.T) {
+	data := []byte(`# config
+name: app # inline
+text: |
+  # not a comment
+tasks:
+- a
+`)
+
+	var events []string
+	s := NewScanner(data)
+	for s.Scan() {
+		e := s.Event()
+		events = append(events, fmt.Sprintf("%v %q %d:%d", e.Kind, e.Value, e.Line, e.Column))
+	}
+	assertEqual(t, s.Err(), nil)
+	assertEqual(t, events, []string{
+		`DocumentStart "" 1:1`,
+		`Comment " config" 1:1`,
+		`MappingStart "" 2:1`,
+		`Key "name" 2:1`,
+		`Scalar "app" 2:7`,
+		`Comment " inline" 2:11`,
+		`Key "text" 3:1`,
+		`Scalar "# not a comment\n" 3:7`,
+		`Key "tasks" 5:1`,
+		`SequenceStart "" 6:1`,
+		`Scalar "a" 6:3`,
+		`SequenceEnd "" 7:1`,
+		`MappingEnd "" 7:1`,
+		`DocumentEnd "" 7:1`,
+	})
+
+	// Every document, with the events preceding an error.
+	events = nil
+	s = NewScanner([]byte("a: &x 1\n---\nb:\n  - c\n  - \"d\n"))
+	for s.Scan() {
+		e := s.Event()
+		events = append(events, fmt.Sprintf("%v %q %q %d:%d", e.Kind, e.Anchor, e.Value, e.Line, e.Column))
+	}
+	assertEqual(t, s.Err() != nil, true)
+	assertEqual(t, events, []string{
+		`DocumentStart "" "" 1:1`,
+		`MappingStart "" "" 1:1`,
+		`Key "" "a" 1:1`,
+		`Scalar "x" "1" 1:4`,
+		`MappingEnd "" "" 2:1`,
+		`DocumentEnd "" "" 3:1`,
+		`DocumentStart "" "" 3:1`,
+		`MappingStart "" "" 3:1`,
+		`Key "" "b" 3:1`,
+		`SequenceStart "" "" 4:3`,
+		`Scalar "" "c" 4:5`,
+	})
+}
+
+func TestStream(t *testing.T) {
+	r := strings.NewReader(`# first
+name: a
+---
+name: b
+...
+%YAML 1.2
+---
+name: c
+`)
+
+	docs, errc := NewDecoder(r).Stream(context.Background())
+	var names []string
+	var lines []int
+	for doc := range docs {
+		var v struct {
+			Name string `yaml:"name"`
+		}
+		assertEqual(t, doc.Decode(&v), nil)
+		names = append(names, v.Name)
+		lines = append(lines, doc.Line)
+	}
+	assertEqual(t, <-errc, nil)
+	assertEqual(t, names, []string{"a", "b", "c"})
+	assertEqual(t, lines, []int{1, 3, 6})
+}
+
+func TestStreamConcurrent(t *testing.T) {
+	var text strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&text, "---\nname: doc%d\nkeys:\n  - a\n  - b\n", i)
+	}
+	// The decoder of the stream has its own state, which the
+	// documents must not share.
+	d := NewDecoder(strings.NewReader("name: first\n" + text.String()))
+	var first interface{}
+	assertEqual(t, d.Decode(&first), nil)
+
+	docs, errc := d.Stream(context.Background())
+	var all []Document
+	for doc := range docs {
+		all = append(all, doc)
+	}
+	assertEqual(t, <-errc, nil)
+
+	// Each document is decoded twice, at the same time.
+	names := make([]string, 2*len(all))
+	done := make(chan bool)
+	for i := range names {
+		go func(i int) {
+			var v struct {
+				Name string   `yaml:"name"`
+				Keys []string `yaml:"keys"`
+			}
+			if err := all[i/2].Decode(&v); err != nil {
+				t.Error(err)
+			}
+			names[i] = v.Name
+			done <- true
+		}(i)
+	}
+	for range names {
+		<-done
+	}
+	for i, name := range names {
+		assertEqual(t, name, fmt.Sprintf("doc%d", i/2))
+	}
+}
+
+func TestStreamRest(t *testing.T) {
+	// The documents streamed follow the one decoded, with the same
+	// lines whether the decoder reads bytes or a reader.
+	const text = "a: 1\n---\nb: 2\n...\n---\nc: 3\n"
+	for _, d := range []*Decoder{
+		NewDecoderBytes([]byte(text)),
+		NewDecoder(strings.NewReader(text)),
+	} {
+		var first map[string]int
+		assertEqual(t, d.Decode(&first), nil)
+		assertEqual(t, first, map[string]int{"a": 1})
+
+		docs, errc := d.Stream(context.Background())
+		var data []string
+		var lines []int
+		for doc := range docs {
+			data = append(data, string(doc.Data))
+			lines = append(lines, doc.Line)
+		}
+		assertEqual(t, <-errc, nil)
+		assertEqual(t, data, []string{"b: 2\n...\n", "---\nc: 3\n"})
+		assertEqual(t, lines, []int{3, 5})
+	}
+}
+
+func TestMarshalAll(t *testing.T) {
+	out, err := MarshalAll([]interface{}{
+		map[string]string{"kind": "Service"},
+		map[string]string{"kind": "Deployment"},
+	})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "kind: Service\n---\nkind: Deployment\n")
+}
+
+func TestDocumentMarkers(t *testing.T) {
+	e := NewEncoder()
+	e.SetDocumentStart(true)
+	e.SetDocumentEnd(true)
+	out, err := e.EncodeAll([]interface{}{map[string]int{"a": 1}, map[string]int{"b": 2}})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "---\na: 1\n...\n---\nb: 2\n...\n")
+}
+
+func TestEncodeIndent(t *testing.T) {
+	v := MapSlice{{"spec", MapSlice{
+		{"ports", []MapSlice{{{"port", 80}}}},
+		{"text", "  indented\n"},
+	}}}
+	e := NewEncoder()
+	e.SetIndent(4)
+	out, err := e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "spec:\n    ports:\n        - port: 80\n    text: |4\n          indented\n")
+
+	var s struct {
+		Spec struct {
+			Ports []struct {
+				Port int `yaml:"port"`
+			} `yaml:"ports"`
+			Text string `yaml:"text"`
+		} `yaml:"spec"`
+	}
+	assertEqual(t, Unmarshal(out, &s), nil)
+	assertEqual(t, s.Spec.Ports[0].Port, 80)
+	assertEqual(t, s.Spec.Text, "  indented\n")
+}
+
+func TestEncodeSortedKeys(t *testing.T) {
+	out, err := Marshal(map[string]int{"b": 1, "10": 2, "a": 3, "9": 4, "-1.5": 5})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "\"-1.5\": 5\n\"9\": 4\n\"10\": 2\na: 3\nb: 1\n")
+}
+
+func TestEncodeCanonical(t *testing.T) {
+	e := NewEncoder()
+	e.SetCanonical(true)
+	out, err := e.Encode(MapSlice{{"b", 1.0}, {"a", "x\r\ny"}, {"c", math.Copysign(0, -1)}})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: |-\n  x\n  y\nb: 1.0\nc: 0.0\n")
+
+	type T struct {
+		A string `yaml:"a,quoted"`
+		B string `yaml:"b,folded"`
+	}
+	for _, v := range []interface{}{T{"x", "a long line"}, MapSlice{{"b", "a long line"}, {"a", "x"}}} {
+		e = NewEncoder()
+		e.SetCanonical(true)
+		e.SetQuoteAll(true)
+		e.SetLineWidth(8)
+		out, err = e.Encode(v)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(out), "a: x\nb: a long line\n")
+	}
+}
+
+func TestEncodeFieldStyle(t *testing.T) {
+	type T struct {
+		Cmd  string   `yaml:"cmd,literal"`
+		Desc string   `yaml:"desc,folded"`
+		Tags []string `yaml:"tags"`
+		Ver  string   `yaml:"ver,quoted"`
+	}
+	s := T{"make all", "a long\nline\n", []string{"x", "y"}, "1.10"}
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "cmd: |-\n  make all\ndesc: >\n  a long\n\n  line\ntags:\n  - x\n  - \"y\"\nver: \"1.10\"\n")
+
+	var r T
+	assertEqual(t, Unmarshal(out, &r), nil)
+	assertEqual(t, r, s)
+}
+
+func TestQuoted(t *testing.T) {
+	e := NewEncoder()
+	e.SetQuoteAll(true)
+	v := MapSlice{{"country", "no"}, {"version", "1.10"}, {"text", "a #b\n"}}
+	out, err := e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "country: \"no\"\nversion: \"1.10\"\ntext: \"a #b\\n\"\n")
+
+	var m map[string]interface{}
+	assertEqual(t, Unmarshal(out, &m), nil)
+	assertEqual(t, m, map[string]interface{}{"country": "no", "version": "1.10", "text": "a #b\n"})
+
+	var s struct{ A, B string }
+	assertEqual(t, Unmarshal([]byte("A: 'it''s' # c\nB: \"x\"\n"), &s), nil)
+	assertEqual(t, s.A, "it's")
+	assertEqual(t, s.B, "x")
+}
+
+func TestEncodeAmbiguous(t *testing.T) {
+	v := MapSlice{{"a", "no"}, {"b", "1.0"}, {"c", "null"}, {"d", "-foo"},
+		{"e", "08:30"}, {"f", ""}, {"g", "a #b"}, {"h", "plain text"}}
+	out, err := Marshal(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `a: "no"
+b: "1.0"
+c: "null"
+d: "-foo"
+e: "08:30"
+f: ""
+g: "a #b"
+h: plain text
+`)
+
+	var m map[string]interface{}
+	assertEqual(t, Unmarshal(out, &m), nil)
+	for _, item := range v {
+		assertEqual(t, m[item.Key.(string)], item.Value)
+	}
+}
+
+func TestEncodeLineWidth(t *testing.T) {
+	text := "the quick brown fox jumps over the lazy dog"
+	e := NewEncoder()
+	e.SetLineWidth(20)
+	out, err := e.Encode(map[string]string{"text": text})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "text: >-\n  the quick brown\n  fox jumps over the\n  lazy dog\n")
+
+	var m map[string]string
+	assertEqual(t, Unmarshal(out, &m), nil)
+	assertEqual(t, m["text"], text)
+}
+
+func TestEncodeOmit(t *testing.T) {
+	type Limits struct{ CPU, Memory int }
+	s := struct {
+		Name    string   `yaml:"name,omitempty"`
+		Port    int      `yaml:"port,omitempty"`
+		Debug   bool     `yaml:"debug,omitempty"`
+		Ratio   float64  `yaml:"ratio,omitempty"`
+		Tags    []string `yaml:"tags,omitempty"`
+		Limits  Limits   `yaml:"limits,omitzero"`
+		Default Limits   `yaml:"default,omitempty"`
+	}{Name: "app"}
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "name: app\ndefault:\n  CPU: 0\n  Memory: 0\n")
+}
+
+type optional struct {
+	Set   bool
+	Value string
+}
+
+func (o *optional) IsZero() bool { return !o.Set }
+
+func TestEncodeIsZeroer(t *testing.T) {
+	s := struct {
+		A optional `yaml:"a,omitempty"`
+		B optional `yaml:"b,omitzero"`
+		C optional `yaml:"c,omitzero"`
+	}{C: optional{Set: true}}
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "c:\n  Set: true\n  Value: \"\"\n")
+}
+
+func TestEncodeNodeComments(t *testing.T) {
+	scalar := func(v string) *Node { return &Node{Kind: ScalarNode, Value: v} }
+	port := scalar("80")
+	port.LineComment = "default"
+	name := scalar("name")
+	name.HeadComment = "the service"
+	root := Node{
+		Kind:        MappingNode,
+		HeadComment: "generated",
+		Content: []*Node{
+			name, scalar("web"),
+			scalar("ports"), {Kind: SequenceNode, Content: []*Node{port, scalar("443")}},
+		},
+		FootComment: "end",
+	}
+	out, err := Marshal(root)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `# generated
+# the service
+name: web
+ports:
+  - 80 # default
+  - 443
+# end
+`)
+
+	var n Node
+	assertEqual(t, Unmarshal(out, &n), nil)
+	assertEqual(t, n.Content[3].Content[0].Value, "80")
+}
+
+func TestNodeRootTag(t *testing.T) {
+	for _, data := range []string{"!!str 1\n", "# c\n!point 1,2\n", "&a !!str 1\n", "!set\na: 1\n"} {
+		var n Node
+		assertEqual(t, Unmarshal([]byte(data), &n), nil)
+		out, err := Marshal(n)
+		assertEqual(t, err, nil)
+		var r Node
+		assertEqual(t, Unmarshal(out, &r), nil)
+		assertEqual(t, r.Tag, n.Tag)
+		assertEqual(t, r.Anchor, n.Anchor)
+		assertEqual(t, r.Value, n.Value)
+		assertEqual(t, len(r.Content), len(n.Content))
+	}
+
+	var v interface{}
+	assertEqual(t, Unmarshal([]byte("!!str 1\n"), &v), nil)
+	assertEqual(t, v, "1")
+}
+
+func TestAnchors(t *testing.T) {
+	type Server struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	type Config struct {
+		Primary *Server   `yaml:"primary"`
+		Backup  *Server   `yaml:"backup"`
+		All     []*Server `yaml:"all"`
+	}
+	s := &Server{"example.com", 80}
+	c := Config{s, s, []*Server{s}}
+
+	e := NewEncoder()
+	e.SetAnchors(true)
+	out, err := e.Encode(&c)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "primary: &a1\n  host: example.com\n  port: 80\nbackup: *a1\nall:\n  - *a1\n")
+
+	var r Config
+	assertEqual(t, Unmarshal(out, &r), nil)
+	assertEqual(t, *r.Primary, *s)
+	assertEqual(t, r.Backup == r.Primary && r.All[0] == r.Primary, true)
+
+	var m map[string]interface{}
+	assertEqual(t, Unmarshal([]byte("a: &x 1\nb: *x\nc:\n  - &y\n    k: v\n  - *y\n"), &m), nil)
+	assertEqual(t, m["b"], 1)
+	assertEqual(t, m["c"], []interface{}{map[string]interface{}{"k": "v"}, map[string]interface{}{"k": "v"}})
+	assertEqual(t, Unmarshal([]byte("a: !!str &x 1\nb: *x\n"), &m), nil)
+	assertEqual(t, m["b"], "1")
+
+	d := NewDecoderBytes([]byte("a: &x 1\n---\nb: *x\n"))
+	assertEqual(t, d.Decode(&m), nil)
+	assertEqual(t, d.Decode(&m) != nil, true)
+}
+
+func TestNodeAnchors(t *testing.T) {
+	data := "base: &b\n  x: 1\nother: *b\nlist:\n  - &e\n    k: v\n  - *e\n  - &s \"2\"\n  - *s\n"
+	var n Node
+	assertEqual(t, Unmarshal([]byte(data), &n), nil)
+	base, other := n.Content[1], n.Content[3]
+	assertEqual(t, base.Anchor, "b")
+	assertEqual(t, other.Alias, base)
+	assertEqual(t, other.Kind, MappingNode)
+	assertEqual(t, other.Content[1].Value, "1")
+	assertEqual(t, []int{other.Line, other.Column}, []int{3, 8})
+
+	out, err := Marshal(n)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), data)
+
+	// A changed alias is written in full.
+	other.Content = []*Node{{Kind: ScalarNode, Value: "x"}, {Kind: ScalarNode, Value: "2"}}
+	out, err = Marshal(n)
+	assertEqual(t, err, nil)
+	assertEqual(t, strings.HasPrefix(string(out), "base: &b\n  x: 1\nother:\n  x: 2\n"), true)
+
+	err = Unmarshal([]byte("a: *x\n"), &n)
+	assertEqual(t, err.Error(), "a undefined anchor x at line 1, column 4")
+}
+
+func TestEncodeCycle(t *testing.T) {
+	type Node struct {
+		Name string `yaml:"name"`
+		Next *Node  `yaml:"next"`
+	}
+	n := &Node{Name: "a"}
+	n.Next = &Node{Name: "b", Next: n}
+
+	_, err := Marshal(n)
+	assertEqual(t, err, errors.New("cyclic reference via field next"))
+
+	e := NewEncoder()
+	e.SetAnchors(true)
+	out, err := e.Encode(n)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "&a1\nname: a\nnext:\n  name: b\n  next: *a1\n")
+
+	var r Node
+	assertEqual(t, Unmarshal(out, &r), nil)
+	assertEqual(t, r.Next.Next, &r)
+}
+
+func TestEncodeNull(t *testing.T) {
+	type T struct {
+		Port  *int                   `yaml:"port"`
+		Extra map[string]interface{} `yaml:"extra"`
+		List  []*int                 `yaml:"list"`
+	}
+	s := T{Extra: map[string]interface{}{"a": nil}, List: []*int{nil}}
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "port: null\nextra:\n  a: null\nlist:\n  - null\n")
+
+	port := 1
+	r := T{Port: &port}
+	assertEqual(t, Unmarshal(out, &r), nil)
+	assertEqual(t, r.Port, (*int)(nil))
+	assertEqual(t, r.List, []*int{nil})
+}
+
+func TestEncodeNilStyle(t *testing.T) {
+	s := struct {
+		List []int         `yaml:"list"`
+		Map  map[string]int `yaml:"map"`
+		Port int            `yaml:"port"`
+	}{Port: 80}
+
+	for style, want := range map[NilStyle]string{
+		NilDefault: "list:\nmap:\nport: 80\n",
+		NilNull:    "list: null\nmap: null\nport: 80\n",
+		NilEmpty:   "list: []\nmap: {}\nport: 80\n",
+		NilOmit:    "port: 80\n",
+	} {
+		e := NewEncoder()
+		e.SetNilStyle(style)
+		out, err := e.Encode(&s)
+		assertEqual(t, err, nil)
+		assertEqual(t, string(out), want)
+	}
+}
+
+func TestEncodeUint(t *testing.T) {
+	s := struct {
+		Port  uint16 `yaml:"port"`
+		Quota uint64 `yaml:"quota"`
+		Level int8   `yaml:"level"`
+	}{8080, math.MaxUint64, -3}
+	out, err := Marshal(&s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "port: 8080\nquota: 18446744073709551615\nlevel: -3\n")
+}
+
+func TestEncodeInterface(t *testing.T) {
+	data := []byte("name: app\nports:\n  - 80\n  - 443\nlimits:\n  cpu: 0.5\n  debug: true\n")
+	var v interface{}
+	assertEqual(t, Unmarshal(data, &v), nil)
+
+	out, err := Marshal(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "limits:\n  cpu: 0.5\n  debug: true\nname: app\nports:\n  - 80\n  - 443\n")
+}
+
+func TestEncodeInterfaceKeys(t *testing.T) {
+	v := map[interface{}]interface{}{
+		1: "a", "1": "b", true: "c",
+		"x": map[interface{}]interface{}{"y": 1},
+	}
+	out, err := Marshal(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "\"1\": b\n1: a\ntrue: c\nx:\n  y: 1\n")
+
+	_, err = Marshal(map[interface{}]int{nil: 1})
+	assertEqual(t, err, errors.New("unsupported nil map key"))
+}
+
+func TestEncodeTime(t *testing.T) {
+	type T struct {
+		At  time.Time     `yaml:"at"`
+		For time.Duration `yaml:"for"`
+	}
+	v := T{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), 90 * time.Minute}
+	out, err := Marshal(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "at: 2024-01-02T03:04:05Z\nfor: 1h30m0s\n")
+
+	var w T
+	assertEqual(t, Unmarshal(out, &w), nil)
+	assertEqual(t, w, v)
+
+	e := NewEncoder()
+	e.SetTimeLayout("2006-01-02")
+	e.SetDurationStyle(DurationSeconds)
+	out, err = e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "at: 2024-01-02\nfor: 5400\n")
+
+	w = T{}
+	assertEqual(t, Unmarshal(out, &w), nil)
+	assertEqual(t, w, T{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), 90 * time.Minute})
+}
+
+func TestEncodeFloat(t *testing.T) {
+	x := 0.1
+	v := MapSlice{{"a", 1e6}, {"b", x + 0.2}, {"c", float32(0.1)}, {"d", 2.0}}
+	out, err := Marshal(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: 1e+06\nb: 0.30000000000000004\nc: 0.1\nd: 2\n")
+
+	e := NewEncoder()
+	e.SetFloatFormat('f', -1)
+	e.SetFloatPoint(true)
+	out, err = e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: 1000000.0\nb: 0.30000000000000004\nc: 0.1\nd: 2.0\n")
+
+	e = NewEncoder()
+	e.SetFloatFormat('g', 6)
+	e.SetFloatPoint(true)
+	out, err = e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: 1.0e+06\nb: 0.3\nc: 0.1\nd: 2.0\n")
+}
+
+func TestEncodeEscape(t *testing.T) {
+	v := MapSlice{{"a", "x\x00y"}, {"b", "l1\nl2\x1b"}, {"c", "café"}, {"é", 1}}
+	e := NewEncoder()
+	e.SetEscape(EscapeControl)
+	out, err := e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: \"x\\x00y\"\nb: \"l1\\nl2\\x1b\"\nc: café\né: 1\n")
+
+	e = NewEncoder()
+	e.SetEscape(EscapeASCII)
+	out, err = e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: \"x\\x00y\"\nb: \"l1\\nl2\\x1b\"\nc: \"caf\\u00e9\"\n\"\\u00e9\": 1\n")
+
+	var w map[string]interface{}
+	assertEqual(t, Unmarshal(out, &w), nil)
+	assertEqual(t, w, map[string]interface{}{"a": "x\x00y", "b": "l1\nl2\x1b", "c": "café", "é": 1})
+}
+
+func TestFieldTag(t *testing.T) {
+	type T struct {
+		A string `yaml:",omitempty"`
+		B int    `yaml:"b, omitempty,,unknown"`
+		C string `yaml:"-"`
+		D string `yaml:"-,"`
+	}
+	out, err := Marshal(T{B: 1, C: "c", D: "d"})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "b: 1\n\"-\": d\n")
+
+	var v T
+	assertEqual(t, Unmarshal([]byte("A: a\nb: 2\n"), &v), nil)
+	assertEqual(t, v, T{A: "a", B: 2})
+	err = Unmarshal([]byte("C: c\n"), &v)
+	assertEqual(t, err != nil && strings.Contains(err.Error(), "undefined field C"), true)
+}
+
+func TestWriteFileMode(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	assertEqual(t, WriteFileMode(name, map[string]int{"a": 1}, 0600), nil)
+	assertEqual(t, WriteFileMode(name, map[string]int{"b": 2}, 0600), nil)
+
+	data, err := os.ReadFile(name)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(data), "b: 2\n")
+	fi, err := os.Stat(name)
+	assertEqual(t, err, nil)
+	assertEqual(t, fi.Mode().Perm(), os.FileMode(0600))
+
+	entries, _ := os.ReadDir(filepath.Dir(name))
+	assertEqual(t, len(entries), 1)
+}
+
+func TestReadFileFS(t *testing.T) {
+	fsys := fstest.MapFS{"conf/app.yaml": {Data: []byte("name: app\n")}}
+	var v struct {
+		Name string `yaml:"name"`
+	}
+	assertEqual(t, ReadFileFS(fsys, "conf/app.yaml", &v), nil)
+	assertEqual(t, v.Name, "app")
+	assertEqual(t, errors.Is(ReadFileFS(fsys, "missing.yaml", &v), fs.ErrNotExist), true)
+}
+
+func TestOptions(t *testing.T) {
+	v := map[string]interface{}{"a": []int{1}, "b": nil}
+	e := NewEncoder(WithIndent(4), WithNilStyle(NilNull), WithDocumentStart(true))
+	out, err := e.Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "---\na:\n    - 1\nb: null\n")
+
+	type T struct {
+		Z int `yaml:"z"`
+		A int `yaml:"a"`
+		N int `yaml:"10"`
+	}
+	e = NewEncoder(WithSortKeys(true))
+	out, err = e.Encode(T{1, 2, 3})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "\"10\": 3\na: 2\nz: 1\n")
+	out, err = NewEncoder(WithSortKeys(true)).Encode(MapSlice{{"z", 1}, {"a", 2}})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a: 2\nz: 1\n")
+
+	var w map[string]interface{}
+	d := NewDecoderBytes([]byte("a: yes\n"), WithSchema(FailsafeSchema), WithStrict(true))
+	assertEqual(t, d.Decode(&w), nil)
+	assertEqual(t, w, map[string]interface{}{"a": "yes"})
+
+	// The options of the encoder are ignored by a decoder, and the reverse.
+	d = NewDecoderBytes([]byte("a: yes\n"), WithIndent(4))
+	assertEqual(t, d.Decode(&w), nil)
+	out, err = NewEncoder(WithStrict(true)).Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a:\n  - 1\nb: null\n")
+}
+
+func TestNewDecoderReader(t *testing.T) {
+	var v map[string]int
+	d := NewDecoder(strings.NewReader("a: 1\nb: 2\n"))
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v, map[string]int{"a": 1, "b": 2})
+
+	d = NewDecoder(iotest.ErrReader(errors.New("broken")))
+	assertEqual(t, d.Decode(&v), errors.New("broken"))
+
+	// One document at a time.
+	data := "a: 1\n---\n# c\nb: 2\n...\n---\nc: 3\n"
+	d = NewDecoder(iotest.OneByteReader(strings.NewReader(data)))
+	var docs []map[string]int
+	for {
+		var m map[string]int
+		err := d.Decode(&m)
+		if err == io.EOF {
+			break
+		}
+		assertEqual(t, err, nil)
+		docs = append(docs, m)
+	}
+	assertEqual(t, docs, []map[string]int{{"a": 1}, {"b": 2}, {"c": 3}})
+	assertEqual(t, d.InputOffset(), int64(len(data)))
+	assertEqual(t, NewDecoder(strings.NewReader("# none\n")).Decode(&v), io.EOF)
+}
+
+func TestValid(t *testing.T) {
+	assertEqual(t, Valid([]byte("a: 1\nb:\n  - x\n  - y\n")), true)
+	assertEqual(t, Valid([]byte("a: 1\n\tb: 2\n")), false)
+	assertEqual(t, Valid([]byte("a: [1, {b: \"]\"}]\n")), true)
+	assertEqual(t, Valid([]byte("a: [\n")), false)
+	assertEqual(t, Valid([]byte("a: {b: 1]\n")), false)
+	assertEqual(t, Valid([]byte("a: [1] x\n")), false)
+	assertEqual(t, Valid([]byte("- ]\n")), false)
+	assertEqual(t, Valid([]byte("a: 1\n---\nb: [\n")), false)
+
+	d := NewDecoderBytes([]byte("a: 1\n---\nb: 2\n"))
+	assertEqual(t, d.Skip(), nil)
+	var m map[string]int
+	assertEqual(t, d.Decode(&m), nil)
+	assertEqual(t, m, map[string]int{"b": 2})
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	var v struct {
+		Name    string            `yaml:"name"`
+		Port    int               `yaml:"port"`
+		Labels  map[string]string `yaml:"labels"`
+		Version string            `yaml:"version"`
+	}
+	data := "name: app\nport: 80\nlabels:\n  a: x\nversion: \"1.10\"\n"
+	assertEqual(t, UnmarshalStrict([]byte(data), &v), nil)
+	assertEqual(t, v.Version, "1.10")
+	assertEqual(t, UnmarshalStrict([]byte("version: |\n  1.10\n"), &v), nil)
+	assertEqual(t, UnmarshalStrict([]byte("version: !!str 1.10\n"), &v), nil)
+
+	for _, data := range []string{
+		"name: a\nname: b\n",
+		"labels:\n  a: x\n  a: y\n",
+		"port: \"80\"\n",
+		"version: 1.10\n",
+		"name: true\n",
+	} {
+		assertEqual(t, Unmarshal([]byte(data), &v), nil)
+		assertEqual(t, UnmarshalStrict([]byte(data), &v) != nil, true)
+	}
+}
+
+func TestDecodeGeneric(t *testing.T) {
+	type Config struct {
+		Name string `yaml:"name"`
+	}
+	c, err := Decode[Config]([]byte("name: app\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, c, Config{"app"})
+
+	name := filepath.Join(t.TempDir(), "ports.yaml")
+	os.WriteFile(name, []byte("- 80\n- 443\n"), 0666)
+	ports, err := DecodeFile[[]int](name)
+	assertEqual(t, err, nil)
+	assertEqual(t, ports, []int{80, 443})
+}
+
+func TestInputOffset(t *testing.T) {
+	data := "---\ntitle: Hello\n---\n# Body\n"
+	var v map[string]string
+	d := NewDecoderBytes([]byte(data))
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v, map[string]string{"title": "Hello"})
+	assertEqual(t, d.InputOffset(), int64(21))
+	assertEqual(t, string(d.Buffered()), "# Body\n")
+
+	d = NewDecoderBytes([]byte("a: 1\n...\nb: 2\n--- c: 3\n"))
+	v = nil
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v, map[string]string{"a": "1"})
+	assertEqual(t, string(d.Buffered()), "b: 2\n--- c: 3\n")
+	v = nil
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v, map[string]string{"b": "2"})
+	assertEqual(t, string(d.Buffered()), "--- c: 3\n")
+}
+
+func TestMore(t *testing.T) {
+	for _, d := range []*Decoder{
+		NewDecoderBytes([]byte("a: 1\n---\nb: 2\n# end\n")),
+		NewDecoder(strings.NewReader("a: 1\n---\nb: 2\n# end\n")),
+	} {
+		var docs []map[string]int
+		for {
+			var v map[string]int
+			assertEqual(t, d.Decode(&v), nil)
+			docs = append(docs, v)
+			if !d.More() {
+				break
+			}
+		}
+		assertEqual(t, docs, []map[string]int{{"a": 1}, {"b": 2}})
+	}
+}
+
+func TestReaderPositions(t *testing.T) {
+	// A decoder reading a reader reports the positions of the stream,
+	// as one decoding bytes does.
+	for _, in := range []string{"a: 1\n...\nb: 2\n", "a: 1\n---\nb: 2\n", "a: 1\n...\n---\nb: 2\n", "a: 1\n---\n---\nb: 2\n"} {
+		var offsets [2][]int64
+		var buffered [2][]string
+		for i, d := range []*Decoder{NewDecoderBytes([]byte(in)), NewDecoder(strings.NewReader(in))} {
+			for j := 0; j < 2; j++ {
+				var v map[string]int
+				assertEqual(t, d.Decode(&v), nil)
+				offsets[i] = append(offsets[i], d.InputOffset())
+				buffered[i] = append(buffered[i], string(d.Buffered()))
+			}
+		}
+		assertEqual(t, offsets[1], offsets[0])
+		assertEqual(t, buffered[1], buffered[0])
+	}
+
+	type doc struct {
+		A int `yaml:"a"`
+		B int `yaml:"b"`
+	}
+	data := "a: 1\n---\n# b\nb: x\n"
+	for _, d := range []*Decoder{NewDecoderBytes([]byte(data)), NewDecoder(strings.NewReader(data))} {
+		var v doc
+		assertEqual(t, d.Decode(&v), nil)
+		var te *TypeError
+		assertEqual(t, errors.As(d.Decode(&v), &te), true)
+		assertEqual(t, [3]int{te.Line, te.Column, te.Offset}, [3]int{4, 4, 16})
+	}
+	data = "a: 1\n...\nb: 'x\n"
+	for _, d := range []*Decoder{NewDecoderBytes([]byte(data)), NewDecoder(strings.NewReader(data))} {
+		var v doc
+		assertEqual(t, d.Decode(&v), nil)
+		var se *SyntaxError
+		assertEqual(t, errors.As(d.Decode(&v), &se), true)
+		assertEqual(t, [2]int{se.Line, se.Offset}, [2]int{3, 11})
+	}
+}
+
+func TestAppendMarshal(t *testing.T) {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, "# config\n"...)
+	out, err := AppendMarshal(buf, map[string]int{"a": 1})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "# config\na: 1\n")
+	assertEqual(t, &out[0] == &buf[0], true)
+
+	_, err = AppendMarshal(buf, make(chan int))
+	assertEqual(t, err != nil, true)
+}
+
+func TestMarshalPool(t *testing.T) {
+	a, err := Marshal(map[string]int{"a": 1})
+	assertEqual(t, err, nil)
+	_, err = Marshal(make(chan int))
+	assertEqual(t, err != nil, true)
+	b, err := Marshal(map[string]int{"b": 2})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(a), "a: 1\n")
+	assertEqual(t, string(b), "b: 2\n")
+
+	var v map[string]int
+	assertEqual(t, Unmarshal(a, &v), nil)
+	assertEqual(t, Unmarshal([]byte("a: x\n"), &v) != nil, true)
+	v = nil
+	assertEqual(t, Unmarshal(b, &v), nil)
+	assertEqual(t, v, map[string]int{"b": 2})
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	v := map[string]interface{}{"name": "app", "ports": []int{80, 443}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Marshal(v)
+	}
+}
+
+func BenchmarkDecodeStructs(b *testing.B) {
+	var buf bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, "- name: app%d\n  image: nginx\n  port: %d\n", i, 8000+i)
+	}
+	data := buf.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var v []struct {
+			Name  string `yaml:"name"`
+			Image string `yaml:"image"`
+			Port  int    `yaml:"port"`
+		}
+		Unmarshal(data, &v)
+	}
+}
+
+func BenchmarkEncodeStructs(b *testing.B) {
+	type Service struct {
+		Name  string `yaml:"name"`
+		Image string `yaml:"image"`
+		Port  int    `yaml:"port"`
+	}
+	v := make([]Service, 1000)
+	for i := range v {
+		v[i] = Service{fmt.Sprintf("app%d", i), "nginx", 8000 + i}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Marshal(v)
+	}
+}
+
+func TestDecodeRecursiveType(t *testing.T) {
+	type Tree struct {
+		Name     string  `yaml:"name"`
+		Children []*Tree `yaml:"children"`
+	}
+	var v Tree
+	data := "name: a\nchildren:\n  - name: b\n    children:\n      - name: c\n"
+	assertEqual(t, Unmarshal([]byte(data), &v), nil)
+	assertEqual(t, v.Children[0].Children[0].Name, "c")
+}
+
+type panicText struct{}
+
+func (panicText) MarshalText() ([]byte, error) {
+	panic("broken")
+}
+
+func TestForeignPanic(t *testing.T) {
+	defer func() {
+		assertEqual(t, recover(), "broken")
+	}()
+	Marshal(map[panicText]int{{}: 1})
+	t.Error("no panic")
+}
+
+func TestLineTable(t *testing.T) {
+	var v map[string]string
+	data := "a: 'x #y' # c\nb: z # d\n"
+	assertEqual(t, Unmarshal([]byte(data), &v), nil)
+	assertEqual(t, v, map[string]string{"a": "x #y", "b": "z"})
+
+	var n Node
+	assertEqual(t, Unmarshal([]byte("# c\na:\n  - x\n  - y\n"), &n), nil)
+	y := n.Content[1].Content[1]
+	assertEqual(t, [2]int{y.Line, y.Column}, [2]int{4, 5})
+}
+
+func TestZeroCopy(t *testing.T) {
+	data := []byte("name: app\nimage: \"nginx\"\nport: 80\n")
+	var v struct {
+		Name  string `yaml:"name"`
+		Image string `yaml:"image"`
+		Port  int    `yaml:"port"`
+	}
+	assertEqual(t, NewDecoderBytes(data, WithZeroCopy(true)).Decode(&v), nil)
+	assertEqual(t, v.Name, "app")
+	assertEqual(t, v.Image, "nginx")
+
+	copy(data[6:], "xyz")
+	assertEqual(t, v.Name, "xyz")
+}
+
+func TestIntern(t *testing.T) {
+	var v []map[string]string
+	assertEqual(t, Unmarshal([]byte("- name: a\n- name: a\n"), &v), nil)
+	var k [2]string
+	for i, m := range v {
+		for key := range m {
+			k[i] = key
+		}
+	}
+	assertEqual(t, unsafe.StringData(k[0]) == unsafe.StringData(k[1]), true)
+	assertEqual(t, unsafe.StringData(v[0]["name"]) == unsafe.StringData(v[1]["name"]), true)
+}
+
+func TestLimits(t *testing.T) {
+	var v interface{}
+	deep := strings.Repeat("- ", 20) + "x\n"
+	assertEqual(t, Unmarshal([]byte(deep), &v), nil)
+	d := NewDecoderBytes([]byte(deep), WithLimits(Limits{MaxDepth: 10}))
+	assertEqual(t, d.Decode(&v) != nil, true)
+
+	keys := "a: 1\nb: 2\nc: 3\n"
+	d = NewDecoderBytes([]byte(keys), WithLimits(Limits{MaxKeys: 2}))
+	assertEqual(t, d.Decode(&v) != nil, true)
+	d = NewDecoderBytes([]byte(keys), WithLimits(Limits{MaxDocumentSize: 10}))
+	assertEqual(t, d.Decode(&v) != nil, true)
+	d = NewDecoder(strings.NewReader(keys), WithLimits(Limits{MaxDocumentSize: 10}))
+	assertEqual(t, d.Decode(&v) != nil, true)
+
+	aliases := "a: &x [1]\nb: *x\nc: *x\n"
+	d = NewDecoderBytes([]byte(aliases), WithLimits(Limits{MaxAliasExpansions: 1}))
+	assertEqual(t, d.Decode(&v) != nil, true)
+	d = NewDecoderBytes([]byte(aliases), WithLimits(Limits{MaxAliasExpansions: -1}))
+	assertEqual(t, d.Decode(&v), nil)
+}
+
+func TestTypedErrors(t *testing.T) {
+	var v struct {
+		Port int `yaml:"port"`
+	}
+	err := Unmarshal([]byte("# c\nport: x\n"), &v)
+	var te *TypeError
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, [3]int{te.Line, te.Column, te.Offset}, [3]int{2, 7, 10})
+	assertEqual(t, te.Type, reflect.TypeOf(0))
+
+	err = Unmarshal([]byte("port: 'x\n"), &v)
+	var se *SyntaxError
+	assertEqual(t, errors.As(err, &se), true)
+
+	err = Unmarshal([]byte("port: 1\nhost: x\n"), &v)
+	var ue *UnknownFieldError
+	assertEqual(t, errors.As(err, &ue), true)
+	assertEqual(t, [3]int{ue.Line, ue.Column, ue.Offset}, [3]int{2, 1, 8})
+	assertEqual(t, ue.Key, "host")
+	assertEqual(t, err.Error(), "undefined field host of struct { Port int \"yaml:\\\"port\\\"\" } at line 2, column 1")
+}
+
+func TestErrorPath(t *testing.T) {
+	var v struct {
+		Servers []struct {
+			TLS struct {
+				Port int `yaml:"port"`
+			} `yaml:"tls"`
+		} `yaml:"servers"`
+	}
+	data := "servers:\n  - tls:\n      port: 1\n  - tls:\n      port: x\n"
+	err := Unmarshal([]byte(data), &v)
+	var te *TypeError
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, te.Field, "servers[1].tls.port")
+
+	var m map[string][]int8
+	err = Unmarshal([]byte("a:\n  - 1\n  - 300\n"), &m)
+	var re *RangeError
+	assertEqual(t, errors.As(err, &re), true)
+	assertEqual(t, re.Field, "a[1]")
+
+	err = Unmarshal([]byte("servers:\n  - tls:\n      host: x\n"), &v)
+	var ue *UnknownFieldError
+	assertEqual(t, errors.As(err, &ue), true)
+	assertEqual(t, ue.Field, "servers[0].tls")
+
+	var p int
+	err = Get([]byte("a:\n  b: [1]\n  c: x\n"), "a.c", &p)
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, te.Field, "a.c")
+}
+
+func TestAllErrors(t *testing.T) {
+	var v struct {
+		Port    int           `yaml:"port"`
+		Size    int8          `yaml:"size"`
+		Timeout time.Duration `yaml:"timeout"`
+		Name    string        `yaml:"name"`
+		Tags    []bool        `yaml:"tags"`
+	}
+	data := "port: x\nsize: 300\nhost:\n  a: 1\ntimeout: y\nname: n\ntags:\n  - true\n  - z\n"
+	err := NewDecoderBytes([]byte(data), WithAllErrors(true)).Decode(&v)
+	var errs Errors
+	assertEqual(t, errors.As(err, &errs), true)
+	assertEqual(t, len(errs), 5)
+	var ue *UnknownFieldError
+	assertEqual(t, errors.As(err, &ue), true)
+	assertEqual(t, ue.Key, "host")
+	var re *RangeError
+	assertEqual(t, errors.As(err, &re), true)
+	assertEqual(t, re.Field, "size")
+	assertEqual(t, v.Name, "n")
+	assertEqual(t, v.Tags, []bool{true, false})
+
+	// The error stopping the decoding comes last.
+	err = NewDecoderBytes([]byte("port: x\nname: 'n\n"), WithAllErrors(true)).Decode(&v)
+	assertEqual(t, errors.As(err, &errs), true)
+	assertEqual(t, len(errs), 2)
+	var se *SyntaxError
+	assertEqual(t, errors.As(errs[1], &se), true)
+
+	err = NewDecoderBytes([]byte("port: x\n"), WithAllErrors(false)).Decode(&v)
+	assertEqual(t, errors.As(err, &errs), false)
+}
+
+func TestFormatError(t *testing.T) {
+	var v struct {
+		Name string `yaml:"name"`
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	data := []byte("name: app\nhost: example.com\nport: x\n")
+	err := Unmarshal(data, &v)
+	assertEqual(t, FormatError(data, err), err.Error()+`
+  1 | name: app
+  2 | host: example.com
+  3 | port: x
+    |       ^`)
+
+	data = []byte("a: x\nb: 2\nc: 3\nd: 4\n")
+	err = Unmarshal(data, new(map[string]int))
+	assertEqual(t, FormatError(data, err), err.Error()+`
+  1 | a: x
+    |    ^
+  2 | b: 2
+  3 | c: 3`)
+
+	err = errors.New("no position")
+	assertEqual(t, FormatError(data, err), "no position")
+}
+
+func TestSentinelErrors(t *testing.T) {
+	var v struct {
+		Port int8 `yaml:"port"`
+	}
+	err := Unmarshal([]byte("host: x\n"), &v)
+	assertEqual(t, errors.Is(err, ErrUnknownField), true)
+
+	err = UnmarshalStrict([]byte("port: 1\nport: 2\n"), &v)
+	assertEqual(t, errors.Is(err, ErrDuplicateKey), true)
+
+	err = Unmarshal([]byte("port: 300\n"), &v)
+	assertEqual(t, errors.Is(err, ErrOverflow), true)
+
+	err = Unmarshal([]byte("port: x\n"), &v)
+	var ne *strconv.NumError
+	assertEqual(t, errors.As(err, &ne), true)
+	assertEqual(t, errors.Is(err, strconv.ErrSyntax), true)
+
+	var c chan int
+	err = Unmarshal([]byte("a\n"), &c)
+	assertEqual(t, errors.Is(err, ErrUnsupportedType), true)
+
+	_, err = Marshal(map[string]interface{}{"c": c})
+	assertEqual(t, errors.Is(err, ErrUnsupportedType), true)
+	assertEqual(t, err.Error(), "unsupported type chan int")
+}
+
+func TestValidUTF8(t *testing.T) {
+	data := []byte("a: b\nc: d\xffe\n")
+	var m map[string]string
+	assertEqual(t, Unmarshal(data, &m), nil)
+
+	err := NewDecoderBytes(data, WithValidUTF8(true)).Decode(&m)
+	assertEqual(t, errors.Is(err, ErrInvalidUTF8), true)
+	var se *SyntaxError
+	assertEqual(t, errors.As(err, &se), true)
+	assertEqual(t, [3]int{se.Line, se.Column, se.Offset}, [3]int{2, 5, 9})
+
+	err = NewDecoderBytes([]byte("a: é\n"), WithValidUTF8(true)).Decode(&m)
+	assertEqual(t, err, nil)
+	assertEqual(t, m["a"], "é")
+}
+
+func TestSplitDocuments(t *testing.T) {
+	data := "# bundle\n" +
+		"a: 1\n" +
+		"---\n" +
+		"b: |\n  ---\n  text\n" +
+		"...\n" +
+		"# only comments\n" +
+		"...\n" +
+		"%YAML 1.2\n---\nc: '---'\n"
+	docs := SplitDocuments([]byte(data))
+	assertEqual(t, len(docs), 3)
+	assertEqual(t, string(docs[0]), "# bundle\na: 1\n")
+	assertEqual(t, string(docs[1]), "---\nb: |\n  ---\n  text\n...\n")
+	assertEqual(t, string(docs[2]), "%YAML 1.2\n---\nc: '---'\n")
+
+	for _, doc := range docs {
+		var m map[string]string
+		assertEqual(t, Unmarshal(doc, &m), nil)
+		assertEqual(t, len(m), 1)
+	}
+	assertEqual(t, len(SplitDocuments(nil)), 0)
+	assertEqual(t, len(SplitDocuments([]byte("---\n---\n"))), 2)
+}
+
+func TestMerge(t *testing.T) {
+	base := []byte(`name: app
+server:
+  host: localhost
+  port: 80
+tags:
+  - a
+users:
+  - name: bob
+    role: user
+  - name: eve
+    role: user
+`)
+	overlay := []byte(`server:
+  port: 8080
+  tls: true
+tags:
+  - b
+users:
+  - name: eve
+    role: admin
+  - name: joe
+    role: user
+debug: true
+`)
+	out, err := Merge(base, overlay)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `name: app
+server:
+  host: localhost
+  port: 8080
+  tls: true
+tags:
+  - b
+users:
+  - name: eve
+    role: admin
+  - name: joe
+    role: user
+debug: true
+`)
+
+	out, err = Merge(base, overlay, WithSliceStrategy(SliceAppend))
+	assertEqual(t, err, nil)
+	var v struct {
+		Name   string                 `yaml:"name"`
+		Server map[string]interface{} `yaml:"server"`
+		Debug  bool                   `yaml:"debug"`
+		Tags   []string               `yaml:"tags"`
+		Users  []struct {
+			Name string `yaml:"name"`
+			Role string `yaml:"role"`
+		} `yaml:"users"`
+	}
+	assertEqual(t, Unmarshal(out, &v), nil)
+	assertEqual(t, v.Tags, []string{"a", "b"})
+	assertEqual(t, len(v.Users), 4)
+
+	out, err = Merge(base, overlay, WithMergeKey("name"))
+	assertEqual(t, err, nil)
+	v.Users = nil
+	assertEqual(t, Unmarshal(out, &v), nil)
+	assertEqual(t, fmt.Sprint(v.Users), "[{bob user} {eve admin} {joe user}]")
+
+	out, err = Merge(base, []byte("# nothing\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), string(base))
+
+	_, err = Merge(base, []byte("a: 'b\n"))
+	assertEqual(t, err != nil, true)
+
+	out, err = Merge([]byte("# app\nname: app # the name\nport: 80 # the port\n"), []byte("port: 8080\n# new\ndebug: true\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "# app\nname: app # the name\nport: 8080 # the port\n# new\ndebug: true\n")
+
+	anchors := []byte("base: &b\n  x: 1\nother: *b\nlist: *b\n")
+	out, err = Merge(anchors, []byte("list:\n  y: 2\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "base: &b\n  x: 1\nother: *b\nlist:\n  x: 1\n  y: 2\n")
+	out, err = Merge(anchors, []byte("base:\n  x: 2\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "base: &b\n  x: 2\nother:\n  x: 1\nlist:\n  x: 1\n")
+}
+
+func TestMergeValues(t *testing.T) {
+	var base, overlay interface{}
+	assertEqual(t, Unmarshal([]byte("a:\n  b: 1\n  c: 2\nl:\n  - k: x\n    v: 1\n"), &base), nil)
+	assertEqual(t, Unmarshal([]byte("a:\n  c: 3\nl:\n  - k: x\n    v: 2\n  - k: y\n"), &overlay), nil)
+
+	v := MergeValues(base, overlay, WithMergeKey("k"))
+	assertEqual(t, v, map[string]interface{}{
+		"a": map[string]interface{}{"b": 1, "c": 3},
+		"l": []interface{}{
+			map[string]interface{}{"k": "x", "v": 2},
+			map[string]interface{}{"k": "y"},
+		},
+	})
+	// The base is left unchanged.
+	assertEqual(t, base.(map[string]interface{})["a"], map[string]interface{}{"b": 1, "c": 2})
+
+	s := MergeValues(MapSlice{{"a", 1}, {"b", 2}}, MapSlice{{"c", 3}, {"a", 4}})
+	assertEqual(t, s, MapSlice{{"a", 4}, {"b", 2}, {"c", 3}})
+}
+
+func TestLoader(t *testing.T) {
+	type config struct {
+		Name   string `yaml:"name"`
+		Server struct {
+			Host string `yaml:"host"`
+			Port int    `yaml:"port"`
+		} `yaml:"server"`
+		Tags  []string          `yaml:"tags"`
+		Debug bool              `yaml:"debug"`
+		Extra map[string]string `yaml:"extra"`
+	}
+	var defaults config
+	defaults.Name = "app"
+	defaults.Server.Host = "localhost"
+	defaults.Server.Port = 80
+	defaults.Tags = []string{"a"}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	os.WriteFile(file, []byte("server:\n  port: 8080\ntags:\n  - b\nextra:\n  k: v\n"), 0666)
+
+	t.Setenv("TEST_LOADER_HOST", "example.com")
+	l := NewLoader(WithStrict(true))
+	l.Defaults(&defaults)
+	l.File(file)
+	l.OptionalFile(filepath.Join(dir, "missing.yaml"))
+	l.Env("TEST_LOADER_HOST", "server.host")
+	l.Env("TEST_LOADER_UNSET", "server.port")
+	l.Set("debug", true)
+	l.Set("extra.l", "x: y")
+
+	var c config
+	assertEqual(t, l.Load(&c), nil)
+	assertEqual(t, c.Name, "app")
+	assertEqual(t, c.Server.Host, "example.com")
+	assertEqual(t, c.Server.Port, 8080)
+	assertEqual(t, c.Tags, []string{"b"})
+	assertEqual(t, c.Debug, true)
+	assertEqual(t, c.Extra, map[string]string{"k": "v", "l": "x: y"})
+	assertEqual(t, defaults.Tags, []string{"a"})
+
+	l.File(filepath.Join(dir, "missing.yaml"))
+	assertEqual(t, errors.Is(l.Load(&c), fs.ErrNotExist), true)
+
+	l = NewLoader()
+	l.Set("server.port", "x")
+	var te *TypeError
+	assertEqual(t, errors.As(l.Load(&c), &te), true)
+
+	// The nested mappings are merged, in maps and interfaces too.
+	type backend struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	var w struct {
+		Backends map[string]backend `yaml:"backends"`
+		Extra    interface{}        `yaml:"extra"`
+	}
+	os.WriteFile(file, []byte("backends:\n  a:\n    host: h\n    port: 1\nextra:\n  x: 1\n"), 0666)
+	l = NewLoader()
+	l.File(file)
+	l.Set("backends.a.port", 2)
+	l.Set("extra.y", 2)
+	assertEqual(t, l.Load(&w), nil)
+	assertEqual(t, w.Backends, map[string]backend{"a": {"h", 2}})
+	assertEqual(t, w.Extra, map[string]interface{}{"x": 1, "y": 2})
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TEST_EXPAND_HOST", "db")
+	t.Setenv("TEST_EXPAND_PORT", "5433")
+	t.Setenv("TEST_EXPAND_EMPTY", "")
+	t.Setenv("SECRET", "s")
+
+	var v struct {
+		URL     string        `yaml:"url"`
+		Port    int           `yaml:"port"`
+		Timeout time.Duration `yaml:"timeout"`
+		Any     interface{}   `yaml:"any"`
+		Literal string        `yaml:"literal"`
+	}
+	data := "url: postgres://${TEST_EXPAND_HOST}:${TEST_EXPAND_UNSET:-5432}/${TEST_EXPAND_EMPTY:-app}\n" +
+		"port: ${TEST_EXPAND_PORT}\n" +
+		"timeout: ${TEST_EXPAND_UNSET:-5s}\n" +
+		"any: ${TEST_EXPAND_PORT}\n" +
+		"literal: $${TEST_EXPAND_HOST}\n"
+	d := NewDecoderBytes([]byte(data), WithExpandEnv([]string{"TEST_EXPAND_*"}, nil))
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v.URL, "postgres://db:5432/app")
+	assertEqual(t, v.Port, 5433)
+	assertEqual(t, v.Timeout, 5*time.Second)
+	assertEqual(t, v.Any, 5433)
+	assertEqual(t, v.Literal, "${TEST_EXPAND_HOST}")
+
+	// Without the option, the text is left unchanged.
+	assertEqual(t, Unmarshal([]byte("url: ${TEST_EXPAND_HOST}\n"), &v), nil)
+	assertEqual(t, v.URL, "${TEST_EXPAND_HOST}")
+
+	err := NewDecoderBytes([]byte("url: ${SECRET}\n"), WithExpandEnv([]string{"TEST_EXPAND_*"}, nil)).Decode(&v)
+	assertEqual(t, strings.Contains(fmt.Sprint(err), "environment variable SECRET is not allowed"), true)
+	err = NewDecoderBytes([]byte("url: ${SECRET}\n"), WithExpandEnv(nil, []string{"SECRET"})).Decode(&v)
+	assertEqual(t, strings.Contains(fmt.Sprint(err), "environment variable SECRET is not allowed"), true)
+	err = NewDecoderBytes([]byte("url: ${TEST_EXPAND_UNSET}\n"), WithExpandEnv(nil, nil)).Decode(&v)
+	assertEqual(t, strings.Contains(fmt.Sprint(err), "environment variable TEST_EXPAND_UNSET is not set"), true)
+}
+
+func TestBindEnv(t *testing.T) {
+	type tls struct {
+		CertFile string `yaml:"cert_file"`
+	}
+	var v struct {
+		Server struct {
+			Port    int           `yaml:"port"`
+			Timeout time.Duration `yaml:"timeout"`
+			TLS     *tls          `yaml:"tls"`
+		} `yaml:"server"`
+		Debug bool     `yaml:"debug"`
+		Name  string   `yaml:"name"`
+		Tags  []string `yaml:"tags"`
+		Other *tls     `yaml:"other"`
+	}
+	assertEqual(t, Unmarshal([]byte("server:\n  port: 80\nname: app\n"), &v), nil)
+
+	t.Setenv("APP_SERVER_PORT", "8080")
+	t.Setenv("APP_SERVER_TIMEOUT", "5s")
+	t.Setenv("APP_SERVER_TLS_CERT_FILE", "/etc/cert.pem")
+	t.Setenv("APP_DEBUG", "true")
+	assertEqual(t, BindEnv(&v, "APP_"), nil)
+	assertEqual(t, v.Server.Port, 8080)
+	assertEqual(t, v.Server.Timeout, 5*time.Second)
+	assertEqual(t, v.Server.TLS.CertFile, "/etc/cert.pem")
+	assertEqual(t, v.Debug, true)
+	assertEqual(t, v.Name, "app")
+	assertEqual(t, v.Other == nil, true)
+
+	t.Setenv("APP_DEBUG", "maybe")
+	err := BindEnv(&v, "APP_")
+	assertEqual(t, strings.HasPrefix(fmt.Sprint(err), "APP_DEBUG: "), true)
+
+	t.Setenv("APP_DEBUG", "false")
+	l := NewLoader()
+	l.EnvPrefix("APP_")
+	l.Set("server.port", 9090)
+	assertEqual(t, l.Load(&v), nil)
+	assertEqual(t, v.Debug, false)
+	assertEqual(t, v.Server.Port, 9090)
+}
+
+func TestWatch(t *testing.T) {
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 5 * time.Millisecond
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	// The file is replaced at once, not to be read while written.
+	write := func(s string) {
+		os.WriteFile(file+".tmp", []byte(s), 0666)
+		os.Rename(file+".tmp", file)
+	}
+	write("port: 80\n")
+
+	type Config struct {
+		Port int `yaml:"port"`
+	}
+	var v Config
+	var current atomic.Value
+	changes := make(chan int, 10)
+	errs := make(chan error, 10)
+	stop, err := Watch(file, &v, func(nv interface{}, err error) {
+		if err != nil {
+			errs <- err
+			return
+		}
+		current.Store(nv)
+		changes <- nv.(*Config).Port
+	})
+	assertEqual(t, err, nil)
+	defer stop()
+	assertEqual(t, v.Port, 80)
+
+	write("port: 8080\n")
+	assertEqual(t, <-changes, 8080)
+	assertEqual(t, current.Load().(*Config).Port, 8080)
+
+	write("port: x\n")
+	var te *TypeError
+	assertEqual(t, errors.As(<-errs, &te), true)
+	assertEqual(t, current.Load().(*Config).Port, 8080)
+
+	write("port: 9090\n")
+	assertEqual(t, <-changes, 9090)
+	stop()
+	assertEqual(t, v.Port, 80)
+
+	_, err = Watch(filepath.Join(t.TempDir(), "missing.yaml"), &v, func(interface{}, error) {})
+	assertEqual(t, errors.Is(err, fs.ErrNotExist), true)
+	_, err = Watch(file, &v, nil)
+	assertEqual(t, err != nil, true)
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-base.yaml"), []byte("name: app\nserver:\n  host: localhost\n  port: 80\ntags:\n  - a\n"), 0666)
+	os.WriteFile(filepath.Join(dir, "20-port.yml"), []byte("server:\n  port: 8080\n"), 0666)
+	os.WriteFile(filepath.Join(dir, "30-empty.yaml"), []byte("# nothing\n"), 0666)
+	os.WriteFile(filepath.Join(dir, "40-tags.yaml"), []byte("tags:\n  - b\n"), 0666)
+	os.WriteFile(filepath.Join(dir, "50-other.conf"), []byte("name: x\n"), 0666)
+	os.WriteFile(filepath.Join(dir, ".60-hidden.yaml"), []byte("name: y\n"), 0666)
+	os.Mkdir(filepath.Join(dir, "70-dir.yaml"), 0777)
+
+	var v struct {
+		Name   string                 `yaml:"name"`
+		Server map[string]interface{} `yaml:"server"`
+		Tags   []string               `yaml:"tags"`
+	}
+	err := ReadDir(dir, &v)
+	assertEqual(t, err, nil)
+	assertEqual(t, v.Name, "app")
+	assertEqual(t, v.Server, map[string]interface{}{"host": "localhost", "port": 8080})
+	assertEqual(t, v.Tags, []string{"b"})
+
+	os.WriteFile(filepath.Join(dir, "80-bad.yaml"), []byte("tags: x\n"), 0666)
+	err = ReadDir(dir, &v)
+	assertEqual(t, fmt.Sprint(err), filepath.Join(dir, "80-bad.yaml")+": tags unexpected x at line 1, column 7")
+
+	// The mappings of maps and interfaces span the files.
+	type backend struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	var w struct {
+		Backends map[string]backend `yaml:"backends"`
+		Extra    interface{}        `yaml:"extra"`
+	}
+	dir = t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-base.yaml"), []byte("backends:\n  a:\n    host: h\nextra:\n  x: 1\n"), 0666)
+	os.WriteFile(filepath.Join(dir, "20-over.yaml"), []byte("backends:\n  a:\n    port: 2\nextra:\n  y: 2\n"), 0666)
+	assertEqual(t, ReadDir(dir, &w), nil)
+	assertEqual(t, w.Backends, map[string]backend{"a": {"h", 2}})
+	assertEqual(t, w.Extra, map[string]interface{}{"x": 1, "y": 2})
+}
+
+func TestRequiredFields(t *testing.T) {
+	type server struct {
+		Host string `yaml:"host,required"`
+		Port int    `yaml:"port,required"`
+	}
+	var v struct {
+		Token  string `yaml:"token,required"`
+		Name   string `yaml:"name"`
+		Server server `yaml:"server"`
+	}
+	err := Unmarshal([]byte("token: t\nserver:\n  host: h\n  port: 1\n"), &v)
+	assertEqual(t, err, nil)
+
+	err = Unmarshal([]byte("name: n\nserver:\n  port: 1\n"), &v)
+	var me *MissingFieldError
+	assertEqual(t, errors.As(err, &me), true)
+	assertEqual(t, me.Field, "server")
+	assertEqual(t, me.Keys, []string{"host"})
+	assertEqual(t, [3]int{me.Line, me.Column, me.Offset}, [3]int{3, 3, 18})
+	assertEqual(t, errors.Is(err, ErrMissingField), true)
+
+	err = NewDecoderBytes([]byte("name: n\nserver: {}\n"), WithAllErrors(true)).Decode(&v)
+	var errs Errors
+	assertEqual(t, errors.As(err, &errs), true)
+	assertEqual(t, len(errs), 2)
+	assertEqual(t, strings.HasPrefix(errs[0].Error(), "server missing required fields host, port of yaml.server"), true)
+	assertEqual(t, strings.HasPrefix(errs[1].Error(), "missing required field token of"), true)
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	var v struct {
+		Host    string `yaml:"host"`
+		OldHost string `yaml:"old_host,deprecated=use host"`
+		Server  struct {
+			Legacy bool `yaml:"legacy,deprecated"`
+		} `yaml:"server"`
+	}
+	var warnings []string
+	d := NewDecoderBytes([]byte("old_host: h\nserver:\n  legacy: true\n"), WithWarningFunc(func(w *Warning) {
+		warnings = append(warnings, w.String())
+	}))
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v.OldHost, "h")
+	assertEqual(t, v.Server.Legacy, true)
+	assertEqual(t, warnings, []string{
+		"old_host is deprecated: use host at line 1, column 1",
+		"server.legacy is deprecated at line 3, column 3",
+	})
+
+	// Without a warning func, the fields are decoded silently.
+	assertEqual(t, Unmarshal([]byte("old_host: h\n"), &v), nil)
+}
+
+type hookLevel int
+
+func TestDecodeHook(t *testing.T) {
+	split := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok && to == reflect.TypeOf([]string(nil)) {
+			return strings.Split(s, ","), nil
+		}
+		return data, nil
+	}
+	levels := map[string]hookLevel{"low": 1, "high": 2}
+	enum := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok && to == reflect.TypeOf(hookLevel(0)) {
+			l, ok := levels[s]
+			if !ok {
+				return nil, errors.New("unknown level " + s)
+			}
+			return l, nil
+		}
+		return data, nil
+	}
+	// An adapter from a host:port string to a struct.
+	type addr struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	}
+	adapt := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		if s, ok := data.(string); ok && to == reflect.TypeOf(addr{}) {
+			host, port, _ := strings.Cut(s, ":")
+			return map[string]interface{}{"host": host, "port": port}, nil
+		}
+		return data, nil
+	}
+
+	var v struct {
+		Tags  []string  `yaml:"tags"`
+		Level hookLevel `yaml:"level"`
+		Addr  addr      `yaml:"addr"`
+		Other addr      `yaml:"other"`
+		Port  int       `yaml:"port"`
+	}
+	data := "tags: a,b,c\nlevel: high\naddr: example.com:80\nother:\n  host: h\n  port: 1\nport: 8080\n"
+	d := NewDecoderBytes([]byte(data), WithHook(split), WithHook(enum), WithHook(adapt))
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v.Tags, []string{"a", "b", "c"})
+	assertEqual(t, v.Level, hookLevel(2))
+	assertEqual(t, v.Addr, addr{"example.com", 80})
+	assertEqual(t, v.Other, addr{"h", 1})
+	assertEqual(t, v.Port, 8080)
+
+	d = NewDecoderBytes([]byte("port: 1\nlevel: mid\n"), WithHook(enum))
+	var te *TypeError
+	err := d.Decode(&v)
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, [2]int{te.Line, te.Column}, [2]int{2, 8})
+	assertEqual(t, te.Field, "level")
+
+	// The errors of the result are at the node, once.
+	d = NewDecoderBytes([]byte("port: 1\naddr: h:notaport\n"), WithHook(adapt))
+	err = d.Decode(&v)
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, [3]int{te.Line, te.Column, strings.Count(err.Error(), " at line")}, [3]int{2, 7, 1})
+	assertEqual(t, te.Field, "addr.port")
+	d = NewDecoderBytes([]byte("port: 1\naddr: h:notaport\n"), WithHook(adapt), WithAllErrors(true))
+	err = d.Decode(&v)
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, [2]int{te.Line, te.Column}, [2]int{2, 7})
+
+	// The nodes are counted once, and the tagged nodes decoded once.
+	nop := func(from, to reflect.Type, data interface{}) (interface{}, error) {
+		return data, nil
+	}
+	var tree map[string]map[string]map[string]int
+	data = "a:\n  b:\n    c: 1\n"
+	assertEqual(t, NewDecoderBytes([]byte(data), WithLimits(Limits{MaxKeys: 3}), WithHook(nop)).Decode(&tree), nil)
+	assertEqual(t, tree["a"]["b"]["c"], 1)
+
+	calls := 0
+	RegisterTag("!counted", func(value string, target reflect.Value) error {
+		calls++
+		target.SetString(value)
+		return nil
+	})
+	var tagged struct {
+		Sub struct {
+			Name string `yaml:"name"`
+		} `yaml:"sub"`
+	}
+	data = "sub:\n  name: !counted x\n"
+	assertEqual(t, NewDecoderBytes([]byte(data), WithHook(nop)).Decode(&tagged), nil)
+	assertEqual(t, tagged.Sub.Name, "x")
+	assertEqual(t, calls, 1)
+}
+
+func TestWeak(t *testing.T) {
+	type config struct {
+		A bool          `yaml:"a"`
+		B bool          `yaml:"b"`
+		C bool          `yaml:"c"`
+		I int           `yaml:"i"`
+		J int           `yaml:"j"`
+		F float64       `yaml:"f"`
+		S string        `yaml:"s"`
+		L []string      `yaml:"l"`
+		N []int         `yaml:"n"`
+		D time.Duration `yaml:"d"`
+		E []int         `yaml:"e"`
+	}
+	data := "a: yes\nb: 2\nc: Off\ni: '8080'\nj: true\nf: ~\ns: 1.10\nl: one\nn: '3'\nd: 5s\ne:\n"
+	var v config
+	assertEqual(t, NewDecoderBytes([]byte(data), WithWeak(true), WithStrict(true)).Decode(&v), nil)
+	assertEqual(t, v, config{A: true, B: true, I: 8080, J: 1, S: "1.10", L: []string{"one"}, N: []int{3}, D: 5 * time.Second})
+
+	var w config
+	assertEqual(t, Unmarshal([]byte("a: yes\n"), &w) != nil, true)
+	assertEqual(t, NewDecoderBytes([]byte("i: 1.5\n"), WithWeak(true)).Decode(&w) != nil, true)
+	assertEqual(t, NewDecoderBytes([]byte("a: maybe\n"), WithWeak(true)).Decode(&w) != nil, true)
+}
+
+func TestSecretFields(t *testing.T) {
+	type db struct {
+		User     string  `yaml:"user"`
+		Password string  `yaml:"password,secret"`
+		Token    string `yaml:"token,secret"`
+	}
+	v := struct {
+		DB db `yaml:"db"`
+	}{db{"app", "hunter2", ""}}
+
+	data, err := Marshal(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(data), "db:\n  user: app\n  password: \"****\"\n  token: \"\"\n")
+
+	data, err = MarshalRedacted(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(data), "db:\n  user: app\n")
+
+	data, err = NewEncoder(WithSecretStyle(SecretShow)).Encode(v)
+	assertEqual(t, err, nil)
+	assertEqual(t, strings.Contains(string(data), "password: hunter2\n"), true)
+
+	file := filepath.Join(t.TempDir(), "db.yaml")
+	assertEqual(t, WriteFile(file, v), nil)
+	var w struct {
+		DB db `yaml:"db"`
+	}
+	assertEqual(t, ReadFile(file, &w), nil)
+	assertEqual(t, w.DB.Password, "hunter2")
+
+	l := NewLoader()
+	l.Defaults(&v)
+	assertEqual(t, l.Load(&w), nil)
+	assertEqual(t, w.DB.Password, "hunter2")
+}
+
+func TestCrypto(t *testing.T) {
+	// A reversible "cipher" for the test.
+	rev := func(s string) (string, error) {
+		if s == "bad" {
+			return "", errors.New("bad ciphertext")
+		}
+		r := []byte(s)
+		for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+			r[i], r[j] = r[j], r[i]
+		}
+		return string(r), nil
+	}
+	c := &Crypto{Tag: "!encrypted", Prefix: "ENC[", Suffix: "]", Decrypt: rev, Encrypt: rev}
+
+	type config struct {
+		User     string `yaml:"user"`
+		Password string `yaml:"password,secret"`
+		Port     int     `yaml:"port,secret"`
+		Token    *string `yaml:"token,secret"`
+	}
+	var v config
+	data := "user: app\npassword: ENC[2retnuh]\nport: !encrypted 0808\ntoken: ENC[cba]\n"
+	assertEqual(t, NewDecoderBytes([]byte(data), WithCrypto(c)).Decode(&v), nil)
+	assertEqual(t, v.Password, "hunter2")
+	assertEqual(t, v.Port, 8080)
+	assertEqual(t, *v.Token, "abc")
+
+	// The secret values are encrypted again.
+	out, err := NewEncoder(WithCrypto(c)).Encode(&v)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "user: app\npassword: ENC[2retnuh]\nport: ENC[0808]\ntoken: ENC[cba]\n")
+	var r config
+	assertEqual(t, NewDecoderBytes(out, WithCrypto(c)).Decode(&r), nil)
+	assertEqual(t, r, v)
+
+	// Outside a secret field, an encrypted value would be written back
+	// in plaintext.
+	var m map[string]interface{}
+	err = NewDecoderBytes([]byte("user: ENC[ppa]\n"), WithCrypto(c)).Decode(&m)
+	assertEqual(t, strings.Contains(fmt.Sprint(err), "encrypted value in a field without the secret option"), true)
+	var u struct {
+		Users []string `yaml:"users,secret"`
+	}
+	err = NewDecoderBytes([]byte("users:\n  - !encrypted ppa\n"), WithCrypto(c)).Decode(&u)
+	assertEqual(t, err != nil, true)
+
+	out, err = NewEncoder(WithCrypto(&Crypto{Tag: "!encrypted", Encrypt: rev})).Encode(&v)
+	assertEqual(t, err, nil)
+	assertEqual(t, strings.Contains(string(out), "password: !encrypted 2retnuh\n"), true)
+
+	// Without a crypto, the values are left encrypted.
+	assertEqual(t, Unmarshal([]byte("password: ENC[2retnuh]\n"), &v), nil)
+	assertEqual(t, v.Password, "ENC[2retnuh]")
+
+	err = NewDecoderBytes([]byte("password: ENC[bad]\n"), WithCrypto(c)).Decode(&v)
+	assertEqual(t, strings.Contains(fmt.Sprint(err), "can not decrypt value: bad ciphertext"), true)
+}
+
+func TestTemplate(t *testing.T) {
+	funcs := template.FuncMap{"upper": strings.ToUpper}
+	data := map[string]interface{}{"Host": "example.com", "Ports": []int{80, 443}}
+	var v struct {
+		Host  string `yaml:"host"`
+		Ports []int  `yaml:"ports"`
+	}
+	doc := "host: {{ .Host | upper }}\nports:\n{{- range .Ports }}\n  - {{ . }}\n{{- end }}\n"
+	assertEqual(t, NewDecoderBytes([]byte(doc), WithTemplate(funcs, data)).Decode(&v), nil)
+	assertEqual(t, v.Host, "EXAMPLE.COM")
+	assertEqual(t, v.Ports, []int{80, 443})
+
+	// The limit applies to the output of the template.
+	long := map[string]interface{}{"Host": strings.Repeat("x", 100)}
+	err := NewDecoderBytes([]byte("host: {{ .Host }}\n"), WithTemplate(nil, long), WithLimits(Limits{MaxDocumentSize: 50})).Decode(&v)
+	assertEqual(t, err != nil, true)
+
+	err = NewDecoderBytes([]byte("a: 1\n\nhost: {{ .Missing.X }}\n"), WithTemplate(nil, data)).Decode(&v)
+	var se *SyntaxError
+	assertEqual(t, errors.As(err, &se), true)
+	assertEqual(t, se.Line, 3)
+	assertEqual(t, strings.HasPrefix(se.Msg, "template: executing"), true)
+
+	err = NewDecoderBytes([]byte("a: 1\nhost: {{ .Host\n"), WithTemplate(nil, data)).Decode(&v)
+	assertEqual(t, errors.As(err, &se), true)
+	assertEqual(t, se.Line, 3)
+}
+
+func TestToJSON(t *testing.T) {
+	data := `name: app
+port: 8080
+ratio: 0.5
+debug: true
+none: ~
+hex: 0x1F
+quoted: "8080"
+html: <a & b>
+1: one
+list:
+  - a
+  - b: c
+custom: !foo bar
+`
+	out, err := ToJSON([]byte(data))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `{"name":"app","port":8080,"ratio":0.5,"debug":true,"none":null,"hex":"0x1F","quoted":"8080","html":"<a & b>","1":"one","list":["a",{"b":"c"}],"custom":"bar"}`)
+
+	out, err = ToJSON([]byte("# empty\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "null")
+
+	_, err = ToJSON([]byte("a: 1\nb: .inf\n"))
+	assertEqual(t, fmt.Sprint(err), "yaml: .inf can not be written in JSON at line 2, column 4")
+
+	_, err = ToJSON([]byte("? - a\n: b\n"))
+	assertEqual(t, strings.Contains(fmt.Sprint(err), "collection as a key"), true)
+
+	_, err = ToJSON([]byte("a: 12345678901234567890\n"))
+	assertEqual(t, fmt.Sprint(err), "yaml: integer 12345678901234567890 out of the range of int64 at line 1, column 4")
+	_, err = ToJSON([]byte("a: !!int -12345678901234567890\n"))
+	assertEqual(t, fmt.Sprint(err), "yaml: integer -12345678901234567890 out of the range of int64 at line 1, column 4")
+	out, err = ToJSON([]byte("a: 9223372036854775807\nb: 1e30\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `{"a":9223372036854775807,"b":1e+30}`)
+
+	out, err = ToJSON([]byte("base: &b\n  x: 1\nother: *b\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `{"base":{"x":1},"other":{"x":1}}`)
+
+	_, err = ToJSON([]byte("a: 1\n---\nb: 2\n"))
+	assertEqual(t, fmt.Sprint(err), "yaml: ToJSON expects a single document, another follows at line 3")
+	out, err = ToJSON([]byte("---\na: 1\n...\n# end\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `{"a":1}`)
+}
+
+func TestFromJSON(t *testing.T) {
+	data := `{"name": "app", "port": 8080, "ratio": 1.5e3, "version": "1.10", "debug": false,
+		"none": null, "tags": ["a", "b"], "empty": {}, "list": [], "servers": [{"host": "h", "port": 80}],
+		"text": "line 1\nline 2"}`
+	out, err := FromJSON([]byte(data))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `name: app
+port: 8080
+ratio: 1.5e3
+version: "1.10"
+debug: false
+none: null
+tags:
+  - a
+  - b
+empty: {}
+list: []
+servers:
+  - host: h
+    port: 80
+text: |-
+  line 1
+  line 2
+`)
+
+	_, err = FromJSON([]byte(`{"a": 1} x`))
+	assertEqual(t, err != nil, true)
+	_, err = FromJSON([]byte(`{"a": }`))
+	assertEqual(t, err != nil, true)
+
+	// The numbers are read back by ToJSON, or rejected.
+	out, err = FromJSON([]byte(`{"a": 9223372036854775807, "b": -1E5}`))
+	assertEqual(t, err, nil)
+	out, err = ToJSON(out)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `{"a":9223372036854775807,"b":-100000}`)
+	_, err = FromJSON([]byte(`{"a": 1e400}`))
+	assertEqual(t, fmt.Sprint(err), "yaml: number 1e400 out of the range of float64 at offset 6")
+	_, err = FromJSON([]byte(`{"a": 12345678901234567890}`))
+	assertEqual(t, fmt.Sprint(err), "yaml: integer 12345678901234567890 out of the range of int64 at offset 6")
+}
+
+func TestJSONCompatible(t *testing.T) {
+	data := `name: app
+port: 8080
+ratio: +1.50
+big: 12345678901234567890
+1: one
+list:
+  - 1e3
+  - true
+  - ~
+nested:
+  key: .5
+`
+	var v interface{}
+	d := NewDecoderBytes([]byte(data), WithJSONCompatible(true))
+	assertEqual(t, d.Decode(&v), nil)
+	assertEqual(t, v, map[string]interface{}{
+		"name":  "app",
+		"port":  json.Number("8080"),
+		"ratio": json.Number("1.5"),
+		"big":   json.Number("12345678901234567890"),
+		"1":     "one",
+		"list":  []interface{}{json.Number("1e3"), true, nil},
+		"nested": map[string]interface{}{
+			"key": json.Number("0.5"),
+		},
+	})
+	_, err := json.Marshal(v)
+	assertEqual(t, err, nil)
+
+	d = NewDecoderBytes([]byte("a: 1\nb: .inf\n"), WithJSONCompatible(true))
+	err = d.Decode(&v)
+	assertEqual(t, fmt.Sprint(err), "b .inf can not be written in JSON at line 2, column 4")
+}
+
+func TestFormat(t *testing.T) {
+	data := `# the service
+name:   app   # its name
+port:    8080
+
+# the servers
+servers:
+    - host: 'a'
+      port: "80"
+    # the backup
+    - host: b
+"8080": x
+msg: "a #b"
+text: |
+  line # not a comment
+  two
+# end
+`
+	out, err := Format([]byte(data), FormatOptions{})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `# the service
+name: app # its name
+port: 8080
+# the servers
+servers:
+  - host: a
+    port: "80"
+  # the backup
+  - host: b
+"8080": x
+msg: "a #b"
+text: |
+  line # not a comment
+  two
+# end
+`)
+
+	out, err = Format([]byte("a:\n - 1\n---\n# c\nb:  2\n"), FormatOptions{Indent: 4})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a:\n    - 1\n---\n# c\nb: 2\n")
+
+	_, err = Format([]byte("a:\n\tb: 2\n"), FormatOptions{})
+	assertEqual(t, err != nil, true)
+	_, err = Format([]byte("a: 1\n"), FormatOptions{Indent: 10})
+	assertEqual(t, err != nil, true)
+
+	anchors := "base:   &b\n  x: 1\nother: *b\nlist:\n- &e  a\n- *e\n"
+	out, err = Format([]byte(anchors), FormatOptions{})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "base: &b\n  x: 1\nother: *b\nlist:\n  - &e a\n  - *e\n")
+
+	// The empty values leave no trailing spaces.
+	out, err = Format([]byte("a:\nb:\n  -\n  - ''\n"), FormatOptions{})
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "a:\nb:\n  -\n  - \"\"\n")
+	assertEqual(t, len(Lint(out)), 0)
+
+	// The formatted documents have the values of the documents.
+	for _, in := range []string{data, anchors, "a: '1'\nb: !!str 2\nc: |-\n  x\nd: ~\n", "- - a\n  - b\n- {}\n"} {
+		out, err := Format([]byte(in), FormatOptions{Indent: 3, LineWidth: 10})
+		assertEqual(t, err, nil)
+		var x, y interface{}
+		assertEqual(t, Unmarshal([]byte(in), &x), nil)
+		assertEqual(t, Unmarshal(out, &y), nil)
+		assertEqual(t, y, x)
+	}
+}
+
+func TestLint(t *testing.T) {
+	data := "name: app \n" +
+		"servers:\n" +
+		"  - host: a\n" +
+		"    ports:\n" +
+		"       - 80\n" +
+		"name: b\n" +
+		"text: |\n" +
+		"      indented   \n" +
+		"description: " + strings.Repeat("x", 80) + "\n" +
+		"---\n" +
+		"---\n" +
+		"a: 1\n"
+	var problems []string
+	for _, p := range Lint([]byte(data)) {
+		problems = append(problems, p.String())
+	}
+	assertEqual(t, problems, []string{
+		"1:10: warning: trailing spaces (trailing-spaces)",
+		"5:8: warning: wrong indentation: expected 6 spaces, found 7 (indentation)",
+		"6:1: error: duplicate key \"name\", first at line 1 (duplicate-key)",
+		"8:15: warning: trailing spaces (trailing-spaces)",
+		"9:81: warning: line too long (93 > 80 characters) (line-length)",
+		"10:1: warning: empty document (empty-document)",
+	})
+
+	problems = problems[:0]
+	for _, p := range Lint([]byte("a:\n\tb: 1\n")) {
+		problems = append(problems, p.String())
+	}
+	assertEqual(t, len(problems), 2)
+	assertEqual(t, problems[0], "2:1: error: tab in indentation (tabs)")
+	assertEqual(t, strings.HasPrefix(problems[1], "2:"), true)
+	assertEqual(t, strings.HasSuffix(problems[1], "(syntax)"), true)
+
+	assertEqual(t, len(Lint([]byte("a:\n    b:\n        - 1\n        - c: 2\n          d: 3\n"))), 0)
+	assertEqual(t, len(Lint([]byte("a:\n  -\n  - - b\n    -\n"))), 0)
+}
+
+func TestDiff(t *testing.T) {
+	a := `name: app
+port: 80
+ratio: 1.0
+tags:
+  - web
+  - api
+servers:
+  - host: a
+    tls: false
+debug: true
+`
+	b := `# reordered
+servers:
+  - tls: false
+    host: b
+ratio: 1.00
+port: "80"
+name:   app
+tags:
+  - web
+extra:
+  level: 2
+`
+	changes, err := Diff([]byte(a), []byte(b))
+	assertEqual(t, err, nil)
+	var s []string
+	for _, c := range changes {
+		s = append(s, c.String())
+	}
+	assertEqual(t, s, []string{
+		`~ port: 80 -> "80"`,
+		`- tags[1]: "api"`,
+		`~ servers[0].host: "a" -> "b"`,
+		`- debug: true`,
+		`+ extra: {"level":2}`,
+	})
+	assertEqual(t, changes[2].Old.Line, 8)
+	assertEqual(t, changes[2].New.Line, 4)
+
+	changes, err = Diff([]byte("a: 1\n"), []byte("- 1\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(changes), 1)
+	assertEqual(t, changes[0].String(), `~ $: {"a":1} -> [1]`)
+
+	changes, err = Diff([]byte(a), []byte(a))
+	assertEqual(t, err, nil)
+	assertEqual(t, len(changes), 0)
+
+	_, err = Diff([]byte(a), []byte("a:\n\tb: 1\n"))
+	assertEqual(t, err != nil, true)
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := `# the service
+name: app # its name
+replicas: 1
+tags:
+  - web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.0
+    - name: sidecar
+      image: proxy
+`
+	out, err := ApplyPatch([]byte(doc), []byte(`[
+		{"op": "replace", "path": "/name", "value": "api"},
+		{"op": "test", "path": "/replicas", "value": 1},
+		{"op": "add", "path": "/tags/-", "value": "api"},
+		{"op": "add", "path": "/tags/0", "value": "first"},
+		{"op": "remove", "path": "/spec/containers/1"},
+		{"op": "copy", "from": "/spec/containers/0/image", "path": "/image"},
+		{"op": "move", "from": "/replicas", "path": "/spec/replicas"},
+		{"op": "add", "path": "/a~1b", "value": {"x": [1, 2]}}
+	]`), JSONPatch)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `# the service
+name: api # its name
+tags:
+  - first
+  - web
+  - api
+spec:
+  containers:
+    - name: web
+      image: nginx:1.0
+  replicas: 1
+image: nginx:1.0
+a/b:
+  x:
+    - 1
+    - 2
+`)
+
+	_, err = ApplyPatch([]byte(doc), []byte(`[{"op": "test", "path": "/replicas", "value": 2}]`), JSONPatch)
+	assertEqual(t, fmt.Sprint(err), "yaml: JSON patch operation 0: test failed at /replicas")
+	_, err = ApplyPatch([]byte(doc), []byte(`[{"op": "remove", "path": "/missing"}]`), JSONPatch)
+	assertEqual(t, errors.Is(err, ErrNotFound), true)
+	_, err = ApplyPatch([]byte(doc), []byte(`[{"op": "add", "path": "/tags/5", "value": 1}]`), JSONPatch)
+	assertEqual(t, fmt.Sprint(err), "yaml: JSON patch operation 0: index 5 out of range at /tags/5")
+
+	out, err = ApplyPatch([]byte(doc), []byte(`
+replicas: 3
+tags:
+  - api
+spec:
+  containers:
+    - name: web
+      image: nginx:2.0
+    - name: sidecar
+      $patch: delete
+    - name: log
+      image: fluentd
+name: null
+`), StrategicMergePatch)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), `# the service
+replicas: 3
+tags:
+  - api
+spec:
+  containers:
+    - name: web
+      image: nginx:2.0
+    - name: log
+      image: fluentd
+`)
+
+	out, err = ApplyPatch([]byte(doc), []byte("spec:\n  $patch: replace\n  paused: true\n"), StrategicMergePatch)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(out), "# the service\nname: app # its name\nreplicas: 1\ntags:\n  - web\nspec:\n  paused: true\n")
+
+	_, err = ApplyPatch([]byte(doc), []byte("spec:\n  $patch: drop\n"), StrategicMergePatch)
+	assertEqual(t, fmt.Sprint(err), `yaml: invalid $patch directive "drop"`)
+}
+
+func TestExtractFrontMatter(t *testing.T) {
+	type page struct {
+		Title string   `yaml:"title"`
+		Tags  []string `yaml:"tags"`
+		Order int      `yaml:"order"`
+	}
+	var p page
+	body, err := ExtractFrontMatter([]byte("---\ntitle: Hello\ntags:\n  - intro\n---\n# Hello\n\ntext\n"), &p)
+	assertEqual(t, err, nil)
+	assertEqual(t, p, page{Title: "Hello", Tags: []string{"intro"}})
+	assertEqual(t, string(body), "# Hello\n\ntext\n")
+
+	p = page{}
+	body, err = ExtractFrontMatter([]byte("---\r\ntitle: A\r\n...\r\nbody"), &p)
+	assertEqual(t, err, nil)
+	assertEqual(t, p.Title, "A")
+	assertEqual(t, string(body), "body")
+
+	body, err = ExtractFrontMatter([]byte("---\ntitle: B\n---"), &p)
+	assertEqual(t, err, nil)
+	assertEqual(t, p.Title, "B")
+	assertEqual(t, len(body), 0)
+
+	p = page{}
+	body, err = ExtractFrontMatter([]byte("# No front matter\n---\n"), &p)
+	assertEqual(t, err, nil)
+	assertEqual(t, p, page{})
+	assertEqual(t, string(body), "# No front matter\n---\n")
+
+	_, err = ExtractFrontMatter([]byte("---\ntitle: C\n"), &p)
+	assertEqual(t, fmt.Sprint(err), "yaml: front matter not closed by a --- line")
+
+	_, err = ExtractFrontMatter([]byte("---\ntitle: C\norder: x\n---\n"), &p)
+	var te *TypeError
+	assertEqual(t, errors.As(err, &te), true)
+	assertEqual(t, te.Line, 3)
+}
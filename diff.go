@@ -0,0 +1,154 @@
+package yaml
+
+import "bytes"
+
+// A ChangeKind is the kind of a Change.
+type ChangeKind int
+
+const (
+	ChangeAdded ChangeKind = iota + 1
+	ChangeRemoved
+	ChangeModified
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return "ChangeKind(?)"
+}
+
+// A Change is a difference found by Diff.
+type Change struct {
+	Kind ChangeKind
+
+	// Path is the path of the node, like "servers[2].port",
+	// empty for the whole document.
+	Path string
+
+	// Old and New are the node in the first and in the second
+	// document, with their position, nil when the node is added
+	// or removed.
+	Old, New *Node
+}
+
+// String returns the change as a line like
+// "~ servers[0].port: 80 -> 8080", with the values in JSON.
+func (c *Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "$"
+	}
+	switch c.Kind {
+	case ChangeAdded:
+		return "+ " + path + ": " + nodeText(c.New)
+	case ChangeRemoved:
+		return "- " + path + ": " + nodeText(c.Old)
+	}
+	return "~ " + path + ": " + nodeText(c.Old) + " -> " + nodeText(c.New)
+}
+
+// nodeText returns the compact JSON text of n, or its value when n
+// can not be written in JSON.
+func nodeText(n *Node) string {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, n); err != nil {
+		return n.Value
+	}
+	return buf.String()
+}
+
+// Diff returns the differences between the first documents of a and
+// b, ordered as the nodes of a followed by the nodes added by b. The
+// mappings are compared key by key, whatever their order, the
+// sequences entry by entry, and the scalars by the value of their tag,
+// so that the formatting of the documents is ignored: 1.0 equals 1.00,
+// but not 1 or "1.0". A node whose kind changes is a single change.
+func Diff(a, b []byte) ([]Change, error) {
+	var x, y Node
+	if err := Unmarshal(a, &x); err != nil {
+		return nil, err
+	}
+	if err := Unmarshal(b, &y); err != nil {
+		return nil, err
+	}
+	var d differ
+	d.node(&x, &y)
+	return d.changes, nil
+}
+
+type differ struct {
+	path    []pathElem
+	changes []Change
+}
+
+func (d *differ) change(kind ChangeKind, x, y *Node) {
+	d.changes = append(d.changes, Change{kind, formatPath(d.path), x, y})
+}
+
+func (d *differ) node(x, y *Node) {
+	switch {
+	case x.Kind == 0 && y.Kind == 0:
+	case x.Kind == 0:
+		d.change(ChangeAdded, nil, y)
+	case y.Kind == 0:
+		d.change(ChangeRemoved, x, nil)
+	case x.Kind != y.Kind:
+		d.change(ChangeModified, x, y)
+
+	case x.Kind == ScalarNode:
+		if !scalarEqual(x, y) {
+			d.change(ChangeModified, x, y)
+		}
+
+	case x.Kind == SequenceNode:
+		for i := 0; i < len(x.Content) || i < len(y.Content); i++ {
+			d.path = append(d.path, pathElem{index: i})
+			switch {
+			case i >= len(y.Content):
+				d.change(ChangeRemoved, x.Content[i], nil)
+			case i >= len(x.Content):
+				d.change(ChangeAdded, nil, y.Content[i])
+			default:
+				d.node(x.Content[i], y.Content[i])
+			}
+			d.path = d.path[:len(d.path)-1]
+		}
+
+	default:
+		for i := 0; i+1 < len(x.Content); i += 2 {
+			k := x.Content[i]
+			d.path = append(d.path, pathElem{key: k.Value})
+			if j := nodeKey(y.Content, k); j != -1 {
+				d.node(x.Content[i+1], y.Content[j+1])
+			} else {
+				d.change(ChangeRemoved, x.Content[i+1], nil)
+			}
+			d.path = d.path[:len(d.path)-1]
+		}
+		for i := 0; i+1 < len(y.Content); i += 2 {
+			k := y.Content[i]
+			if nodeKey(x.Content, k) == -1 {
+				d.path = append(d.path, pathElem{key: k.Value})
+				d.change(ChangeAdded, nil, y.Content[i+1])
+				d.path = d.path[:len(d.path)-1]
+			}
+		}
+	}
+}
+
+// scalarEqual reports whether the scalars x and y have the same value,
+// or the same tag and text when their tag can not be resolved.
+func scalarEqual(x, y *Node) bool {
+	vx, errx := resolve(CoreSchema, x.Tag, x.Value)
+	vy, erry := resolve(CoreSchema, y.Tag, y.Value)
+	if errx != nil || erry != nil {
+		return x.Tag == y.Tag && x.Value == y.Value
+	}
+	return vx == vy
+}
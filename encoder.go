@@ -2,56 +2,395 @@ package yaml
 
 import (
 	"bytes"
+	"encoding"
 	"errors"
+	"io"
 	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
 	"reflect"
-	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 func Marshal(v interface{}) ([]byte, error) {
-	return NewEncoder().Encode(v)
+	e := getEncoder()
+	defer putEncoder(e)
+	data, err := e.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	// The buffer goes back to the pool.
+	return append([]byte(nil), data...), nil
 }
 
+// AppendMarshal appends the document of v to dst and returns the
+// extended buffer. dst is not reallocated when it has the capacity.
+func AppendMarshal(dst []byte, v interface{}) ([]byte, error) {
+	e := Encoder{buf: *bytes.NewBuffer(dst)}
+	data, err := e.Encode(v)
+	if err != nil {
+		return dst, err
+	}
+	return data, nil
+}
+
+// MarshalAll returns the documents of the values, separated by ---.
+func MarshalAll(vs []interface{}) ([]byte, error) {
+	return NewEncoder().EncodeAll(vs)
+}
+
+// WriteFile writes the document of v to the file filename. Unlike
+// Marshal, it writes the fields with the "secret" tag option.
 func WriteFile(filename string, v interface{}) error {
-	data, err := NewEncoder().Encode(v)
+	data, err := NewEncoder(WithSecretStyle(SecretShow)).Encode(v)
 	if err != nil {
 		return err
 	}
 	return ioutil.WriteFile(filename, data, 0777)
 }
 
+// WriteFileMode writes the document of v to the file filename with
+// permissions perm. The document is written to a temporary file in
+// the same directory, renamed to filename once complete, so that
+// filename never holds a partial document. As WriteFile, it writes
+// the fields with the "secret" tag option.
+func WriteFileMode(filename string, v interface{}, perm os.FileMode) error {
+	data, err := NewEncoder(WithSecretStyle(SecretShow)).Encode(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // after a failure
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	return err
+}
+
 type Encoder struct {
 	buf bytes.Buffer
+
+	emptyFlow bool
+	nilStyle  NilStyle
+
+	timeLayout    string
+	durationStyle DurationStyle
+
+	docStart  bool
+	docEnd    bool
+	width     int // indentation width, 2 if 0
+	canonical bool
+	sortKeys  bool
+	quoteAll  bool
+	lineWidth int
+	anchor    bool
+	floatFmt  byte // 'g' if 0
+	floatPrec int
+	floatDot  bool
+	escape    EscapeStyle
+	secrets   SecretStyle
+	crypto    *Crypto
+	refs      map[ref]int      // number of pointers to the values, with anchors
+	anchors   map[ref]string   // anchors of the values written
+	nodes     map[string]*Node // anchored nodes written, by anchor
+	visiting  map[ref]bool     // values being written, to detect cycles
+	field     string           // name of the field or key being written
+}
+
+func NewEncoder(opts ...Option) *Encoder {
+	e := &Encoder{}
+	e.apply(opts)
+	return e
+}
+
+// SetEmptyFlow makes empty slices and maps be written as [] and {},
+// so that they are decoded as empty rather than absent collections.
+// By default nothing is written for them.
+func (e *Encoder) SetEmptyFlow(on bool) {
+	e.emptyFlow = on
+}
+
+// A NilStyle is the way nil slices and maps are written.
+type NilStyle int
+
+const (
+	NilDefault NilStyle = iota // as empty ones, see SetEmptyFlow
+	NilNull                    // as null
+	NilEmpty                   // as [] and {}
+	NilOmit                    // omitted with their key, or as null in sequences
+)
+
+// SetNilStyle sets the way nil slices and maps are written.
+func (e *Encoder) SetNilStyle(s NilStyle) {
+	e.nilStyle = s
+}
+
+// isNilCollection reports whether val is a nil slice or map.
+func isNilCollection(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Slice:
+		return val.IsNil() && val.Type() != rawMessageType
+	case reflect.Map:
+		return val.IsNil()
+	}
+	return false
+}
+
+// nilCollection returns the text of the nil collection val.
+func (e *Encoder) nilCollection(val reflect.Value) string {
+	if e.nilStyle != NilEmpty {
+		return "null"
+	}
+	if val.Kind() == reflect.Map || val.Type() == mapSliceType {
+		return "{}"
+	}
+	return "[]"
+}
+
+// omitted reports whether the entry of a mapping with value val
+// is omitted.
+func (e *Encoder) omitted(val reflect.Value) bool {
+	return e.nilStyle == NilOmit && isNilCollection(val)
+}
+
+// SetDocumentStart makes every document start with a --- marker.
+// By default only the documents following another one have it.
+func (e *Encoder) SetDocumentStart(on bool) {
+	e.docStart = on
+}
+
+// SetDocumentEnd makes every document end with a ... marker.
+func (e *Encoder) SetDocumentEnd(on bool) {
+	e.docEnd = on
+}
+
+// SetIndent sets the number of spaces by which the children of a
+// mapping, or the entries of a nested sequence, are indented, which
+// must be between 1 and 9. The default is 2. The content of an entry
+// always starts after "- ".
+func (e *Encoder) SetIndent(n int) {
+	if n < 1 || n > 9 {
+		panic("yaml: indentation width out of range")
+	}
+	e.width = n
 }
 
-func NewEncoder() *Encoder {
-	return &Encoder{}
+// SetCanonical makes equal values be written the same way, to compare
+// or fingerprint the output: the keys of a MapSlice are sorted as the
+// keys of maps, floats always have a decimal point or an exponent,
+// and the line endings of strings are normalized to \n. The strings
+// are written in the plainest style keeping their value, whatever the
+// styles of their fields, SetQuoteAll and SetLineWidth.
+// RawMessage values are written as they are.
+func (e *Encoder) SetCanonical(on bool) {
+	e.canonical = on
+}
+
+// SetSortKeys makes the fields of structs and the keys of a MapSlice
+// be written sorted as the keys of maps, which always are. The nodes
+// are written as they are.
+func (e *Encoder) SetSortKeys(on bool) {
+	e.sortKeys = on
+}
+
+// SetQuoteAll makes every string value be double-quoted, so that
+// no string is read as another type, like no or 1.10.
+func (e *Encoder) SetQuoteAll(on bool) {
+	e.quoteAll = on
+}
+
+// SetLineWidth makes the strings having lines longer than width
+// be written as folded block scalars, wrapped at spaces. By default,
+// lines are not wrapped.
+func (e *Encoder) SetLineWidth(width int) {
+	e.lineWidth = width
+}
+
+// SetFloatFormat sets the format and the precision of floats,
+// as in strconv.FormatFloat. By default, floats are written with
+// the 'g' format and the smallest precision reading them back.
+func (e *Encoder) SetFloatFormat(fmt byte, prec int) {
+	if strings.IndexByte("eEfgG", fmt) == -1 {
+		panic("yaml: invalid float format " + strconv.QuoteRune(rune(fmt)))
+	}
+	e.floatFmt = fmt
+	e.floatPrec = prec
+}
+
+// SetFloatPoint makes floats always have a decimal point,
+// such as 1.0 or 1.0e+06, so that they are not read as integers.
+func (e *Encoder) SetFloatPoint(on bool) {
+	e.floatDot = on
+}
+
+// SetAnchors makes a value pointed to several times be written once,
+// with an anchor, and be referred to by aliases elsewhere, such as:
+//
+//	server: &a1
+//	  host: example.com
+//	backup: *a1
+//
+// Decoding the aliases into pointers makes them share the value.
+func (e *Encoder) SetAnchors(on bool) {
+	e.anchor = on
+}
+
+// step returns the indentation width.
+func (e *Encoder) step() int {
+	if e.width == 0 {
+		return 2
+	}
+	return e.width
 }
 
 func (e *Encoder) Reset() {
 	e.buf.Reset()
 }
 
-func (e *Encoder) Encode(i interface{}) (data []byte, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			if _, ok := r.(runtime.Error); ok {
-				panic(err)
-			}
-			err = r.(error)
+// An EncoderW encodes values to a writer, one document per value.
+// Successive documents are separated by ---.
+type EncoderW struct {
+	Encoder
+	w    io.Writer
+	docs int
+}
+
+// NewEncoderW returns an encoder writing to w. Each document is
+// written to w as soon as it is encoded, so that only the document
+// being encoded is held in memory.
+func NewEncoderW(w io.Writer, opts ...Option) *EncoderW {
+	e := &EncoderW{w: w}
+	e.apply(opts)
+	return e
+}
+
+// Encode writes the document of v to w. Nothing is written when
+// v can not be encoded.
+func (e *EncoderW) Encode(v interface{}) error {
+	e.Encoder.Reset()
+	if e.docs > 0 && !e.docStart {
+		e.buf.WriteString("---\n")
+	}
+	if _, err := e.Encoder.Encode(v); err != nil {
+		return err
+	}
+	e.docs++
+	_, err := e.buf.WriteTo(e.w)
+	return err
+}
+
+// EncodeAll writes the documents of the values to w, up to the first
+// error.
+func (e *EncoderW) EncodeAll(vs []interface{}) error {
+	for _, v := range vs {
+		if err := e.Encode(v); err != nil {
+			return err
 		}
-	}()
+	}
+	return nil
+}
 
-	val := reflect.ValueOf(i)
-	e.value(reflect.Indirect(val), 0, stateDefault)
-	data = e.buf.Bytes()
-	return
+// Flush flushes w when it has a Flush method, like a bufio.Writer.
+// The documents are not buffered by the encoder itself.
+func (e *EncoderW) Flush() error {
+	if f, ok := e.w.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
 }
 
-func (e *Encoder) error(info string) {
-	panic(errors.New(info))
+func (e *Encoder) Encode(i interface{}) ([]byte, error) {
+	if err := e.document(i, true); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// EncodeAll encodes the values as successive documents,
+// separated by ---.
+func (e *Encoder) EncodeAll(vs []interface{}) ([]byte, error) {
+	for i, v := range vs {
+		if err := e.document(v, i == 0); err != nil {
+			return nil, err
+		}
+	}
+	return e.buf.Bytes(), nil
+}
+
+// document encodes v as a document, with the markers it needs.
+func (e *Encoder) document(v interface{}, first bool) error {
+	if e.docStart || !first {
+		e.buf.WriteString("---\n")
+	}
+	e.refs, e.anchors, e.nodes = nil, nil, nil
+	if e.anchor {
+		e.refs, e.anchors = make(map[ref]int), make(map[ref]string)
+		e.countRefs(reflect.ValueOf(v))
+	}
+	if err := e.value(reflect.ValueOf(v), 0, stateDefault); err != nil {
+		return err
+	}
+	if e.docEnd {
+		e.buf.WriteString("...\n")
+	}
+	return nil
+}
+
+func (e *Encoder) error(info string) error {
+	return errors.New(info)
+}
+
+func (e *Encoder) unsupported(info string) error {
+	return &causeError{info, ErrUnsupportedType}
+}
+
+// cycle returns the error of a value containing itself.
+func (e *Encoder) cycle() error {
+	if e.field == "" {
+		return e.error("cyclic reference")
+	}
+	return e.error("cyclic reference via field " + e.field)
+}
+
+// refOf returns the ref of the value val refers to, if any.
+func refOf(val reflect.Value) (ref, bool) {
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Map:
+		if !val.IsNil() {
+			return ref{val.Pointer(), val.Type()}, true
+		}
+	case reflect.Slice:
+		if val.Len() != 0 {
+			return ref{val.Pointer(), val.Type()}, true
+		}
+	}
+	return ref{}, false
+}
+
+// blockStart ends the line of a key whose value is a block collection.
+func (e *Encoder) blockStart() {
+	if b := e.buf.Bytes(); len(b) > 0 && b[len(b)-1] == ' ' {
+		e.buf.Truncate(len(b) - 1)
+	}
+	e.buf.WriteByte('\n')
 }
 
 func (e *Encoder) indent(n int) {
@@ -60,27 +399,95 @@ func (e *Encoder) indent(n int) {
 	}
 }
 
-func (e *Encoder) value(val reflect.Value, indent, state int) {
+func (e *Encoder) value(val reflect.Value, indent, state int) error {
+	if !val.IsValid() {
+		e.buf.WriteString("null\n")
+		return nil
+	}
+	return e.encode(planOf(val.Type()), val, indent, state)
+}
+
+// encode encodes val, whose type has the plan p.
+func (e *Encoder) encode(p *plan, val reflect.Value, indent, state int) error {
+	if r, ok := refOf(val); ok {
+		if e.visiting[r] {
+			if _, ok := e.anchors[r]; !ok {
+				return e.cycle()
+			}
+		} else {
+			if e.visiting == nil {
+				e.visiting = make(map[ref]bool)
+			}
+			e.visiting[r] = true
+			defer delete(e.visiting, r)
+		}
+	}
+	switch p.class {
+	case planNode:
+		n := val.Interface().(Node)
+		return e.node(&n, indent, state)
+	case planTime:
+		text, _ := e.timeText(val)
+		e.buf.WriteString(text)
+		e.buf.WriteByte('\n')
+		return nil
+	}
+	if e.nilStyle != NilDefault && isNilCollection(val) {
+		e.buf.WriteString(e.nilCollection(val))
+		e.buf.WriteByte('\n')
+		return nil
+	}
 	switch val.Kind() {
-	case reflect.Int, reflect.Int64:
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		e.buf.WriteString(strconv.FormatInt(val.Int(), 10))
 		e.buf.WriteByte('\n')
 
-	case reflect.Float64:
-		e.buf.WriteString(strconv.FormatFloat(val.Float(), 'g', -1, 64))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		e.buf.WriteString(strconv.FormatUint(val.Uint(), 10))
+		e.buf.WriteByte('\n')
+
+	case reflect.Float32, reflect.Float64:
+		e.buf.WriteString(e.formatFloat(val.Float(), val.Type().Bits()))
 		e.buf.WriteByte('\n')
 
 	case reflect.String:
-		e.string(val.String(), indent)
+		str := val.String()
+		if e.canonical {
+			str = strings.ReplaceAll(str, "\r\n", "\n")
+			str = strings.ReplaceAll(str, "\r", "\n")
+		}
+		if e.quoteAll && !e.canonical {
+			e.buf.WriteString(e.quote(str))
+		} else {
+			e.string(str, indent, state)
+		}
 		e.buf.WriteByte('\n')
 
 	case reflect.Bool:
 		e.buf.WriteString(strconv.FormatBool(val.Bool()))
 		e.buf.WriteByte('\n')
 
+	case reflect.Ptr:
+		if val.IsNil() {
+			e.buf.WriteString("null\n")
+			break
+		}
+		return e.pointer(p, val, indent, state)
+
 	case reflect.Slice:
+		if p.class == planMapSlice {
+			return e.mapSlice(val.Interface().(MapSlice), indent, state)
+		}
+		if p.class == planRaw {
+			e.rawMessage(val.Bytes(), indent, state)
+			break
+		}
+		if e.emptyFlow && val.Len() == 0 {
+			e.buf.WriteString("[]\n")
+			break
+		}
 		if state == stateObjectValue {
-			e.buf.WriteByte('\n')
+			e.blockStart()
 		}
 
 		for i,n := 0, val.Len(); i<n; i++ {
@@ -89,114 +496,495 @@ func (e *Encoder) value(val reflect.Value, indent, state int) {
 			}
 			e.buf.WriteByte('-')
 			e.buf.WriteByte(' ')
-			e.value(val.Index(i), indent+2, stateListElem)
+			n := e.buf.Len()
+			if err := e.encode(p.elem, val.Index(i), indent+2, stateListElem); err != nil {
+				return err
+			}
+			if e.buf.Len() == n {
+				// An empty collection must still end the entry.
+				e.buf.WriteByte('\n')
+			}
 		}
 
 	case reflect.Map:
+		if e.emptyFlow && val.Len() == 0 {
+			e.buf.WriteString("{}\n")
+			break
+		}
 		if state == stateObjectValue {
-			e.buf.WriteByte('\n')
+			e.blockStart()
 		}
 
-		for i, key := range val.MapKeys() {
+		keys := val.MapKeys()
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			name, err := e.keyString(key)
+			if err != nil {
+				return err
+			}
+			names[i] = name
+		}
+		i := 0
+		for _, j := range keyOrder(names) {
+			key := keys[j]
+			if e.omitted(val.MapIndex(key)) {
+				continue
+			}
 			if i != 0 || state != stateListElem {
 				e.indent(indent)
 			}
-			e.key(key.String())
+			e.buf.WriteString(names[j])
 			e.buf.WriteByte(':')
 			e.buf.WriteByte(' ')
-			e.value(val.MapIndex(key), indent+2, stateObjectValue)
-			e.buf.WriteByte('\n')
+			e.field = names[j]
+			if err := e.encode(p.elem, val.MapIndex(key), indent+e.step(), stateObjectValue); err != nil {
+				return err
+			}
+			i++
 		}
 
 	case reflect.Struct:
 		if state == stateObjectValue {
-			e.buf.WriteByte('\n')
+			e.blockStart()
 		}
 
-		t := val.Type()
-		needIdent := state != stateListElem
-		var name string
-		for i, n := 0, t.NumField(); i < n; i++ {
-			f := t.Field(i)
-			if f.PkgPath == "" {
-				name = f.Tag.Get("yaml")
-				fv := val.Field(i)
-				if name == "" {
-					name = f.Name
-				} else {
-					if i := strings.Index(name, ","); i != -1 {
-						if strings.Index(name, "omitempty") != -1 {
-							switch fv.Kind() {
-							case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
-								if fv.Len() == 0 {
-									continue
-								}
-							}
-						}
-						name = name[:i]
-					}
-				}
-
-				if needIdent {
-					e.indent(indent)
-				} else {
-					needIdent = true
-				}
-				e.key(name)
-				e.buf.WriteByte(':')
-				e.buf.WriteByte(' ')
-				e.value(fv, indent+2, stateObjectValue)
-				e.buf.WriteByte('\n')
+		i := 0
+		for j := range p.st.tags {
+			if e.sortKeys {
+				j = p.sorted[j]
+			}
+			f, ok, err := e.structField(p.st.tags[j], p.fields[j], val.Field(p.st.index[j]))
+			if err != nil {
+				return err
 			}
+			if !ok {
+				continue
+			}
+			if i != 0 || state != stateListElem {
+				e.indent(indent)
+			}
+			if e.escaped(f.name) {
+				e.key(f.name)
+			} else {
+				e.buf.WriteString(p.keys[j])
+			}
+			e.buf.WriteByte(':')
+			e.buf.WriteByte(' ')
+			e.field = f.name
+			if err := e.styled(f, indent+e.step(), stateObjectValue); err != nil {
+				return err
+			}
+			i++
 		}
 
+	case reflect.Interface:
+		if val.IsNil() {
+			e.buf.WriteString("null\n")
+			break
+		}
+		return e.value(val.Elem(), indent, state)
+
 	default:
-		e.error("unsupported type "+val.Type().String())
+		return e.unsupported("unsupported type " + val.Type().String())
+	}
+	return nil
+}
+
+// A field is a field of a struct to encode.
+type field struct {
+	name  string
+	value reflect.Value
+	p     *plan  // of the type of value
+	style string // literal, folded or quoted, if set by the tag
+}
+
+// structField returns the field of a struct with the tag, the value fv
+// and the plan p, and reports whether it is written.
+func (e *Encoder) structField(tag fieldTag, p *plan, fv reflect.Value) (field, bool, error) {
+	if tag.omitEmpty && isEmptyValue(fv) || tag.omitZero && isZeroValue(fv) || e.omitted(fv) {
+		return field{}, false, nil
+	}
+	if tag.secret {
+		ev, encrypted, err := e.encrypted(tag.name, fv)
+		if err != nil {
+			return field{}, false, err
+		}
+		var ok bool
+		if encrypted {
+			fv = ev
+		} else if fv, ok = e.secret(fv); !ok {
+			return field{}, false, nil
+		}
+		if fv.IsValid() && fv.Type() != p.t {
+			p = planOf(fv.Type())
+		}
+	}
+	return field{tag.name, fv, p, tag.style}, true, nil
+}
+
+// An IsZeroer reports whether it is zero. The fields tagged omitempty
+// or omitzero are omitted when their value is an IsZeroer which is zero.
+type IsZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*IsZeroer)(nil)).Elem()
+
+// zeroer returns the result of the IsZero method of v, if any.
+func zeroer(v reflect.Value) (zero, ok bool) {
+	switch {
+	case v.Type().Implements(isZeroerType):
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return true, true
+		}
+		return v.Interface().(IsZeroer).IsZero(), true
+	case v.CanAddr() && reflect.PtrTo(v.Type()).Implements(isZeroerType):
+		return v.Addr().Interface().(IsZeroer).IsZero(), true
+	}
+	return false, false
+}
+
+// isZeroValue reports whether v is zero for omitzero.
+func isZeroValue(v reflect.Value) bool {
+	if zero, ok := zeroer(v); ok {
+		return zero
+	}
+	return v.IsZero()
+}
+
+// isEmptyValue reports whether v is empty for omitempty:
+// false, 0, a nil pointer or interface, of length 0,
+// or an IsZeroer which is zero.
+func isEmptyValue(v reflect.Value) bool {
+	if zero, ok := zeroer(v); ok {
+		return zero
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// styled writes the value of a field in the style set by its tag,
+// when the style applies to the value.
+func (e *Encoder) styled(f field, indent, state int) error {
+	switch {
+	case e.canonical:
+	case f.style == "quoted" && f.value.Kind() == reflect.String:
+		e.buf.WriteString(e.quote(f.value.String()))
+		e.buf.WriteByte('\n')
+		return nil
+	case (f.style == "literal" || f.style == "folded") && f.value.Kind() == reflect.String && f.value.Len() > 0:
+		e.block(f.value.String(), indent, state, f.style == "folded")
+		e.buf.WriteByte('\n')
+		return nil
+	}
+	return e.encode(f.p, f.value, indent, state)
+}
+
+func (e *Encoder) formatFloat(f float64, bits int) string {
+	if e.canonical && f == 0 {
+		return "0.0" // no negative zero
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return formatFloat(f)
+	}
+
+	var s string
+	if e.floatFmt == 0 {
+		s = strconv.FormatFloat(f, 'g', -1, bits)
+	} else {
+		s = strconv.FormatFloat(f, e.floatFmt, e.floatPrec, bits)
+	}
+	switch {
+	case e.floatDot && strings.IndexByte(s, '.') == -1:
+		// Before the exponent, if any.
+		if i := strings.IndexAny(s, "eE"); i != -1 {
+			return s[:i] + ".0" + s[i:]
+		}
+		return s + ".0"
+	case e.canonical && strings.IndexAny(s, ".eE") == -1:
+		return s + ".0"
+	}
+	return s
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// keyOrder returns the indexes of the key texts in names, sorted
+// so that the output does not change between runs: numeric keys
+// in numeric order first, then the other keys in lexical order.
+func keyOrder(names []string) []int {
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Sort(keySorter{order, names})
+	return order
+}
+
+type keySorter struct {
+	order []int
+	names []string
+}
+
+func (s keySorter) Len() int      { return len(s.order) }
+func (s keySorter) Swap(i, j int) { s.order[i], s.order[j] = s.order[j], s.order[i] }
+
+func (s keySorter) Less(i, j int) bool {
+	x, y := s.names[s.order[i]], s.names[s.order[j]]
+	fx, errx := numericKey(unquoteKey(x))
+	fy, erry := numericKey(unquoteKey(y))
+	switch {
+	case errx == nil && erry == nil && fx != fy:
+		return fx < fy
+	case errx == nil && erry != nil:
+		return true
+	case errx != nil && erry == nil:
+		return false
+	}
+	return x < y
+}
+
+// unquoteKey returns the key text s without its quotes.
+func unquoteKey(s string) string {
+	if u, err := strconv.Unquote(s); err == nil {
+		return u
+	}
+	return s
+}
+
+func numericKey(s string) (float64, error) {
+	if !isNumber(s) {
+		return 0, strconv.ErrSyntax
+	}
+	return parseFloat(s)
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// keyString returns the text of a map key. String keys are quoted
+// as needed, so that they are not read as other types.
+func (e *Encoder) keyString(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	if !key.IsValid() || key.Kind() == reflect.Interface {
+		return "", e.error("unsupported nil map key")
+	}
+	if key.Kind() != reflect.String && key.Type().Implements(textMarshalerType) {
+		text, err := key.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", e.error(err.Error())
+		}
+		return e.keyText(string(text)), nil
+	}
+	switch key.Kind() {
+	case reflect.String:
+		return e.keyText(key.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return e.formatFloat(key.Float(), key.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(key.Bool()), nil
 	}
+	return "", e.unsupported("unsupported map key type " + key.Type().String())
 }
 
 func (e *Encoder) key(key string) {
-	if strings.IndexAny(key, "\n\r\t  #") != -1 {
-		key = strconv.Quote(key)
+	e.buf.WriteString(e.keyText(key))
+}
+
+// keyText returns the string key s, quoted if it would not be read
+// as the same string.
+func keyText(s string) string {
+	if s == "" || strings.IndexAny(s, "\n\r\t  #") != -1 ||
+		resolveTag(CoreSchema, s) != tagStr ||
+		strings.ContainsAny(s[:1], "-?:,[]{}&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.HasSuffix(s, ":") {
+		return strconv.Quote(s)
 	}
-	e.buf.WriteString(key)
+	return s
 }
 
-func (e *Encoder) string(str string, indent int) {
-	if str == "" {
-		return
+func (e *Encoder) string(str string, indent, state int) {
+	long := false
+	if e.lineWidth > 0 && !e.canonical {
+		for _, line := range strings.Split(str, "\n") {
+			if indent+len(line) > e.lineWidth && strings.IndexByte(line, ' ') != -1 {
+				long = true
+			}
+		}
 	}
 
-	i := strings.IndexByte(str, '\n')
-	if i == -1 {
-		if strings.IndexByte(str, '#') != -1 {
-			e.buf.WriteByte('\n')
-			e.indent(indent)
+	if e.escaped(str) {
+		e.buf.WriteString(e.quote(str))
+		return
+	}
+	if strings.IndexByte(str, '\n') == -1 && !long {
+		if needsQuote(str) {
+			str = e.quote(str)
 		}
 		e.buf.WriteString(str)
 		return
 	}
 
-	if str[len(str)-1] == '\n' {
-		e.buf.WriteByte('>')
-		str = str[:len(str)-1]
+	// Multi-line strings are written as literal block scalars,
+	// and long strings as folded block scalars.
+	e.block(str, indent, state, long)
+}
+
+// wrap splits s at single spaces, which folding restores,
+// into lines not longer than width where possible.
+func wrap(s string, width int) []string {
+	var lines []string
+	for len(s) > width {
+		i := -1
+		for j := 1; j+1 < len(s); j++ {
+			if s[j] == ' ' && s[j-1] != ' ' && s[j+1] != ' ' {
+				if j > width && i != -1 {
+					break
+				}
+				i = j
+			}
+		}
+		if i == -1 {
+			break
+		}
+		lines = append(lines, s[:i])
+		s = s[i+1:]
 	}
-	e.buf.WriteByte('\n')
+	return append(lines, s)
+}
 
-	var line string
-	for ; i != -1; i = strings.IndexByte(str, '\n') {
-		line, str = str[:i+1], str[i+1:]
-		if len(line) != 1 {
-			e.indent(indent)
-			e.buf.WriteString(line)
-		} else {
-			e.buf.WriteByte('\n')
+// needsQuote reports whether the single-line string s must be quoted
+// to be read as the same string, by this package or other parsers:
+// if it would be read as another type, including the booleans and
+// sexagesimal numbers of YAML 1.1, or it is not a plain scalar.
+func needsQuote(s string) bool {
+	if s == "" || s != strings.TrimSpace(s) {
+		return true
+	}
+	if isNull(s) || isNumber(s) || isSexagesimal(s) {
+		return true
+	}
+	switch s {
+	case "y", "Y", "yes", "Yes", "YES", "n", "N", "no", "No", "NO",
+		"true", "True", "TRUE", "false", "False", "FALSE",
+		"on", "On", "ON", "off", "Off", "OFF":
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") ||
+		strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") {
+		return true
+	}
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+// isSexagesimal reports whether s is a base 60 number
+// of YAML 1.1, like 08:30.
+func isSexagesimal(s string) bool {
+	if s[0] < '0' || s[0] > '9' || strings.IndexByte(s, ':') == -1 {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if (c < '0' || c > '9') && c != ':' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// block writes str as a block scalar, literal or folded,
+// with chomping and indentation indicators as needed.
+func (e *Encoder) block(str string, indent, state int, folded bool) {
+	body := strings.TrimRight(str, "\n")
+	n := len(str) - len(body)
+	lines := strings.Split(body, "\n")
+	for _, line := range lines {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			// The line breaks around more indented lines
+			// are not folded.
+			folded = false
+		}
+	}
+
+	if folded {
+		e.buf.WriteByte('>')
+	} else {
+		e.buf.WriteByte('|')
+	}
+	if body != "" && body[0] == ' ' {
+		// The indicator is relative to the parent node.
+		step := e.step()
+		if state == stateListElem {
+			step = 2
+		}
+		e.buf.WriteByte(byte('0' + step))
+		if indent == 0 {
+			indent = step
 		}
-		e.buf.WriteByte('\n')
+	}
+	switch {
+	case n == 0:
+		e.buf.WriteByte('-')
+	case n > 1:
+		e.buf.WriteByte('+')
 	}
 
-	if len(str) != 0 {
+	for i, line := range lines {
+		e.buf.WriteByte('\n')
+		if folded && i > 0 {
+			// A line break is kept by an empty line.
+			e.buf.WriteByte('\n')
+		}
+		if line == "" {
+			continue
+		}
+		if folded && e.lineWidth > 0 {
+			for j, part := range wrap(line, e.lineWidth-indent) {
+				if j > 0 {
+					e.buf.WriteByte('\n')
+				}
+				e.indent(indent)
+				e.buf.WriteString(part)
+			}
+			continue
+		}
 		e.indent(indent)
-		e.buf.WriteString(str)
+		e.buf.WriteString(line)
+	}
+	for ; n > 1; n-- {
+		e.buf.WriteByte('\n')
 	}
 }
@@ -0,0 +1,83 @@
+package yaml
+
+import (
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// toUTF8 detects the character encoding of data, following the YAML
+// specification, and returns data as UTF-8 without byte order mark.
+// UTF-16 input, with or without byte order mark, is transcoded.
+func toUTF8(data []byte) []byte {
+	switch {
+	case len(data) >= 3 && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF:
+		return data[3:]
+	case len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF:
+		return utf16ToUTF8(data[2:], true)
+	case len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE:
+		return utf16ToUTF8(data[2:], false)
+	case len(data) >= 2 && data[0] == 0 && data[1] != 0:
+		// The first character of a document is ASCII.
+		return utf16ToUTF8(data, true)
+	case len(data) >= 2 && data[0] != 0 && data[1] == 0:
+		return utf16ToUTF8(data, false)
+	}
+	return data
+}
+
+func utf16ToUTF8(data []byte, bigEndian bool) []byte {
+	u := make([]uint16, len(data)/2)
+	for i := range u {
+		if bigEndian {
+			u[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			u[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	buf := make([]byte, 0, len(u))
+	for _, r := range utf16.Decode(u) {
+		buf = utf8.AppendRune(buf, r)
+	}
+	if len(data)%2 != 0 {
+		buf = utf8.AppendRune(buf, utf8.RuneError)
+	}
+	return buf
+}
+
+// SetValidUTF8 makes the decoder check that the text of each document
+// is valid UTF-8 before decoding it, and fail with ErrInvalidUTF8 at the
+// offset of the first invalid sequence. Otherwise, invalid sequences are
+// copied into the decoded strings.
+func (d *Decoder) SetValidUTF8(on bool) {
+	d.validUTF8 = on
+}
+
+// checkUTF8 fails on the first invalid UTF-8 sequence of the text,
+// when the decoder validates it.
+func (d *Decoder) checkUTF8() error {
+	if !d.validUTF8 || d.validated {
+		return nil
+	}
+	if off := invalidUTF8(d.data); off != -1 {
+		d.off = off
+		return d.syntaxError("", "invalid UTF-8", ErrInvalidUTF8)
+	}
+	d.validated = true
+	return nil
+}
+
+// invalidUTF8 returns the offset of the first invalid UTF-8
+// sequence of data, or -1.
+func invalidUTF8(data []byte) int {
+	if utf8.Valid(data) {
+		return -1
+	}
+	for off := 0; off < len(data); {
+		r, n := utf8.DecodeRune(data[off:])
+		if r == utf8.RuneError && n == 1 {
+			return off
+		}
+		off += n
+	}
+	return -1
+}
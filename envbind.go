@@ -0,0 +1,96 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// BindEnv sets the fields of the struct pointed to by v from the
+// environment variables named after their keys, so that they override
+// the values decoded from a document. The name of the variable of a
+// field is prefix followed by its key and the keys of the structs
+// holding it, in upper case and separated by underscores: with the
+// prefix "APP_", APP_SERVER_PORT is the variable of server.port.
+// The value of a variable is decoded as a plain scalar into the field,
+// following its type. The fields which are sequences or mappings are
+// not bound.
+func BindEnv(v interface{}, prefix string) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return errors.New("yaml: BindEnv expects a pointer")
+	}
+	return envVars(val.Type().Elem(), prefix, true, nil, func(path []pathElem, name, s string) error {
+		data, err := Marshal(&Node{Kind: ScalarNode, Value: s})
+		if err != nil {
+			return err
+		}
+		if err := NewDecoderBytes(data).Decode(fieldAt(val.Elem(), path).Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// envVars calls f with the path, the name and the value of each
+// variable set bound to a value of type t, named name, or to its
+// fields, named after name.
+func envVars(t reflect.Type, name string, root bool, path []pathElem, f func(path []pathElem, name, s string) error) error {
+	switch {
+	case t.Kind() == reflect.Struct && t != timeType:
+		if !root {
+			name += "_"
+		}
+		st := structFields(t)
+		for i, tag := range st.tags {
+			p := append(path[:len(path):len(path)], pathElem{key: tag.name})
+			if err := envVars(t.Field(st.index[i]).Type, name+envName(tag.name), false, p, f); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct && t.Elem() != timeType:
+		return envVars(t.Elem(), name, root, path, f)
+
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Map || root:
+		return nil
+	}
+
+	if s, ok := os.LookupEnv(name); ok {
+		return f(path, name, s)
+	}
+	return nil
+}
+
+// fieldAt returns the field of the struct val at path, a list of keys,
+// allocating the nil structs holding it.
+func fieldAt(val reflect.Value, path []pathElem) reflect.Value {
+	for _, elem := range path {
+		if val.Kind() == reflect.Ptr {
+			if val.IsNil() {
+				val.Set(reflect.New(val.Type().Elem()))
+			}
+			val = val.Elem()
+		}
+		st := structFields(val.Type())
+		val = val.Field(st.index[st.byName[elem.key]])
+	}
+	return val
+}
+
+// envName returns the key in upper case, with the characters other
+// than letters and digits replaced by underscores.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
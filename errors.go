@@ -0,0 +1,230 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// The causes of the errors of decoding and encoding,
+// for errors.Is.
+var (
+	ErrUnknownField    = errors.New("yaml: unknown field")
+	ErrMissingField    = errors.New("yaml: missing required field")
+	ErrDuplicateKey    = errors.New("yaml: duplicate key")
+	ErrUnsupportedType = errors.New("yaml: unsupported type")
+	ErrOverflow        = errors.New("yaml: value out of range")
+	ErrInvalidUTF8     = errors.New("yaml: invalid UTF-8")
+)
+
+// Errors lists the errors of a document decoded by a decoder
+// reporting all errors, see Decoder.SetAllErrors.
+type Errors []error
+
+func (e Errors) Error() string {
+	s := make([]string, len(e))
+	for i, err := range e {
+		s[i] = err.Error()
+	}
+	return strings.Join(s, "\n")
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// SetAllErrors makes the decoder go on after the scalars which can not
+// be decoded into their fields, which are left unchanged, and after the
+// undefined fields, and return all the errors of a document in Errors.
+func (d *Decoder) SetAllErrors(on bool) {
+	d.allErrors = on
+}
+
+// fieldError returns err, the error of a scalar, or records it and
+// returns nil when it is a TypeError or a RangeError and the decoder
+// reports all errors.
+func (d *Decoder) fieldError(err error) error {
+	if !d.allErrors {
+		return err
+	}
+	switch err.(type) {
+	case *TypeError, *RangeError:
+		d.errs = append(d.errs, err)
+		return nil
+	}
+	return err
+}
+
+// collected sets *err to the errors recorded while decoding,
+// followed by the error which stopped the decoding if any.
+func (d *Decoder) collected(err *error) {
+	if len(d.errs) == 0 {
+		return
+	}
+	errs := Errors(d.errs)
+	if *err != nil {
+		errs = append(errs, *err)
+	}
+	*err = errs
+	d.errs = nil
+}
+
+// A SyntaxError describes a document which is not well-formed, or
+// not supported by this package.
+type SyntaxError struct {
+	Msg   string
+	Field string // path of the node, like "servers[2].port", if any
+	Err   error  // cause, like ErrDuplicateKey, if any
+
+	// Line and Column are 1-based, and Offset is the byte offset
+	// in the input, of the error.
+	Line, Column, Offset int
+}
+
+func (e *SyntaxError) Error() string {
+	return errorText(e.Field, e.Msg, e.Line, e.Column)
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// A TypeError describes a node which can not be decoded into the Go
+// value of its field, such as a mapping into an int.
+type TypeError struct {
+	Msg   string
+	Field string
+	Type  reflect.Type // of the Go value
+	Err   error        // cause, like a *strconv.NumError, if any
+
+	Line, Column, Offset int
+}
+
+func (e *TypeError) Error() string {
+	return errorText(e.Field, e.Msg, e.Line, e.Column)
+}
+
+func (e *TypeError) Unwrap() error {
+	return e.Err
+}
+
+// An UnknownFieldError describes a key of a mapping which is not
+// the name of a field of the struct it is decoded into.
+type UnknownFieldError struct {
+	Field string // path of the mapping
+	Key   string
+	Type  reflect.Type // of the struct
+
+	Line, Column, Offset int
+}
+
+func (e *UnknownFieldError) Error() string {
+	return errorText(e.Field, "undefined field "+e.Key+" of "+e.Type.String(), e.Line, e.Column)
+}
+
+func (e *UnknownFieldError) Unwrap() error {
+	return ErrUnknownField
+}
+
+func errorText(field, msg string, line, column int) string {
+	return fmt.Sprintf("%s at line %d, column %d", strings.TrimSpace(field+" "+msg), line, column)
+}
+
+// position returns the 1-based line and column of offset off.
+func (d *Decoder) position(off int) (line, column int) {
+	if off > len(d.data) {
+		off = len(d.data)
+	}
+	if len(d.lines.starts) != 0 {
+		n := d.lines.lineAt(off)
+		return d.baseLine + n + 1, off - d.lines.starts[n] + 1
+	}
+	return d.baseLine + bytes.Count(d.data[:off], []byte{'\n'}) + 1, off - bytes.LastIndexByte(d.data[:off], '\n')
+}
+
+// offset returns the offset in the input of offset off of the document.
+func (d *Decoder) offset(off int) int {
+	return int(d.base) + off
+}
+
+// skipSpaces returns the offset of the node at offset off,
+// after the spaces preceding it.
+func (d *Decoder) skipSpaces(off int) int {
+	for off < len(d.data) && d.data[off] == ' ' {
+		off++
+	}
+	return off
+}
+
+func (d *Decoder) error(name, info string) error {
+	return d.syntaxError(name, info, nil)
+}
+
+// syntaxError returns the error at the current offset,
+// with the cause err.
+func (d *Decoder) syntaxError(name, info string, err error) error {
+	line, column := d.position(d.off)
+	return &SyntaxError{info, d.field(name), err, line, column, d.offset(d.off)}
+}
+
+// typeError returns the error of the node at offset off, which can
+// not be decoded into a value of type t, with the cause err.
+func (d *Decoder) typeError(name, info string, err error, t reflect.Type, off int) error {
+	off = d.skipSpaces(off)
+	line, column := d.position(off)
+	return &TypeError{info, d.field(name), t, err, line, column, d.offset(off)}
+}
+
+// unknownField returns the error of the undefined field key of type t,
+// or records it and returns nil when the decoder reports all errors.
+func (d *Decoder) unknownField(key string, t reflect.Type, off int) error {
+	off = d.skipSpaces(off)
+	line, column := d.position(off)
+	err := &UnknownFieldError{d.field(""), key, t, line, column, d.offset(off)}
+	if d.allErrors {
+		d.errs = append(d.errs, err)
+		return nil
+	}
+	return err
+}
+
+// A RangeError describes a scalar which can not be represented
+// by the type of its target, such as 300 for an int8 field
+// or 1.5 for an int field.
+type RangeError struct {
+	Field string
+	Value string
+	Type  reflect.Type
+
+	Line, Column, Offset int
+}
+
+func (e *RangeError) Error() string {
+	return errorText(e.Field, e.Value+" out of range of "+e.Type.String(), e.Line, e.Column)
+}
+
+func (e *RangeError) Unwrap() error {
+	return ErrOverflow
+}
+
+func (d *Decoder) rangeError(name, value string, t reflect.Type, off int) error {
+	off = d.skipSpaces(off)
+	line, column := d.position(off)
+	return &RangeError{d.field(name), value, t, line, column, d.offset(off)}
+}
+
+// causeError is an error of the encoder with a cause, for errors.Is.
+type causeError struct {
+	msg string
+	err error
+}
+
+func (e *causeError) Error() string {
+	return e.msg
+}
+
+func (e *causeError) Unwrap() error {
+	return e.err
+}
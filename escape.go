@@ -0,0 +1,56 @@
+package yaml
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// An EscapeStyle is the set of characters which make strings be
+// written double-quoted, with the characters escaped.
+type EscapeStyle int
+
+const (
+	EscapeNone    EscapeStyle = iota // none, control characters are escaped in quoted strings only
+	EscapeControl                    // control and other non-printable characters
+	EscapeASCII                      // those and the non-ASCII characters
+)
+
+// SetEscape makes the strings and keys holding characters of style
+// be written double-quoted, with the characters escaped as \x, \u
+// or \U sequences, even when the strings span several lines.
+// The output of EscapeASCII holds ASCII characters only.
+func (e *Encoder) SetEscape(style EscapeStyle) {
+	e.escape = style
+}
+
+// escaped reports whether s holds characters to escape.
+func (e *Encoder) escaped(s string) bool {
+	if e.escape == EscapeNone {
+		return false
+	}
+	for _, r := range s {
+		if r >= utf8.RuneSelf && e.escape == EscapeASCII {
+			return true
+		}
+		if r != '\n' && r != '\t' && !strconv.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// quote returns s double-quoted.
+func (e *Encoder) quote(s string) string {
+	if e.escape == EscapeASCII {
+		return strconv.QuoteToASCII(s)
+	}
+	return strconv.Quote(s)
+}
+
+// keyText returns the text of the string key s.
+func (e *Encoder) keyText(s string) string {
+	if e.escaped(s) {
+		return e.quote(s)
+	}
+	return keyText(s)
+}
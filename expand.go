@@ -0,0 +1,92 @@
+package yaml
+
+import (
+	"os"
+	"strings"
+)
+
+// ExpandEnv makes the decoder replace the references to environment
+// variables in scalar values, before they are converted to the type
+// of their field:
+//
+//	url: postgres://${DB_HOST}:${DB_PORT:-5432}/app
+//
+// ${VAR} is the value of VAR, which must be set, ${VAR:-default} is
+// default when VAR is unset or empty, and $${ is a literal ${. When
+// allow is not empty, only the variables it names can be referenced,
+// and those named by deny never can. A name ending with * matches the
+// names starting with the rest of it, like "APP_*".
+func (d *Decoder) ExpandEnv(allow, deny []string) {
+	d.expandEnv = true
+	d.envAllow, d.envDeny = allow, deny
+}
+
+// expand returns the scalar str, read at offset start,
+// with its references to environment variables replaced.
+func (d *Decoder) expand(name, str string, start int) (string, error) {
+	if !d.expandEnv || !strings.Contains(str, "${") {
+		return str, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(str, "${")
+		if i == -1 {
+			break
+		}
+		if i > 0 && str[i-1] == '$' {
+			b.WriteString(str[:i])
+			b.WriteString("{")
+			str = str[i+2:]
+			continue
+		}
+		j := strings.IndexByte(str[i:], '}')
+		if j == -1 {
+			d.off = d.skipSpaces(start)
+			return "", d.error(name, "missing } in "+str[i:])
+		}
+		v, err := d.lookupEnv(name, str[i+2:i+j], start)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(str[:i])
+		b.WriteString(v)
+		str = str[i+j+1:]
+	}
+	b.WriteString(str)
+	return b.String(), nil
+}
+
+// lookupEnv returns the value of the reference ref, VAR or VAR:-default.
+func (d *Decoder) lookupEnv(name, ref string, start int) (string, error) {
+	key, def, hasDef := strings.Cut(ref, ":-")
+	if !envAllowed(key, d.envAllow, d.envDeny) {
+		d.off = d.skipSpaces(start)
+		return "", d.error(name, "environment variable "+key+" is not allowed")
+	}
+	v, ok := os.LookupEnv(key)
+	switch {
+	case hasDef && v == "":
+		return def, nil
+	case !ok:
+		d.off = d.skipSpaces(start)
+		return "", d.error(name, "environment variable "+key+" is not set")
+	}
+	return v, nil
+}
+
+func envAllowed(key string, allow, deny []string) bool {
+	if key == "" || matchEnv(key, deny) {
+		return false
+	}
+	return len(allow) == 0 || matchEnv(key, allow)
+}
+
+func matchEnv(key string, names []string) bool {
+	for _, n := range names {
+		if n == key || strings.HasSuffix(n, "*") && strings.HasPrefix(key, n[:len(n)-1]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package yaml
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldTag is the parsed yaml tag of a struct field, such as
+// `yaml:"name,omitempty,literal"`.
+type fieldTag struct {
+	name      string
+	omitEmpty bool
+	omitZero  bool
+	required  bool
+	secret    bool
+	style     string // literal, folded or quoted, if set
+
+	deprecated  bool
+	deprecation string // note of the deprecated option, like "use new_name"
+}
+
+// parseFieldTag parses the yaml tag of f. The name is the name of
+// the field when the tag has none, as in ",omitempty". It returns
+// false for the fields skipped with the tag "-". Unknown options
+// are ignored.
+func parseFieldTag(f reflect.StructField) (fieldTag, bool) {
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return fieldTag{}, false
+	}
+
+	opts := strings.Split(tag, ",")
+	ft := fieldTag{name: strings.TrimSpace(opts[0])}
+	if ft.name == "" {
+		ft.name = f.Name
+	}
+	for _, opt := range opts[1:] {
+		switch opt = strings.TrimSpace(opt); opt {
+		case "omitempty":
+			ft.omitEmpty = true
+		case "omitzero":
+			ft.omitZero = true
+		case "required":
+			ft.required = true
+		case "secret":
+			ft.secret = true
+		case "deprecated":
+			ft.deprecated = true
+		case "literal", "folded", "quoted":
+			ft.style = opt
+		default:
+			if note, ok := strings.CutPrefix(opt, "deprecated="); ok {
+				ft.deprecated, ft.deprecation = true, note
+			}
+		}
+	}
+	return ft, true
+}
+
+// A structType holds the fields of a struct type which are encoded
+// and decoded, in order.
+type structType struct {
+	index    []int      // indexes of the fields in the struct
+	tags     []fieldTag // tags of the fields
+	byName   map[string]int
+	required []int // of the fields in index
+}
+
+var structTypes sync.Map // reflect.Type -> *structType
+
+// structFields returns the fields of the struct type t,
+// which are computed once per type.
+func structFields(t reflect.Type) *structType {
+	if st, ok := structTypes.Load(t); ok {
+		return st.(*structType)
+	}
+
+	st := &structType{byName: make(map[string]int)}
+	for i, n := 0, t.NumField(); i < n; i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if tag, ok := parseFieldTag(f); ok {
+			// With duplicate names, the last field is decoded.
+			st.byName[tag.name] = len(st.index)
+			st.index = append(st.index, i)
+			st.tags = append(st.tags, tag)
+			if tag.required {
+				st.required = append(st.required, len(st.index)-1)
+			}
+		}
+	}
+	actual, _ := structTypes.LoadOrStore(t, st)
+	return actual.(*structType)
+}
@@ -0,0 +1,126 @@
+package yaml
+
+import (
+	"errors"
+	"strings"
+)
+
+// FormatOptions are the options of Format.
+type FormatOptions struct {
+	// Indent is the number of spaces by which the children of a
+	// mapping, or the entries of a nested sequence, are indented,
+	// between 1 and 9. It is 2 when zero.
+	Indent int
+
+	// LineWidth makes the strings having longer lines be written as
+	// folded block scalars, as Encoder.SetLineWidth. By default, lines
+	// are not wrapped.
+	LineWidth int
+}
+
+// Format returns the documents of data written again in block style,
+// with the indentation of opts, a single space after the colon of each
+// key, and each scalar in the plainest style keeping its value.
+//
+// The comments are kept: a comment following a node on its line stays
+// at the end of the line of the node, and a comment on its own line is
+// moved before the next entry, at its indentation. A comment before the
+// first line of the document stays before the document, rather than
+// before its first entry. The blank lines and the directives are not
+// kept.
+func Format(data []byte, opts FormatOptions) ([]byte, error) {
+	if opts.Indent < 0 || opts.Indent > 9 {
+		return nil, errors.New("yaml: indentation width out of range")
+	}
+
+	d := NewDecoderBytes(data)
+	comments := scanComments(d.data)
+	var docs []interface{}
+	for len(docs) == 0 || hasContent(d.Buffered()) {
+		n, rest, err := decodeCommented(d, comments)
+		if err != nil {
+			return nil, err
+		}
+		comments = rest
+		docs = append(docs, n)
+	}
+
+	e := NewEncoder()
+	if opts.Indent != 0 {
+		e.SetIndent(opts.Indent)
+	}
+	e.SetLineWidth(opts.LineWidth)
+	return e.EncodeAll(docs)
+}
+
+// decodeCommented decodes the next document of d into a node holding
+// the comments of its lines, from comments, the comments of d left,
+// and returns the comments of the following documents.
+func decodeCommented(d *Decoder, comments []Event) (*Node, []Event, error) {
+	n := &Node{}
+	if err := d.Decode(n); err != nil {
+		return nil, nil, err
+	}
+	if n.Kind == 0 {
+		// An empty document.
+		n.Kind, n.Tag, n.Line = ScalarNode, tagNull, d.line(int(d.InputOffset()))
+	}
+
+	// The comments up to the end of the document.
+	end := len(comments)
+	if hasContent(d.Buffered()) {
+		line := d.line(int(d.InputOffset()))
+		for end = 0; end < len(comments) && comments[end].Line < line; end++ {
+		}
+	}
+	attachComments(n, comments[:end])
+	return n, comments[end:], nil
+}
+
+// A formatNode is a node of a document being formatted.
+type formatNode struct {
+	n     *Node
+	entry bool // whether n is an entry of a sequence, or a key
+}
+
+// formatNodes appends n and its descendants to nodes,
+// in the order of the document.
+func formatNodes(nodes []formatNode, n *Node, entry bool) []formatNode {
+	nodes = append(nodes, formatNode{n, entry})
+	for i, c := range n.Content {
+		nodes = formatNodes(nodes, c, n.Kind == SequenceNode || i%2 == 0)
+	}
+	return nodes
+}
+
+// attachComments sets the comments of the nodes of the document root
+// from its comments, read by scanComments.
+func attachComments(root *Node, comments []Event) {
+	nodes := formatNodes(nil, root, false)
+COMMENTS:
+	for _, c := range comments {
+		text := strings.TrimPrefix(c.Value, " ")
+
+		// The last node starting on the line of the comment.
+		for i := len(nodes) - 1; i >= 0; i-- {
+			if n := nodes[i].n; n.Line == c.Line && n.Column < c.Column {
+				n.LineComment = joinComments(n.LineComment, text)
+				continue COMMENTS
+			}
+		}
+
+		// The document, or the next entry.
+		if c.Line < root.Line {
+			root.HeadComment = joinComments(root.HeadComment, text)
+			continue
+		}
+		for _, fn := range nodes {
+			if fn.entry && fn.n.Line > c.Line {
+				fn.n.HeadComment = joinComments(fn.n.HeadComment, text)
+				continue COMMENTS
+			}
+		}
+
+		root.FootComment = joinComments(root.FootComment, text)
+	}
+}
@@ -0,0 +1,51 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ExtractFrontMatter decodes into v the front matter of data, a YAML
+// document between two --- lines at the start of a Markdown or text
+// file, and returns the text following it:
+//
+//	---
+//	title: Hello
+//	tags:
+//	  - intro
+//	---
+//	# Hello
+//
+// The front matter may also end with a ... line. When data does not
+// start with a --- line, v is left unchanged and data is returned.
+// The body is a slice of data, unless data is in UTF-16.
+func ExtractFrontMatter(data []byte, v interface{}) (body []byte, err error) {
+	text := toUTF8(data)
+	first, rest, ok := cutLine(text)
+	if !ok || string(first) != "---" {
+		return data, nil
+	}
+	for off := len(text) - len(rest); ; {
+		line, next, ok := cutLine(text[off:])
+		if string(line) == "---" || string(line) == "..." {
+			if err := Unmarshal(text[:off], v); err != nil {
+				return nil, err
+			}
+			return next, nil
+		}
+		if !ok {
+			return nil, errors.New("yaml: front matter not closed by a --- line")
+		}
+		off = len(text) - len(next)
+	}
+}
+
+// cutLine returns the first line of data, without its line ending,
+// and the text following it. ok is false when data has no line ending.
+func cutLine(data []byte) (line, rest []byte, ok bool) {
+	i := bytes.IndexByte(data, '\n')
+	if i == -1 {
+		return bytes.TrimRight(data, "\r"), nil, false
+	}
+	return bytes.TrimRight(data[:i], "\r"), data[i+1:], true
+}
@@ -0,0 +1,16 @@
+package yaml
+
+// Decode returns the document of data decoded into a value of type T.
+func Decode[T any](data []byte) (T, error) {
+	var v T
+	err := Unmarshal(data, &v)
+	return v, err
+}
+
+// DecodeFile returns the document of the file filename
+// decoded into a value of type T.
+func DecodeFile[T any](filename string) (T, error) {
+	var v T
+	err := ReadFile(filename, &v)
+	return v, err
+}
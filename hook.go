@@ -0,0 +1,131 @@
+package yaml
+
+import "reflect"
+
+// A DecodeHook transforms data, a node decoded into an interface{}
+// value of type from (nil for a null node), before it is stored into
+// a value of type to. It returns data unchanged when it does not apply.
+type DecodeHook func(from, to reflect.Type, data interface{}) (interface{}, error)
+
+// RegisterHook adds h to the hooks of the decoder, which are called in
+// turn for each node decoded into a value which is not an interface
+// or a Node, each one with the result of the previous one. When a hook
+// changes the data, the result is stored into the value if it has its
+// type, and is otherwise encoded and decoded into the value. When none
+// does, the node is decoded as usual.
+//
+// A hook can split a string into a []string, or read an enum from its
+// name, without methods on the types. The nodes holding a tag other
+// than the core ones, like !env, !include or a custom tag, are decoded
+// by their tags without hooks. As the nodes are read twice, the
+// decoding is slower with hooks.
+func (d *Decoder) RegisterHook(h DecodeHook) {
+	d.hooks = append(d.hooks, h)
+}
+
+// hook calls the hooks for the node at the current position decoded
+// into val, and reports whether they decoded it.
+func (d *Decoder) hook(p *plan, name string, val reflect.Value, indent, state int) (bool, error) {
+	if d.hooking || p.class == planInterface || p.class == planRaw || p.class == planNode {
+		return false, nil
+	}
+
+	save, start := d.off, d.skipSpaces(d.off)
+	keys, aliases, errs := d.keys, d.aliases, len(d.errs)
+	depth, path := d.depth, len(d.path)
+	restore := func() {
+		// The node is read again, and counted once.
+		d.off, d.keys, d.aliases, d.errs = save, keys, aliases, d.errs[:errs]
+		d.depth, d.path = depth, d.path[:path]
+	}
+	data, ok := d.hookData(name, indent, state)
+	if !ok {
+		restore()
+		return false, nil
+	}
+
+	result := data
+	for _, h := range d.hooks {
+		var err error
+		result, err = h(reflect.TypeOf(result), val.Type(), result)
+		if err != nil {
+			return false, d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+	}
+
+	switch {
+	case sameData(result, data):
+		restore()
+		return false, nil
+	case result == nil:
+		val.Set(reflect.Zero(val.Type()))
+	case reflect.TypeOf(result).AssignableTo(val.Type()):
+		val.Set(reflect.ValueOf(result))
+	default:
+		text, err := marshalSecrets(result)
+		if err != nil {
+			return false, d.typeError(name, err.Error(), err, val.Type(), start)
+		}
+		sub := d.clone()
+		sub.path = append(sub.path, d.path...)
+		sub.Reset(text)
+		err = sub.value(name, val, 0, stateDefault)
+
+		// The errors are reported at the node, rather than
+		// in the text of the result.
+		line, column := d.position(start)
+		for _, e := range sub.errs {
+			d.errs = append(d.errs, relocate(e, line, column, d.offset(start)))
+		}
+		if err != nil {
+			return false, relocate(err, line, column, d.offset(start))
+		}
+	}
+	return true, nil
+}
+
+// hookData decodes the node at the current position into the data of
+// the hooks, and reports whether it could: the nodes with other tags
+// than the core ones are decoded by their tags.
+func (d *Decoder) hookData(name string, indent, state int) (interface{}, bool) {
+	var data interface{}
+	d.hooking = true
+	err := d.value(name, reflect.ValueOf(&data).Elem(), indent, state)
+	d.hooking = false
+	return data, err == nil
+}
+
+// relocate returns err, an error of the decoding of the result of the
+// hooks, at the position of the node.
+func relocate(err error, line, column, off int) error {
+	switch e := err.(type) {
+	case *SyntaxError:
+		e.Line, e.Column, e.Offset = line, column, off
+	case *TypeError:
+		e.Line, e.Column, e.Offset = line, column, off
+	case *RangeError:
+		e.Line, e.Column, e.Offset = line, column, off
+	case *UnknownFieldError:
+		e.Line, e.Column, e.Offset = line, column, off
+	}
+	return err
+}
+
+// sameData reports whether a and b are the same value, or the
+// same map or slice.
+func sameData(a, b interface{}) bool {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if !va.IsValid() || !vb.IsValid() {
+		return va.IsValid() == vb.IsValid()
+	}
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Ptr:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	return va.Type().Comparable() && a == b
+}
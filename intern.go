@@ -0,0 +1,29 @@
+package yaml
+
+// The interning table of a decoder holds at most maxInterned strings,
+// of at most maxInternLen bytes, which keys and small scalars often
+// repeat, such as "name" or "true".
+const (
+	maxInterned  = 1024
+	maxInternLen = 32
+)
+
+// intern returns b as a string, the same string for the same bytes
+// while the interning table is not full, so that the repeated strings
+// are allocated once.
+func (d *Decoder) intern(b []byte) string {
+	if d.zeroCopy || len(b) > maxInternLen {
+		return d.str(b)
+	}
+	if s, ok := d.interned[string(b)]; ok {
+		return s
+	}
+	s := string(b)
+	if len(d.interned) < maxInterned {
+		if d.interned == nil {
+			d.interned = make(map[string]string)
+		}
+		d.interned[s] = s
+	}
+	return s
+}
@@ -0,0 +1,245 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// ToJSON returns the document data as compact JSON. The keys
+// of the mappings keep their order and are written as strings, and the
+// scalars with a custom tag are written as strings. The aliases are
+// written as the values of their anchors. The documents with a
+// collection as a key, with a .inf or .nan float, or with an integer
+// out of the range of int64, are rejected rather than written with
+// another value, and so are the streams of several documents.
+func ToJSON(data []byte) ([]byte, error) {
+	var n Node
+	d := NewDecoderBytes(data)
+	if err := d.Decode(&n); err != nil {
+		return nil, err
+	}
+	if d.More() {
+		line, _ := d.position(d.off)
+		return nil, fmt.Errorf("yaml: ToJSON expects a single document, another follows at line %d", line)
+	}
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, &n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, n *Node) error {
+	switch n.Kind {
+	case 0:
+		buf.WriteString("null")
+
+	case ScalarNode:
+		switch n.Tag {
+		case tagNull, tagBool, tagInt, tagFloat:
+			if isInteger(n.Value) {
+				if _, err := strconv.ParseInt(n.Value, 10, 64); err != nil {
+					// Resolved as a float, it would be rounded.
+					return jsonError(n, "integer "+n.Value+" out of the range of int64")
+				}
+			}
+			v, err := resolve(CoreSchema, n.Tag, n.Value)
+			if err != nil {
+				return jsonError(n, err.Error())
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return jsonError(n, n.Value+" can not be written in JSON")
+			}
+			buf.Write(b)
+		default:
+			writeJSONString(buf, n.Value)
+		}
+
+	case SequenceNode:
+		buf.WriteByte('[')
+		for i, c := range n.Content {
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+
+	case MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != ScalarNode {
+				return jsonError(k, "collection as a key can not be written in JSON")
+			}
+			if i != 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONString(buf, k.Value)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, v); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	default:
+		return jsonError(n, "invalid node kind")
+	}
+	return nil
+}
+
+// isInteger reports whether s is a decimal integer, with a sign or not.
+func isInteger(s string) bool {
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// writeJSONString writes s as a JSON string, without escaping
+// the HTML characters.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // newline of Encode
+}
+
+func jsonError(n *Node, msg string) error {
+	return fmt.Errorf("yaml: %s at line %d, column %d", msg, n.Line, n.Column)
+}
+
+// SetJSONCompatible makes the decoder decode the values into
+// interface{} only as values encoding/json can encode and decode: the
+// mappings into map[string]interface{}, the sequences into
+// []interface{} and the numbers into json.Number, keeping their text
+// when it is a JSON number. The .inf and .nan floats are rejected.
+func (d *Decoder) SetJSONCompatible(on bool) {
+	d.jsonCompat = on
+}
+
+// jsonNumber returns v, resolved from the scalar str read at offset
+// start, with a number converted to a json.Number.
+func (d *Decoder) jsonNumber(name string, v interface{}, str string, t reflect.Type, start int) (interface{}, error) {
+	switch n := v.(type) {
+	case int:
+		if isJSONNumber(str) {
+			return json.Number(str), nil
+		}
+		return json.Number(strconv.Itoa(n)), nil
+	case float64:
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, d.typeError(name, str+" can not be written in JSON", nil, t, start)
+		}
+		if isJSONNumber(str) {
+			return json.Number(str), nil
+		}
+		return json.Number(strconv.FormatFloat(n, 'g', -1, 64)), nil
+	}
+	return v, nil
+}
+
+// FromJSON returns the JSON value data as a YAML document in block
+// style. The keys of the objects keep their order. The integers out of
+// the range of int64, and the numbers out of the range of float64, are
+// rejected rather than written with another value.
+func FromJSON(data []byte) ([]byte, error) {
+	n, err := parseJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(n)
+}
+
+// parseJSON returns the JSON value data as a node.
+func parseJSON(data []byte) (*Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := jsonNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("yaml: invalid JSON after the top-level value")
+	}
+	return n, nil
+}
+
+// parseNode returns the first document of data, in YAML or, as the
+// flow collections are not decoded, in JSON when it starts with
+// [ or {, as a node.
+func parseNode(data []byte) (*Node, error) {
+	if t := bytes.TrimSpace(data); len(t) != 0 && (t[0] == '[' || t[0] == '{') {
+		return parseJSON(t)
+	}
+	n := &Node{}
+	if err := Unmarshal(data, n); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// jsonNode reads the next JSON value of dec as a node.
+func jsonNode(dec *json.Decoder) (*Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		n := &Node{Kind: SequenceNode, Tag: tagSeq}
+		if tok == '{' {
+			n.Kind, n.Tag = MappingNode, tagMap
+		}
+		for dec.More() {
+			if n.Kind == MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.Content = append(n.Content, &Node{Kind: ScalarNode, Tag: tagStr, Value: key.(string)})
+			}
+			c, err := jsonNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, c)
+		}
+		_, err := dec.Token() // closing delimiter
+		return n, err
+	case string:
+		return &Node{Kind: ScalarNode, Tag: tagStr, Value: tok}, nil
+	case json.Number:
+		// A number out of the range of int64 or float64 would be read
+		// back as another value, or as a string.
+		s, tag := tok.String(), tagFloat
+		off := dec.InputOffset() - int64(len(s))
+		if isInteger(s) {
+			if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, fmt.Errorf("yaml: integer %s out of the range of int64 at offset %d", s, off)
+			}
+			tag = tagInt
+		} else if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return nil, fmt.Errorf("yaml: number %s out of the range of float64 at offset %d", s, off)
+		}
+		return &Node{Kind: ScalarNode, Tag: tag, Value: s}, nil
+	case bool:
+		return &Node{Kind: ScalarNode, Tag: tagBool, Value: strconv.FormatBool(tok)}, nil
+	}
+	return &Node{Kind: ScalarNode, Tag: tagNull, Value: "null"}, nil
+}
@@ -0,0 +1,167 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/J5ive/yaml"
+)
+
+// maxEnum is the number of distinct strings at most of a value
+// described by InferSchema as an enumeration.
+const maxEnum = 5
+
+// typeOrder is the order of the types in the schemas of InferSchema.
+var typeOrder = []string{"null", "boolean", "integer", "number", "string", "array", "object"}
+
+// InferSchema returns a schema describing the documents of docs, each of
+// which may hold several documents: the types of the values, the
+// properties of the objects, required when every object has them, and
+// the items of the arrays. A string value taking at most 5 distinct
+// values, one of which at least twice, is described by their enum.
+//
+// The schema validates the documents of docs, and may be written with
+// MarshalJSON to be documented and made stricter.
+func InferSchema(docs ...[]byte) (Schema, error) {
+	root := &shape{}
+	for _, data := range docs {
+		d := yaml.NewDecoderBytes(data)
+		for {
+			var n yaml.Node
+			if err := d.Decode(&n); err != nil {
+				return Schema{}, err
+			}
+			if n.Kind != 0 {
+				root.add(&n)
+			}
+			if !d.More() {
+				break
+			}
+		}
+	}
+
+	m := root.schema()
+	m["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	return Schema{root: m, patterns: make(map[string]*regexp.Regexp)}, nil
+}
+
+// MarshalJSON returns the JSON text of the schema.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.root)
+}
+
+// A shape is the merged shape of the nodes at a place of the documents.
+type shape struct {
+	types map[string]bool
+
+	strings []string       // distinct, in order
+	counts  map[string]int // of each string
+
+	objects int // number of mappings
+	keys    []string
+	fields  map[string]*shape
+	present map[string]int // number of mappings having each key
+
+	items *shape // nil without entries
+}
+
+func (s *shape) add(n *yaml.Node) {
+	if s.types == nil {
+		s.types = make(map[string]bool)
+	}
+	t := nodeType(n)
+	s.types[t] = true
+
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if t != "string" {
+			break
+		}
+		if s.counts == nil {
+			s.counts = make(map[string]int)
+		}
+		if s.counts[n.Value] == 0 {
+			s.strings = append(s.strings, n.Value)
+		}
+		s.counts[n.Value]++
+
+	case yaml.SequenceNode:
+		for _, e := range n.Content {
+			if s.items == nil {
+				s.items = &shape{}
+			}
+			s.items.add(e)
+		}
+
+	case yaml.MappingNode:
+		s.objects++
+		if s.fields == nil {
+			s.fields, s.present = make(map[string]*shape), make(map[string]int)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i].Value
+			f := s.fields[k]
+			if f == nil {
+				f = &shape{}
+				s.fields[k] = f
+				s.keys = append(s.keys, k)
+			}
+			if s.present[k] < s.objects { // once per mapping
+				s.present[k]++
+			}
+			f.add(n.Content[i+1])
+		}
+	}
+}
+
+// schema returns the schema of the shape.
+func (s *shape) schema() map[string]interface{} {
+	m := make(map[string]interface{})
+	var types []interface{}
+	for _, t := range typeOrder {
+		if s.types[t] && !(t == "integer" && s.types["number"]) {
+			types = append(types, t)
+		}
+	}
+	switch len(types) {
+	case 0:
+		return m
+	case 1:
+		m["type"] = types[0]
+	default:
+		m["type"] = types
+	}
+
+	if len(types) == 1 && types[0] == "string" && len(s.strings) <= maxEnum {
+		repeated := false
+		for _, c := range s.counts {
+			repeated = repeated || c > 1
+		}
+		if repeated {
+			enum := make([]interface{}, len(s.strings))
+			for i, v := range s.strings {
+				enum[i] = v
+			}
+			m["enum"] = enum
+		}
+	}
+
+	if s.objects != 0 {
+		properties := make(map[string]interface{})
+		var required []interface{}
+		for _, k := range s.keys {
+			properties[k] = s.fields[k].schema()
+			if s.present[k] == s.objects {
+				required = append(required, k)
+			}
+		}
+		m["properties"] = properties
+		if len(required) != 0 {
+			m["required"] = required
+		}
+	}
+	if s.items != nil {
+		m["items"] = s.items.schema()
+	}
+	return m
+}
@@ -0,0 +1,605 @@
+/*
+Package jsonschema validates documents decoded into a yaml.Node against
+a JSON Schema, and reports each violation at the line and column of
+the node in the document:
+
+	s, err := jsonschema.Compile(schemaText)
+	...
+	violations, err := s.ValidateDocument(configText)
+	for _, v := range violations {
+		fmt.Println(v)  // 12:11: /spec/replicas: 0 is less than the minimum 1
+	}
+
+The keywords supported are those of the validation of instances in
+draft 2020-12, less the dynamic and the remote references:
+
+	type enum const
+	multipleOf minimum maximum exclusiveMinimum exclusiveMaximum
+	minLength maxLength pattern
+	items prefixItems contains minContains maxContains minItems maxItems uniqueItems
+	properties patternProperties additionalProperties propertyNames
+	required dependentRequired minProperties maxProperties
+	allOf anyOf oneOf not if then else $ref $defs definitions
+
+The format keyword and the other annotations are ignored. The scalars
+have the type of their tag: a !!str scalar is a string, even when its
+text is a number.
+
+InferSchema returns a schema describing a set of example documents.
+*/
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/J5ive/yaml"
+)
+
+// A Schema is a compiled JSON Schema.
+type Schema struct {
+	root     interface{}
+	patterns map[string]*regexp.Regexp
+}
+
+// A Violation is a node of a document which does not match its schema.
+type Violation struct {
+	// Pointer is the JSON pointer of the node, like "/spec/replicas".
+	Pointer string
+
+	// Line and Column are the 1-based position of the node.
+	Line, Column int
+
+	Msg string
+}
+
+func (v *Violation) String() string {
+	pointer := v.Pointer
+	if pointer == "" {
+		pointer = "/"
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", v.Line, v.Column, pointer, v.Msg)
+}
+
+// Compile parses the JSON Schema data, written in JSON or in YAML.
+func Compile(data []byte) (*Schema, error) {
+	var root interface{}
+	if t := bytes.TrimSpace(data); len(t) != 0 && t[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(t))
+		dec.UseNumber()
+		if err := dec.Decode(&root); err != nil {
+			return nil, err
+		}
+	} else if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	if err := s.check(root); err != nil {
+		return nil, fmt.Errorf("jsonschema: %w", err)
+	}
+	return s, nil
+}
+
+// check checks the references and compiles the patterns of the
+// schema v, and of the schemas it holds.
+func (s *Schema) check(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			if _, err := s.resolve(ref); err != nil {
+				return err
+			}
+		}
+		patterns := []string{}
+		if p, ok := v["pattern"].(string); ok {
+			patterns = append(patterns, p)
+		}
+		if pp, ok := v["patternProperties"].(map[string]interface{}); ok {
+			for p := range pp {
+				patterns = append(patterns, p)
+			}
+		}
+		for _, p := range patterns {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return err
+			}
+			s.patterns[p] = re
+		}
+		for _, e := range v {
+			if err := s.check(e); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := s.check(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the schema of the local reference ref, like
+// "#/$defs/port".
+func (s *Schema) resolve(ref string) (interface{}, error) {
+	if ref != "#" && !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	v := s.root
+	for _, t := range strings.Split(ref, "/")[1:] {
+		t = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+		switch c := v.(type) {
+		case map[string]interface{}:
+			v = c[t]
+		case []interface{}:
+			i, err := strconv.Atoi(t)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("invalid reference %q", ref)
+			}
+			v = c[i]
+		default:
+			v = nil
+		}
+		if v == nil {
+			return nil, fmt.Errorf("invalid reference %q", ref)
+		}
+	}
+	return v, nil
+}
+
+// ValidateDocument returns the violations of the first document of
+// data, or its syntax error.
+func (s *Schema) ValidateDocument(data []byte) ([]Violation, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+	return s.Validate(&n), nil
+}
+
+// Validate returns the violations of the document n.
+func (s *Schema) Validate(n *yaml.Node) []Violation {
+	if n.Kind == 0 {
+		n = &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null", Line: 1, Column: 1}
+	}
+	v := &validator{s: s}
+	v.validate(s.root, n, "")
+	return v.violations
+}
+
+// A validator collects the violations of a document.
+type validator struct {
+	s          *Schema
+	violations []Violation
+	depth      int // of the references followed
+}
+
+// maxDepth is the number of references followed at most,
+// to stop on recursive schemas which do not consume the nodes.
+const maxDepth = 1000
+
+var errDepth = errors.New("too many nested references")
+
+func (v *validator) report(n *yaml.Node, pointer, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{pointer, n.Line, n.Column, fmt.Sprintf(format, args...)})
+}
+
+// valid reports whether n matches the schema, without reporting.
+func (v *validator) valid(schema interface{}, n *yaml.Node, pointer string) bool {
+	sub := &validator{s: v.s, depth: v.depth}
+	sub.validate(schema, n, pointer)
+	return len(sub.violations) == 0
+}
+
+func (v *validator) validate(schema interface{}, n *yaml.Node, pointer string) {
+	var m map[string]interface{}
+	switch schema := schema.(type) {
+	case bool:
+		if !schema {
+			v.report(n, pointer, "no value is allowed")
+		}
+		return
+	case map[string]interface{}:
+		m = schema
+	default:
+		return
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		if v.depth++; v.depth > maxDepth {
+			v.report(n, pointer, "%v", errDepth)
+			return
+		}
+		target, _ := v.s.resolve(ref) // checked by Compile
+		v.validate(target, n, pointer)
+		v.depth--
+	}
+
+	if !v.validType(m, n, pointer) {
+		return
+	}
+	value := nodeValue(n)
+	if enum, ok := m["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || equal(e, value)
+		}
+		if !found {
+			v.report(n, pointer, "%s is not one of %s", text(value), text(enum))
+		}
+	}
+	if c, ok := m["const"]; ok && !equal(c, value) {
+		v.report(n, pointer, "%s is not %s", text(value), text(c))
+	}
+
+	switch n.Kind {
+	case yaml.ScalarNode:
+		switch value := value.(type) {
+		case float64:
+			v.number(m, n, pointer, value)
+		case string:
+			v.string(m, n, pointer, value)
+		}
+	case yaml.SequenceNode:
+		v.array(m, n, pointer)
+	case yaml.MappingNode:
+		v.object(m, n, pointer)
+	}
+
+	v.combinations(m, n, pointer)
+}
+
+// validType checks the type keyword, and reports whether n has
+// one of the types.
+func (v *validator) validType(m map[string]interface{}, n *yaml.Node, pointer string) bool {
+	var types []string
+	switch t := m["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				types = append(types, s)
+			}
+		}
+	default:
+		return true
+	}
+	actual := nodeType(n)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+		if t == "integer" && actual == "number" {
+			if f, ok := nodeValue(n).(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	v.report(n, pointer, "%s is not of type %s", actual, strings.Join(types, " or "))
+	return false
+}
+
+func (v *validator) number(m map[string]interface{}, n *yaml.Node, pointer string, f float64) {
+	if x, ok := number(m["minimum"]); ok && f < x {
+		v.report(n, pointer, "%s is less than the minimum %s", n.Value, format(x))
+	}
+	if x, ok := number(m["maximum"]); ok && f > x {
+		v.report(n, pointer, "%s is greater than the maximum %s", n.Value, format(x))
+	}
+	if x, ok := number(m["exclusiveMinimum"]); ok && f <= x {
+		v.report(n, pointer, "%s is not greater than %s", n.Value, format(x))
+	}
+	if x, ok := number(m["exclusiveMaximum"]); ok && f >= x {
+		v.report(n, pointer, "%s is not less than %s", n.Value, format(x))
+	}
+	if x, ok := number(m["multipleOf"]); ok && x > 0 {
+		if q := f / x; q != math.Trunc(q) {
+			v.report(n, pointer, "%s is not a multiple of %s", n.Value, format(x))
+		}
+	}
+}
+
+func (v *validator) string(m map[string]interface{}, n *yaml.Node, pointer string, s string) {
+	length := utf8.RuneCountInString(s)
+	if x, ok := number(m["minLength"]); ok && float64(length) < x {
+		v.report(n, pointer, "length %d is less than %s", length, format(x))
+	}
+	if x, ok := number(m["maxLength"]); ok && float64(length) > x {
+		v.report(n, pointer, "length %d is greater than %s", length, format(x))
+	}
+	if p, ok := m["pattern"].(string); ok && !v.s.patterns[p].MatchString(s) {
+		v.report(n, pointer, "%q does not match the pattern %q", s, p)
+	}
+}
+
+func (v *validator) array(m map[string]interface{}, n *yaml.Node, pointer string) {
+	entries := n.Content
+	if x, ok := number(m["minItems"]); ok && float64(len(entries)) < x {
+		v.report(n, pointer, "%d items are fewer than %s", len(entries), format(x))
+	}
+	if x, ok := number(m["maxItems"]); ok && float64(len(entries)) > x {
+		v.report(n, pointer, "%d items are more than %s", len(entries), format(x))
+	}
+
+	prefix, _ := m["prefixItems"].([]interface{})
+	items, hasItems := m["items"]
+	if list, ok := items.([]interface{}); ok {
+		// The items of the drafts before 2020-12.
+		prefix = list
+		items, hasItems = m["additionalItems"]
+	}
+	for i, e := range entries {
+		p := pointer + "/" + strconv.Itoa(i)
+		switch {
+		case i < len(prefix):
+			v.validate(prefix[i], e, p)
+		case hasItems:
+			v.validate(items, e, p)
+		}
+	}
+
+	if contains, ok := m["contains"]; ok {
+		count := 0
+		for i, e := range entries {
+			if v.valid(contains, e, pointer+"/"+strconv.Itoa(i)) {
+				count++
+			}
+		}
+		least, ok := number(m["minContains"])
+		if !ok {
+			least = 1
+		}
+		if float64(count) < least {
+			v.report(n, pointer, "%d items match contains, fewer than %s", count, format(least))
+		}
+		if most, ok := number(m["maxContains"]); ok && float64(count) > most {
+			v.report(n, pointer, "%d items match contains, more than %s", count, format(most))
+		}
+	}
+
+	if unique, _ := m["uniqueItems"].(bool); unique {
+		values := make([]interface{}, len(entries))
+		for i, e := range entries {
+			values[i] = nodeValue(e)
+			for j := 0; j < i; j++ {
+				if equal(values[j], values[i]) {
+					v.report(e, pointer+"/"+strconv.Itoa(i), "item is a duplicate of item %d", j)
+					break
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) object(m map[string]interface{}, n *yaml.Node, pointer string) {
+	count := len(n.Content) / 2
+	if x, ok := number(m["minProperties"]); ok && float64(count) < x {
+		v.report(n, pointer, "%d properties are fewer than %s", count, format(x))
+	}
+	if x, ok := number(m["maxProperties"]); ok && float64(count) > x {
+		v.report(n, pointer, "%d properties are more than %s", count, format(x))
+	}
+
+	keys := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keys[n.Content[i].Value] = true
+	}
+	if required, ok := m["required"].([]interface{}); ok {
+		for _, r := range required {
+			if k, ok := r.(string); ok && !keys[k] {
+				v.report(n, pointer, "missing property %q", k)
+			}
+		}
+	}
+	if deps, ok := m["dependentRequired"].(map[string]interface{}); ok {
+		for k, d := range deps {
+			list, _ := d.([]interface{})
+			for _, r := range list {
+				if r, ok := r.(string); ok && keys[k] && !keys[r] {
+					v.report(n, pointer, "missing property %q, required by %q", r, k)
+				}
+			}
+		}
+	}
+
+	properties, _ := m["properties"].(map[string]interface{})
+	patterns, _ := m["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := m["additionalProperties"]
+	names, hasNames := m["propertyNames"]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, val := n.Content[i], n.Content[i+1]
+		p := pointer + "/" + escape(k.Value)
+		if hasNames {
+			name := *k
+			name.Kind, name.Tag = yaml.ScalarNode, "!!str"
+			v.validate(names, &name, p)
+		}
+
+		matched := false
+		if s, ok := properties[k.Value]; ok {
+			v.validate(s, val, p)
+			matched = true
+		}
+		for pattern, s := range patterns {
+			if v.s.patterns[pattern].MatchString(k.Value) {
+				v.validate(s, val, p)
+				matched = true
+			}
+		}
+		if !matched && hasAdditional {
+			if allowed, ok := additional.(bool); ok && !allowed {
+				v.report(k, p, "property %q is not allowed", k.Value)
+			} else {
+				v.validate(additional, val, p)
+			}
+		}
+	}
+}
+
+// combinations checks the keywords combining schemas.
+func (v *validator) combinations(m map[string]interface{}, n *yaml.Node, pointer string) {
+	if allOf, ok := m["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			v.validate(s, n, pointer)
+		}
+	}
+	if anyOf, ok := m["anyOf"].([]interface{}); ok {
+		matched := false
+		for _, s := range anyOf {
+			if v.valid(s, n, pointer) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.report(n, pointer, "value matches none of the schemas of anyOf")
+		}
+	}
+	if oneOf, ok := m["oneOf"].([]interface{}); ok {
+		count := 0
+		for _, s := range oneOf {
+			if v.valid(s, n, pointer) {
+				count++
+			}
+		}
+		if count != 1 {
+			v.report(n, pointer, "value matches %d schemas of oneOf rather than 1", count)
+		}
+	}
+	if not, ok := m["not"]; ok && v.valid(not, n, pointer) {
+		v.report(n, pointer, "value matches the schema of not")
+	}
+	if cond, ok := m["if"]; ok {
+		if v.valid(cond, n, pointer) {
+			if then, ok := m["then"]; ok {
+				v.validate(then, n, pointer)
+			}
+		} else if els, ok := m["else"]; ok {
+			v.validate(els, n, pointer)
+		}
+	}
+}
+
+// nodeType returns the JSON type of n.
+func nodeType(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.MappingNode:
+		return "object"
+	}
+	switch n.Tag {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		return "boolean"
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	}
+	return "string"
+}
+
+// nodeValue returns the value of n, with the numbers as float64.
+func nodeValue(n *yaml.Node) interface{} {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		s := make([]interface{}, len(n.Content))
+		for i, e := range n.Content {
+			s[i] = nodeValue(e)
+		}
+		return s
+	case yaml.MappingNode:
+		m := make(map[string]interface{})
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			m[n.Content[i].Value] = nodeValue(n.Content[i+1])
+		}
+		return m
+	}
+	switch nodeType(n) {
+	case "null":
+		return nil
+	case "boolean":
+		var b bool
+		yaml.Unmarshal([]byte(n.Value), &b)
+		return b
+	case "integer", "number":
+		var f float64
+		yaml.Unmarshal([]byte(n.Value), &f)
+		return f
+	}
+	return n.Value
+}
+
+// number returns the number v of a schema.
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// equal reports whether the value x of a schema
+// equals the value y of a node.
+func equal(x, y interface{}) bool {
+	return reflect.DeepEqual(normalize(x), y)
+}
+
+// normalize returns the value v of a schema with the numbers as float64.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = normalize(e)
+		}
+		return s
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = normalize(e)
+		}
+		return m
+	}
+	if f, ok := number(v); ok {
+		return f
+	}
+	return v
+}
+
+// text returns the JSON text of the value v.
+func text(v interface{}) string {
+	b, err := json.Marshal(normalize(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func format(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// escape escapes the key of a JSON pointer.
+func escape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
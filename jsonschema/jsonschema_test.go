@@ -0,0 +1,164 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, x, y interface{}) {
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("Assert fail! \nExpect: %v\nObtain: %v\n", x, y)
+	}
+}
+
+func violations(t *testing.T, s *Schema, doc string) []string {
+	vs, err := s.ValidateDocument([]byte(doc))
+	assertEqual(t, err, nil)
+	var text []string
+	for _, v := range vs {
+		text = append(text, v.String())
+	}
+	return text
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(`{
+		"type": "object",
+		"required": ["name", "replicas"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string", "pattern": "^[a-z]+$"},
+			"replicas": {"type": "integer", "minimum": 1},
+			"ratio": {"type": "number", "exclusiveMaximum": 1},
+			"mode": {"enum": ["fast", "safe"]},
+			"ports": {"type": "array", "items": {"$ref": "#/$defs/port"}, "uniqueItems": true},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}}
+		},
+		"$defs": {
+			"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+		}
+	}`))
+	assertEqual(t, err, nil)
+
+	assertEqual(t, violations(t, s, `
+name: app
+replicas: 2
+ratio: 0.5
+mode: fast
+ports:
+  - 80
+  - 443
+labels:
+  team: web
+`), []string(nil))
+
+	assertEqual(t, violations(t, s, `
+name: App
+replicas: 0
+ratio: 1
+mode: slow
+ports:
+  - 80
+  - 70000
+  - 80
+labels:
+  team: 1
+extra: x
+`), []string{
+		`2:7: /name: "App" does not match the pattern "^[a-z]+$"`,
+		`3:11: /replicas: 0 is less than the minimum 1`,
+		`4:8: /ratio: 1 is not less than 1`,
+		`5:7: /mode: "slow" is not one of ["fast","safe"]`,
+		`8:5: /ports/1: 70000 is greater than the maximum 65535`,
+		`9:5: /ports/2: item is a duplicate of item 0`,
+		`11:9: /labels/team: integer is not of type string`,
+		`12:1: /extra: property "extra" is not allowed`,
+	})
+
+	assertEqual(t, violations(t, s, "name: a\n"), []string{
+		`1:1: /: missing property "replicas"`,
+	})
+}
+
+func TestCombinations(t *testing.T) {
+	s, err := Compile([]byte(`
+oneOf:
+  - type: string
+  - type: integer
+    multipleOf: 5
+`))
+	assertEqual(t, err, nil)
+	assertEqual(t, violations(t, s, "abc\n"), []string(nil))
+	assertEqual(t, violations(t, s, "10\n"), []string(nil))
+	assertEqual(t, violations(t, s, "7\n"), []string{"1:1: /: value matches 0 schemas of oneOf rather than 1"})
+
+	s, err = Compile([]byte(`
+type: object
+if:
+  properties:
+    tls:
+      const: true
+then:
+  required:
+    - cert
+`))
+	assertEqual(t, err, nil)
+	assertEqual(t, violations(t, s, "tls: false\n"), []string(nil))
+	assertEqual(t, violations(t, s, "tls: true\n"), []string{`1:1: /: missing property "cert"`})
+	assertEqual(t, violations(t, s, "- 1\n"), []string{"1:1: /: array is not of type object"})
+
+	_, err = Compile([]byte(`{"$ref": "#/$defs/missing"}`))
+	assertEqual(t, err.Error(), `jsonschema: invalid reference "#/$defs/missing"`)
+	_, err = Compile([]byte(`{"pattern": "("}`))
+	assertEqual(t, err != nil, true)
+}
+
+func TestInferSchema(t *testing.T) {
+	a := []byte(`
+name: app
+port: 8080
+mode: fast
+tags:
+  - web
+servers:
+  - host: a
+    weight: 1
+  - host: b
+    weight: 0.5
+    backup: true
+`)
+	b := []byte(`
+name: api
+port: 9090
+mode: fast
+debug: ~
+---
+name: db
+port: 5432
+mode: safe
+debug: false
+`)
+	s, err := InferSchema(a, b)
+	assertEqual(t, err, nil)
+	text, err := json.Marshal(s)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(text), `{"$schema":"https://json-schema.org/draft/2020-12/schema",`+
+		`"properties":{`+
+		`"debug":{"type":["null","boolean"]},`+
+		`"mode":{"enum":["fast","safe"],"type":"string"},`+
+		`"name":{"type":"string"},`+
+		`"port":{"type":"integer"},`+
+		`"servers":{"items":{"properties":{"backup":{"type":"boolean"},"host":{"type":"string"},"weight":{"type":"number"}},"required":["host","weight"],"type":"object"},"type":"array"},`+
+		`"tags":{"items":{"type":"string"},"type":"array"}},`+
+		`"required":["name","port","mode"],"type":"object"}`)
+
+	for _, doc := range [][]byte{a, b} {
+		vs, err := s.ValidateDocument(doc)
+		assertEqual(t, err, nil)
+		assertEqual(t, len(vs), 0)
+	}
+	assertEqual(t, violations(t, &s, "name: x\nport: 1\nmode: slow\n"), []string{
+		`3:7: /mode: "slow" is not one of ["fast","safe"]`,
+	})
+}
@@ -0,0 +1,136 @@
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var (
+	kindMu sync.RWMutex
+	kinds  = make(map[string]map[string]reflect.Type) // key -> value -> type
+)
+
+// RegisterKind registers the type of v as the concrete type of
+// mappings whose discriminator key has the given value, when they
+// are decoded into an interface type implemented by it:
+//
+//	yaml.RegisterKind("kind", "http", HTTPBackend{})
+//
+//	backends:
+//	  - kind: http
+//	    url: http://localhost
+//
+// The discriminator key is decoded like any other key,
+// so the type should have a field for it.
+// If RegisterKind is called twice with the same key and value, it panics.
+func RegisterKind(key, value string, v interface{}) {
+	kindMu.Lock()
+	defer kindMu.Unlock()
+	m := kinds[key]
+	if m == nil {
+		m = make(map[string]reflect.Type)
+		kinds[key] = m
+	}
+	if _, dup := m[value]; dup {
+		panic("yaml: RegisterKind called twice for " + key + ": " + value)
+	}
+	m[value] = reflect.TypeOf(v)
+}
+
+// kind decodes a mapping into the interface val, choosing the concrete
+// type with the registered discriminator keys.
+func (d *Decoder) kind(name string, val reflect.Value, indent, state int) error {
+	kindMu.RLock()
+	keys := make([]string, 0, len(kinds))
+	for k := range kinds {
+		keys = append(keys, k)
+	}
+	kindMu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, ok, err := d.peekKey(name, key, indent, state)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		kindMu.RLock()
+		t := kinds[key][value]
+		kindMu.RUnlock()
+		if t == nil {
+			continue
+		}
+
+		var v, elem reflect.Value
+		switch {
+		case t.Implements(val.Type()) && t.Kind() != reflect.Ptr:
+			v = reflect.New(t).Elem()
+			elem = v
+		case reflect.PtrTo(t).Implements(val.Type()):
+			v = reflect.New(t)
+			elem = v.Elem()
+		case t.Kind() == reflect.Ptr && t.Implements(val.Type()):
+			v = reflect.New(t.Elem())
+			elem = v.Elem()
+		default:
+			continue
+		}
+		if err := d.value(name, elem, indent, state); err != nil {
+			return err
+		}
+		val.Set(v)
+		return nil
+	}
+	return d.typeError(name, fmt.Sprintf("no registered kind for %s", val.Type()), ErrUnsupportedType, val.Type(), d.off)
+}
+
+// peekKey looks ahead for key in the mapping at the current position,
+// and returns its scalar value. The position is left unchanged.
+func (d *Decoder) peekKey(name, key string, indent, state int) (string, bool, error) {
+	// The keys are read again when decoding, and counted once.
+	save, keys, aliases := d.off, d.keys, d.aliases
+	defer func() { d.off, d.keys, d.aliases = save, keys, aliases }()
+
+	if state == stateObjectValue {
+		d.nextLine()
+		var err error
+		if indent, err = d.blockIndent(indent - 2); err != nil {
+			return "", false, err
+		}
+		state = stateDefault
+	}
+	for {
+		k, err := d.key(name, indent, state)
+		if err != nil || k == "" {
+			return "", false, err
+		}
+		if k == key {
+			s, err := d.string(indent + 2)
+			return s, err == nil, err
+		}
+		d.skipValue(indent)
+		state = stateDefault
+	}
+}
+
+// skipValue skips the value of a key at column indent.
+func (d *Decoder) skipValue(indent int) {
+	d.nextLine()
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			return
+		}
+		if n := len(line) - len(bytes.TrimLeft(line, " ")); n < len(line) {
+			if n < indent || n == indent && line[n] != '-' {
+				return
+			}
+		}
+		d.off = pos
+	}
+}
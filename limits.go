@@ -0,0 +1,84 @@
+package yaml
+
+import "strconv"
+
+// Limits bound the resources used to decode a document, so that
+// documents from untrusted sources can not exhaust them. A zero field
+// is the default limit, and a negative one disables the limit.
+type Limits struct {
+	MaxDepth           int // nesting of the nodes, 10000 by default
+	MaxDocumentSize    int // in bytes, 64 MiB by default
+	MaxKeys            int // keys of all the mappings, 1 << 20 by default
+	MaxAliasExpansions int // aliases decoded, 10000 by default
+}
+
+var defaultLimits = Limits{
+	MaxDepth:           10000,
+	MaxDocumentSize:    64 << 20,
+	MaxKeys:            1 << 20,
+	MaxAliasExpansions: 10000,
+}
+
+// SetLimits sets the limits of the documents decoded.
+func (d *Decoder) SetLimits(l Limits) {
+	d.limits = l
+}
+
+// limit returns the limit n, or the default limit def when n is zero.
+// Disabled limits are -1.
+func limit(n, def int) int {
+	switch {
+	case n == 0:
+		return def
+	case n < 0:
+		return -1
+	}
+	return n
+}
+
+func (d *Decoder) maxDepth() int {
+	return limit(d.limits.MaxDepth, defaultLimits.MaxDepth)
+}
+
+func (d *Decoder) maxDocumentSize() int {
+	return limit(d.limits.MaxDocumentSize, defaultLimits.MaxDocumentSize)
+}
+
+// checkSize fails when the document is larger than the limit.
+func (d *Decoder) checkSize(size int) error {
+	if max := d.maxDocumentSize(); max >= 0 && size > max {
+		return d.error("", "document larger than "+strconv.Itoa(max)+" bytes")
+	}
+	return nil
+}
+
+// enter enters a nested node, which leave leaves.
+func (d *Decoder) enter(name string) error {
+	d.depth++
+	if max := d.maxDepth(); max >= 0 && d.depth > max {
+		return d.error(name, "nesting deeper than "+strconv.Itoa(max))
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+// countKey counts a key read.
+func (d *Decoder) countKey(name string) error {
+	d.keys++
+	if max := limit(d.limits.MaxKeys, defaultLimits.MaxKeys); max >= 0 && d.keys > max {
+		return d.error(name, "more than "+strconv.Itoa(max)+" keys")
+	}
+	return nil
+}
+
+// countAlias counts an alias decoded.
+func (d *Decoder) countAlias(name string) error {
+	d.aliases++
+	if max := limit(d.limits.MaxAliasExpansions, defaultLimits.MaxAliasExpansions); max >= 0 && d.aliases > max {
+		return d.error(name, "more than "+strconv.Itoa(max)+" aliases")
+	}
+	return nil
+}
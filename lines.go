@@ -0,0 +1,62 @@
+package yaml
+
+import "sort"
+
+// A lineTable records where the lines of the data start and where
+// their comments start, so that the lines are scanned once rather
+// than each time a node at some depth looks at them.
+type lineTable struct {
+	starts   []int // offsets of the lines, none if the table is not built
+	comments []int // offsets of the first comment of the lines, or -1
+	last     int   // index of the line found last
+}
+
+// scanLines fills the line table of the data.
+func (d *Decoder) scanLines() {
+	t := &d.lines
+	t.last = 0
+	t.starts = append(t.starts[:0], 0)
+	t.comments = append(t.comments[:0], -1)
+	for i, c := range d.data {
+		switch {
+		case c == '\n':
+			t.starts = append(t.starts, i+1)
+			t.comments = append(t.comments, -1)
+		case c == '#' && t.comments[len(t.comments)-1] == -1 && (i == 0 || isBlank(d.data, i-1)):
+			// A comment is separated from other tokens by white space.
+			t.comments[len(t.comments)-1] = i
+		}
+	}
+}
+
+// lineAt returns the index of the line holding offset off.
+// The line is most often the one found last, or the next one.
+func (t *lineTable) lineAt(off int) int {
+	for n := t.last; n <= t.last+1 && n < len(t.starts); n++ {
+		if t.starts[n] <= off && (n+1 == len(t.starts) || off < t.starts[n+1]) {
+			t.last = n
+			return n
+		}
+	}
+	t.last = sort.SearchInts(t.starts, off+1) - 1
+	return t.last
+}
+
+// tableLine is peekLine with the line table.
+func (d *Decoder) tableLine() ([]byte, int, bool) {
+	n := d.lines.lineAt(d.off)
+	next, end := len(d.data), len(d.data)
+	if n+1 < len(d.lines.starts) && d.lines.starts[n+1] <= len(d.data) {
+		next = d.lines.starts[n+1]
+		end = next - 1
+	}
+	if c := d.lines.comments[n]; c != -1 && c < end {
+		if c < d.off {
+			// Past the comment, which may be text
+			// of a quoted scalar.
+			return nil, 0, false
+		}
+		end = c
+	}
+	return d.data[d.off:end], next, true
+}
@@ -0,0 +1,210 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// lintLineLength is the length, in characters, above which
+// Lint reports a line as too long.
+const lintLineLength = 80
+
+// A Severity is the severity of a Problem.
+type Severity int
+
+const (
+	// SeverityWarning is a problem of style.
+	SeverityWarning Severity = iota + 1
+
+	// SeverityError is a document which does not decode, or which
+	// is likely not read as intended.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "Severity(?)"
+}
+
+// A Problem is a problem found by Lint, at its 1-based line and column.
+type Problem struct {
+	Line, Column int
+	Severity     Severity
+
+	// Rule names the check finding the problem: "syntax", "tabs",
+	// "trailing-spaces", "indentation", "line-length", "duplicate-key"
+	// or "empty-document".
+	Rule string
+	Msg  string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", p.Line, p.Column, p.Severity, p.Msg, p.Rule)
+}
+
+// Lint returns the problems of the documents of data, ordered by
+// position: the syntax errors, the tabs in indentation, the trailing
+// spaces, the indentation widths differing from the first one of the
+// documents, the lines longer than 80 characters, the duplicate keys
+// and the empty documents.
+func Lint(data []byte) []Problem {
+	l := &linter{}
+	l.lines(toUTF8(data))
+	l.documents(data)
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		p, q := l.problems[i], l.problems[j]
+		return p.Line < q.Line || p.Line == q.Line && p.Column < q.Column
+	})
+	return l.problems
+}
+
+type linter struct {
+	problems []Problem
+	width    int // of the indentation, once known
+}
+
+func (l *linter) report(line, column int, s Severity, rule, msg string) {
+	l.problems = append(l.problems, Problem{line, column, s, rule, msg})
+}
+
+// lines checks the text of the lines of data.
+func (l *linter) lines(data []byte) {
+	block := -1       // indentation of the line of a block scalar header
+	prev := -1        // indentation of the previous line of content
+	var entries []int // columns following the "- " of the previous line
+	start := 0        // line of the --- marker of a document without content
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		n := i + 1
+		line = bytes.TrimRight(line, "\r")
+		if t := bytes.TrimRight(line, " \t"); len(t) != len(line) {
+			l.report(n, len(t)+1, SeverityWarning, "trailing-spaces", "trailing spaces")
+		}
+		if length := utf8.RuneCount(line); length > lintLineLength {
+			l.report(n, lintLineLength+1, SeverityWarning, "line-length",
+				fmt.Sprintf("line too long (%d > %d characters)", length, lintLineLength))
+		}
+
+		content := bytes.TrimLeft(line, " \t")
+		indent := len(line) - len(content)
+		if i := bytes.IndexByte(line[:indent], '\t'); i != -1 {
+			l.report(n, i+1, SeverityError, "tabs", "tab in indentation")
+		}
+		content = bytes.TrimSpace(content)
+		if block != -1 {
+			if len(content) == 0 || indent > block {
+				continue
+			}
+			block = -1
+		}
+		switch {
+		case string(content) == "---":
+			l.emptyDocument(start)
+			start = n
+			continue
+		case string(content) == "...":
+			l.emptyDocument(start)
+			start = 0
+			continue
+		case len(content) == 0 || content[0] == '#' || content[0] == '%':
+			continue
+		}
+		start = 0
+
+		if indent > prev && prev != -1 && !containsInt(entries, indent) {
+			switch step := indent - prev; {
+			case l.width == 0:
+				l.width = step
+			case step != l.width:
+				l.report(n, indent+1, SeverityWarning, "indentation",
+					fmt.Sprintf("wrong indentation: expected %d spaces, found %d", prev+l.width, indent))
+			}
+		}
+		prev, entries = indent, entries[:0]
+		for j := indent; isSequence(line[j:]); {
+			j++ // the dash, followed by a space or the end of the line
+			for j < len(line) && line[j] == ' ' {
+				j++
+			}
+			entries = append(entries, j)
+		}
+		if isBlockHeader(content) {
+			block = indent
+		}
+	}
+	l.emptyDocument(start)
+}
+
+// emptyDocument reports the document started by the --- marker
+// of line start, if any, which has no content.
+func (l *linter) emptyDocument(start int) {
+	if start != 0 {
+		l.report(start, 1, SeverityWarning, "empty-document", "empty document")
+	}
+}
+
+func containsInt(s []int, x int) bool {
+	for _, v := range s {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
+
+// documents checks the nodes of the documents of data.
+func (l *linter) documents(data []byte) {
+	d := NewDecoderBytes(data)
+	for first := true; first || hasContent(d.Buffered()); first = false {
+		var n Node
+		if err := d.Decode(&n); err != nil {
+			l.syntaxError(err)
+			return
+		}
+		l.duplicateKeys(&n)
+	}
+}
+
+func (l *linter) syntaxError(err error) {
+	line, column, ok := errorPosition(err)
+	if !ok {
+		line, column = 1, 1
+	}
+	msg := err.Error()
+	var se *SyntaxError
+	if errors.As(err, &se) {
+		msg = strings.TrimSpace(se.Field + " " + se.Msg)
+	}
+	l.report(line, column, SeverityError, "syntax", msg)
+}
+
+// duplicateKeys reports the keys of the mappings of n
+// following an equal key.
+func (l *linter) duplicateKeys(n *Node) {
+	if n.Kind == MappingNode {
+		seen := make(map[string]*Node)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Kind != ScalarNode {
+				continue
+			}
+			if first, ok := seen[k.Value]; ok {
+				l.report(k.Line, k.Column, SeverityError, "duplicate-key",
+					fmt.Sprintf("duplicate key %q, first at line %d", k.Value, first.Line))
+				continue
+			}
+			seen[k.Value] = k
+		}
+	}
+	for _, c := range n.Content {
+		l.duplicateKeys(c)
+	}
+}
@@ -0,0 +1,316 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// A Loader decodes a configuration from several sources into a single
+// value. The sources are merged in turn, each one replacing the fields
+// it sets: the defaults, then the files in the order they were added,
+// then the environment variables, and then the values set by Set. The
+// mappings are merged, and the sequences replaced.
+type Loader struct {
+	opts     []Option
+	defaults interface{}
+	files    []loaderFile
+	prefixes []string
+	env      []envBinding
+	sets     []override
+}
+
+type loaderFile struct {
+	name     string
+	optional bool
+}
+
+type envBinding struct {
+	name, path string
+}
+
+type override struct {
+	path  string
+	value interface{}
+}
+
+// NewLoader returns a loader decoding the sources with the options.
+func NewLoader(opts ...Option) *Loader {
+	return &Loader{opts: opts}
+}
+
+// Defaults sets the value holding the defaults, which is left unchanged.
+func (l *Loader) Defaults(v interface{}) {
+	l.defaults = v
+}
+
+// File adds the file filename, which must exist.
+func (l *Loader) File(filename string) {
+	l.files = append(l.files, loaderFile{filename, false})
+}
+
+// OptionalFile adds the file filename, which is skipped if it does not exist.
+func (l *Loader) OptionalFile(filename string) {
+	l.files = append(l.files, loaderFile{filename, true})
+}
+
+// Env binds the environment variable name to path, a list of keys
+// separated by dots like "server.port". The value of the variable,
+// if it is set, is decoded as a plain scalar.
+func (l *Loader) Env(name, path string) {
+	l.env = append(l.env, envBinding{name, path})
+}
+
+// EnvPrefix binds the fields of the value to the environment
+// variables starting with prefix, as BindEnv does.
+func (l *Loader) EnvPrefix(prefix string) {
+	l.prefixes = append(l.prefixes, prefix)
+}
+
+// Set sets the value at path, a list of keys separated by dots like
+// "server.port", to value, which is encoded and then decoded.
+func (l *Loader) Set(path string, value interface{}) {
+	l.sets = append(l.sets, override{path, value})
+}
+
+// Load decodes the sources into v. They are merged before being decoded
+// at once, so that the mappings are merged even as the values of maps
+// or interfaces. The error of a node is reported in the last source
+// setting it.
+func (l *Loader) Load(v interface{}) error {
+	var layers []layer
+	if l.defaults != nil {
+		// A copy of the defaults, which v does not share.
+		s, err := l.override("", nil, l.defaults)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, s)
+	}
+
+	for _, f := range l.files {
+		data, err := ioutil.ReadFile(f.name)
+		if f.optional && os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !hasContent(toUTF8(data)) {
+			continue
+		}
+		s, err := l.parse(f.name, data, true)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		layers = append(layers, s)
+	}
+
+	for _, prefix := range l.prefixes {
+		t := reflect.TypeOf(v)
+		if t == nil || t.Kind() != reflect.Ptr {
+			return errors.New("yaml: Load expects a pointer")
+		}
+		err := envVars(t.Elem(), prefix, true, nil, func(path []pathElem, name, s string) error {
+			o, err := l.override(name, path, &Node{Kind: ScalarNode, Value: s})
+			layers = append(layers, o)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, b := range l.env {
+		s, ok := os.LookupEnv(b.name)
+		if !ok {
+			continue
+		}
+		o, err := l.overridePath(b.name, b.path, &Node{Kind: ScalarNode, Value: s})
+		if err != nil {
+			return fmt.Errorf("%s: %w", b.name, err)
+		}
+		layers = append(layers, o)
+	}
+
+	for _, o := range l.sets {
+		s, err := l.overridePath("", o.path, o.value)
+		if err != nil {
+			return err
+		}
+		layers = append(layers, s)
+	}
+
+	if len(layers) == 0 {
+		return nil
+	}
+	n := layers[0].node
+	m := newMerger(nil)
+	for _, s := range layers[1:] {
+		n = m.node(n, s.node)
+	}
+	data, err := Marshal(n)
+	if err != nil {
+		return err
+	}
+	d := NewDecoderBytes(data, l.opts...)
+	d.tmpl = nil // executed on the files
+	if err := d.Decode(v); err != nil {
+		return locate(err, layers)
+	}
+	return nil
+}
+
+// A layer is a source of a Loader, parsed into a node.
+type layer struct {
+	name string // of the file or the variable, if any
+	data []byte
+	node *Node
+}
+
+// parse parses the first document of data, the text of the source
+// name, into a layer, executing the template of the options if tmpl.
+func (l *Loader) parse(name string, data []byte, tmpl bool) (layer, error) {
+	d := NewDecoderBytes(data, l.opts...)
+	if !tmpl {
+		d.tmpl = nil
+	}
+	var n Node
+	if err := d.Decode(&n); err != nil {
+		return layer{}, err
+	}
+	return layer{name, toUTF8(data), &n}, nil
+}
+
+// overridePath returns the layer of the source name setting the value
+// at path, a list of keys separated by dots like "server.port".
+func (l *Loader) overridePath(name, path string, value interface{}) (layer, error) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return layer{}, err
+	}
+	if len(elems) == 0 {
+		return layer{}, errors.New("yaml: empty path")
+	}
+	for _, elem := range elems {
+		if elem.key == "" {
+			return layer{}, errors.New("yaml: index in path " + path)
+		}
+	}
+	return l.override(name, elems, value)
+}
+
+// override returns the layer of the source name setting the value at
+// path, a list of keys, to value, which is encoded.
+func (l *Loader) override(name string, path []pathElem, value interface{}) (layer, error) {
+	for i := len(path) - 1; i >= 0; i-- {
+		value = MapSlice{{path[i].key, value}}
+	}
+	data, err := marshalSecrets(value)
+	if err != nil {
+		return layer{}, err
+	}
+	return l.parse(name, data, false)
+}
+
+// locate returns err, an error of the decoding of the merged layers,
+// at the position of its node in the last layer holding the node,
+// following the name of that layer.
+func locate(err error, layers []layer) error {
+	if errs, ok := err.(Errors); ok {
+		located := make(Errors, len(errs))
+		for i, e := range errs {
+			located[i] = locate(e, layers)
+		}
+		return located
+	}
+
+	var field, key string
+	switch e := err.(type) {
+	case *SyntaxError:
+		field = e.Field
+	case *TypeError:
+		field = e.Field
+	case *RangeError:
+		field = e.Field
+	case *UnknownFieldError:
+		field, key = e.Field, e.Key
+	case *MissingFieldError:
+		field = e.Field
+	}
+	if field == "" && key == "" {
+		return err
+	}
+	elems, perr := parsePath(field)
+	if perr != nil {
+		return err
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		n := layerNode(layers[i].node, elems, key)
+		if n == nil {
+			continue
+		}
+		err = relocate(err, n.Line, n.Column, lineOffset(layers[i].data, n.Line, n.Column-1))
+		if layers[i].name != "" {
+			err = fmt.Errorf("%s: %w", layers[i].name, err)
+		}
+		break
+	}
+	return err
+}
+
+// layerNode returns the node at path in n, or the key node key of the
+// mapping at path if key is not empty, or nil.
+func layerNode(n *Node, path []pathElem, key string) *Node {
+	for _, elem := range path {
+		if n = childNode(n, elem); n == nil {
+			return nil
+		}
+	}
+	if key == "" {
+		return n
+	}
+	if n.Kind == MappingNode {
+		if i := nodeKey(n.Content, &Node{Kind: ScalarNode, Value: key}); i != -1 {
+			return n.Content[i]
+		}
+	}
+	return nil
+}
+
+// childNode returns the child of n at elem, or nil.
+func childNode(n *Node, elem pathElem) *Node {
+	switch {
+	case elem.key == "" && n.Kind == SequenceNode && elem.index < len(n.Content):
+		return n.Content[elem.index]
+	case elem.key != "" && n.Kind == MappingNode:
+		if i := nodeKey(n.Content, &Node{Kind: ScalarNode, Value: elem.key}); i != -1 {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ReadDir decodes into v the files of the directory dir with the .yaml
+// or .yml extension, in the lexical order of their names, as for the
+// files of a conf.d directory: each file replaces the fields it sets,
+// the mappings being merged and the sequences replaced. Hidden files
+// are skipped.
+func ReadDir(dir string, v interface{}) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	l := NewLoader()
+	for _, e := range entries {
+		name := e.Name()
+		if ext := filepath.Ext(name); e.IsDir() || name[0] == '.' || ext != ".yaml" && ext != ".yml" {
+			continue
+		}
+		l.File(filepath.Join(dir, name))
+	}
+	return l.Load(v)
+}
@@ -0,0 +1,110 @@
+package yaml
+
+import "reflect"
+
+// A MapSlice is a mapping which keeps the order of its keys.
+// Decoding a mapping into a MapSlice keeps the order of the
+// document, and encoding it writes the keys in slice order.
+type MapSlice []MapItem
+
+// A MapItem is a key and value pair of a MapSlice.
+type MapItem struct {
+	Key, Value interface{}
+}
+
+var (
+	mapSliceType  = reflect.TypeOf(MapSlice(nil))
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+func (d *Decoder) mapSlice(name string, val reflect.Value, indent, state int) error {
+	if empty, err := d.emptyFlow(name, "{}", state); empty || err != nil {
+		if empty {
+			val.Set(reflect.ValueOf(MapSlice{}))
+		}
+		return err
+	}
+	if state == stateObjectValue {
+		d.nextLine()
+		var err error
+		if indent, err = d.blockIndent(indent - 2); err != nil {
+			return err
+		}
+	}
+
+	var s MapSlice
+	if !val.IsNil() {
+		s = val.Interface().(MapSlice)[:0]
+	}
+	seen := d.keySet()
+	for {
+		key, err := d.key(name, indent, state)
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			break
+		}
+		if err := d.duplicate(name, seen, key); err != nil {
+			return err
+		}
+		d.pushKey(key)
+		k, err := d.mapKey(key, interfaceType, d.off)
+		if err != nil {
+			return err
+		}
+		item := MapItem{Key: k.Interface()}
+		if err := d.value(key, reflect.ValueOf(&item.Value).Elem(), indent+2, stateObjectValue); err != nil {
+			return err
+		}
+		d.pop()
+		s = append(s, item)
+		state = stateDefault
+	}
+	val.Set(reflect.ValueOf(s))
+	return nil
+}
+
+func (e *Encoder) mapSlice(s MapSlice, indent, state int) error {
+	if e.emptyFlow && len(s) == 0 {
+		e.buf.WriteString("{}\n")
+		return nil
+	}
+	if state == stateObjectValue {
+		e.blockStart()
+	}
+
+	names := make([]string, len(s))
+	order := make([]int, len(s))
+	for i, item := range s {
+		name, err := e.keyString(reflect.ValueOf(item.Key))
+		if err != nil {
+			return err
+		}
+		names[i] = name
+		order[i] = i
+	}
+	if e.canonical || e.sortKeys {
+		order = keyOrder(names)
+	}
+
+	i := 0
+	for _, j := range order {
+		item := s[j]
+		if e.omitted(reflect.ValueOf(item.Value)) {
+			continue
+		}
+		if i != 0 || state != stateListElem {
+			e.indent(indent)
+		}
+		e.buf.WriteString(names[j])
+		e.buf.WriteByte(':')
+		e.buf.WriteByte(' ')
+		e.field = names[j]
+		if err := e.value(reflect.ValueOf(item.Value), indent+e.step(), stateObjectValue); err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package yaml
+
+import "reflect"
+
+// A SliceStrategy is the way a sequence of an overlay is merged
+// into the sequence of a base.
+type SliceStrategy int
+
+const (
+	SliceReplace    SliceStrategy = iota // the overlay replaces the base
+	SliceAppend                          // the overlay entries follow the base ones
+	SliceMergeByKey                      // see WithMergeKey
+)
+
+// A MergeOption is an option of Merge and MergeValues.
+type MergeOption struct {
+	f func(*merger)
+}
+
+// WithSliceStrategy sets the way sequences are merged,
+// SliceReplace by default.
+func WithSliceStrategy(s SliceStrategy) MergeOption {
+	return MergeOption{func(m *merger) { m.slices = s }}
+}
+
+// WithMergeKey merges sequences by key: an entry of the overlay which
+// is a mapping holding key is merged into the entry of the base with
+// the same value of key, and the other entries are appended.
+func WithMergeKey(key string) MergeOption {
+	return MergeOption{func(m *merger) { m.slices, m.key = SliceMergeByKey, key }}
+}
+
+type merger struct {
+	slices SliceStrategy
+	key    string
+}
+
+func newMerger(opts []MergeOption) *merger {
+	m := &merger{}
+	for _, opt := range opts {
+		opt.f(m)
+	}
+	return m
+}
+
+// Merge merges the first document of overlay into the one of base,
+// and returns the text of the result. The mappings are merged deeply,
+// the sequences as set by the options, and the other values of the
+// overlay replace the ones of the base. The keys of the base keep
+// their order, followed by the new keys of the overlay.
+//
+// The comments are kept as Format keeps them, those of the base on the
+// values replaced by the overlay without comments. The anchors are kept,
+// and an alias is written as an alias as long as its anchored value is
+// not changed by the overlay, and otherwise as the value it had in base.
+func Merge(base, overlay []byte, opts ...MergeOption) ([]byte, error) {
+	b, err := decodeCommentedBytes(base)
+	if err != nil {
+		return nil, err
+	}
+	o, err := decodeCommentedBytes(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	n := b
+	switch {
+	case !hasContent(toUTF8(overlay)):
+	case !hasContent(toUTF8(base)):
+		n = o
+	default:
+		n = newMerger(opts).node(b, o)
+	}
+	return Marshal(n)
+}
+
+// decodeCommentedBytes decodes the first document of data into a node
+// holding its comments.
+func decodeCommentedBytes(data []byte) (*Node, error) {
+	d := NewDecoderBytes(data)
+	n, _, err := decodeCommented(d, scanComments(d.data))
+	return n, err
+}
+
+// node returns the merge of overlay into base, leaving both unchanged.
+func (m *merger) node(base, overlay *Node) *Node {
+	if base.Kind != overlay.Kind || overlay.Kind == ScalarNode {
+		if overlay.HeadComment != "" || overlay.LineComment != "" || overlay.FootComment != "" {
+			return overlay
+		}
+		n := *overlay
+		n.HeadComment, n.LineComment, n.FootComment = base.HeadComment, base.LineComment, base.FootComment
+		return &n
+	}
+	n := *base
+	n.Content = append([]*Node(nil), base.Content...)
+
+	if overlay.Kind == MappingNode {
+		for i := 0; i+1 < len(overlay.Content); i += 2 {
+			k, v := overlay.Content[i], overlay.Content[i+1]
+			if j := nodeKey(n.Content, k); j != -1 {
+				n.Content[j+1] = m.node(n.Content[j+1], v)
+			} else {
+				n.Content = append(n.Content, k, v)
+			}
+		}
+		return &n
+	}
+
+	switch m.slices {
+	case SliceAppend:
+		n.Content = append(n.Content, overlay.Content...)
+	case SliceMergeByKey:
+		for _, c := range overlay.Content {
+			if j := m.nodeEntry(n.Content[:len(base.Content)], c); j != -1 {
+				n.Content[j] = m.node(n.Content[j], c)
+			} else {
+				n.Content = append(n.Content, c)
+			}
+		}
+	default:
+		return overlay
+	}
+	return &n
+}
+
+// nodeKey returns the index of the scalar key k in the keys and
+// values of a mapping, or -1.
+func nodeKey(content []*Node, k *Node) int {
+	if k.Kind != ScalarNode {
+		return -1
+	}
+	for i := 0; i+1 < len(content); i += 2 {
+		if content[i].Kind == ScalarNode && content[i].Value == k.Value {
+			return i
+		}
+	}
+	return -1
+}
+
+// nodeEntry returns the index of the entry of a sequence with
+// the same value of the merge key as the mapping c, or -1.
+func (m *merger) nodeEntry(content []*Node, c *Node) int {
+	v := mergeKeyNode(c, m.key)
+	if v == nil {
+		return -1
+	}
+	for i, e := range content {
+		if w := mergeKeyNode(e, m.key); w != nil && w.Value == v.Value {
+			return i
+		}
+	}
+	return -1
+}
+
+func mergeKeyNode(n *Node, key string) *Node {
+	if n.Kind != MappingNode {
+		return nil
+	}
+	if i := nodeKey(n.Content, &Node{Kind: ScalarNode, Value: key}); i != -1 && n.Content[i+1].Kind == ScalarNode {
+		return n.Content[i+1]
+	}
+	return nil
+}
+
+// MergeValues returns the merge of overlay into base, values decoded
+// into interface{}, as Merge does for documents. The mappings are
+// map[string]interface{} or MapSlice values, and the sequences are
+// []interface{} values. base and overlay are left unchanged.
+func MergeValues(base, overlay interface{}, opts ...MergeOption) interface{} {
+	return newMerger(opts).value(base, overlay)
+}
+
+func (m *merger) value(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return overlay
+		}
+		r := make(map[string]interface{}, len(b)+len(o))
+		for k, v := range b {
+			r[k] = v
+		}
+		for k, v := range o {
+			if bv, ok := r[k]; ok {
+				v = m.value(bv, v)
+			}
+			r[k] = v
+		}
+		return r
+
+	case MapSlice:
+		b, ok := base.(MapSlice)
+		if !ok {
+			return overlay
+		}
+		r := append(MapSlice(nil), b...)
+	items:
+		for _, item := range o {
+			for i := range r[:len(b)] {
+				if r[i].Key == item.Key {
+					r[i].Value = m.value(r[i].Value, item.Value)
+					continue items
+				}
+			}
+			r = append(r, item)
+		}
+		return r
+
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return overlay
+		}
+		switch m.slices {
+		case SliceAppend:
+			return append(append([]interface{}(nil), b...), o...)
+		case SliceMergeByKey:
+			r := append([]interface{}(nil), b...)
+			for _, e := range o {
+				if j := m.entry(r[:len(b)], e); j != -1 {
+					r[j] = m.value(r[j], e)
+				} else {
+					r = append(r, e)
+				}
+			}
+			return r
+		}
+	}
+	return overlay
+}
+
+// entry returns the index of the entry of a sequence with the
+// same value of the merge key as the mapping e, or -1.
+func (m *merger) entry(s []interface{}, e interface{}) int {
+	v, ok := mergeKeyValue(e, m.key)
+	if !ok {
+		return -1
+	}
+	for i, x := range s {
+		if w, ok := mergeKeyValue(x, m.key); ok && reflect.DeepEqual(v, w) {
+			return i
+		}
+	}
+	return -1
+}
+
+func mergeKeyValue(v interface{}, key string) (interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		w, ok := v[key]
+		return w, ok
+	case MapSlice:
+		for _, item := range v {
+			if item.Key == key {
+				return item.Value, true
+			}
+		}
+	}
+	return nil, false
+}
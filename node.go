@@ -0,0 +1,496 @@
+package yaml
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+)
+
+// A Kind is the kind of a Node.
+type Kind int
+
+const (
+	ScalarNode Kind = iota + 1
+	SequenceNode
+	MappingNode
+)
+
+// A Node is a node of a document as it is written, with its position.
+// Decoding into a Node keeps the whole subtree, including the tags
+// and the position of every node. The scalars keep their text: only
+// their tags are resolved.
+type Node struct {
+	Kind Kind
+
+	// Tag is the explicit tag of the node or, without one, the tag
+	// resolved by the schema of the decoder, like !!int for 1, or
+	// !!map.
+	Tag string
+
+	// Value is the value of a scalar node.
+	Value string
+
+	// Content holds the entries of a sequence node,
+	// and the keys and values of a mapping node, in turn.
+	Content []*Node
+
+	// Anchor is the anchor of the node, like "base" for &base.
+	Anchor string
+
+	// Alias is the anchored node an alias like *base refers to. The
+	// alias is decoded as a copy of that node, sharing its content, and
+	// is encoded as an alias as long as it has the same value.
+	Alias *Node
+
+	// Line and Column are the 1-based position of the node.
+	Line, Column int
+
+	// The comments of the node, written when the node is encoded,
+	// with a "# " in front of each line. HeadComment is written on
+	// the lines before the node, LineComment at the end of its first
+	// line, and FootComment on the lines after it. Those of a mapping
+	// value are written with those of its key. Decoding leaves them
+	// empty.
+	HeadComment string
+	LineComment string
+	FootComment string
+}
+
+var nodeType = reflect.TypeOf(Node{})
+
+// node decodes the node at the current position into n.
+func (d *Decoder) node(name string, n *Node, indent, state int) error {
+	n.Line, n.Column = d.nodePos(state)
+	n.Content, n.Anchor, n.Alias = nil, "", nil
+	var tag, anchor string
+	var err error
+	if state != stateDefault {
+		if tag, anchor, err = d.properties(name); err != nil {
+			return err
+		}
+		if ok, err := d.nodeAlias(name, n); ok || err != nil {
+			if ok {
+				d.event(Alias, n)
+			}
+			return err
+		}
+	} else if tag, anchor, err = d.rootProperties(name); err != nil {
+		return err
+	}
+
+	switch d.nodeKind(indent, state) {
+	case reflect.Slice:
+		n.Kind, n.Tag = SequenceNode, tagSeq
+	case reflect.Map:
+		n.Kind, n.Tag = MappingNode, tagMap
+	default:
+		n.Kind = ScalarNode
+		if n.Value, err = d.string(indent); err != nil {
+			return err
+		}
+		n.Tag = resolveTag(d.schema, n.Value)
+		if d.quoted {
+			n.Tag = tagStr
+		} else if d.skipping {
+			if err := d.checkFlow(name, n.Value); err != nil {
+				return err
+			}
+		}
+	}
+	if tag != "" {
+		n.Tag = tag
+	}
+	n.Anchor = anchor
+
+	switch n.Kind {
+	case SequenceNode:
+		d.event(SequenceStart, n)
+		empty, err := d.emptyFlow(name, "[]", state)
+		if err == nil && !empty {
+			err = d.sequence(name, n, indent, state)
+		}
+		if err != nil {
+			return err
+		}
+		d.event(SequenceEnd, n)
+
+	case MappingNode:
+		d.event(MappingStart, n)
+		empty, err := d.emptyFlow(name, "{}", state)
+		if err == nil && !empty {
+			err = d.mapping(name, n, indent, state)
+		}
+		if err != nil {
+			return err
+		}
+		d.event(MappingEnd, n)
+
+	default:
+		d.event(Scalar, n)
+	}
+	if anchor != "" {
+		// Set once the node is decoded: it can not contain itself.
+		return d.setAnchor(anchor, reflect.ValueOf(n))
+	}
+	return nil
+}
+
+// nodeAlias decodes the alias at the current position, if any, into n,
+// and reports whether there is one.
+func (d *Decoder) nodeAlias(name string, n *Node) (bool, error) {
+	at := d.off
+	anchor, v, ok, err := d.aliasName(name)
+	if !ok || err != nil {
+		return false, err
+	}
+	target, ok := v.Interface().(*Node)
+	if !ok {
+		return false, d.typeError(name, "can not decode alias *"+anchor+" into yaml.Node", nil, nodeType, at)
+	}
+	line, column := n.Line, n.Column
+	*n = *target
+	n.Line, n.Column = line, column
+	n.Anchor, n.Alias = "", target
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	return true, nil
+}
+
+// sequence decodes the entries of a sequence node into n.
+func (d *Decoder) sequence(name string, n *Node, indent, state int) error {
+	if state == stateObjectValue {
+		d.nextLine()
+		var err error
+		if indent, err = d.sequenceIndent(indent); err != nil {
+			return err
+		}
+	}
+
+	for i := 0; ; i++ {
+		save := d.off
+		ok, err := d.tryLine(indent, state)
+		if err != nil {
+			return err
+		}
+		if !ok || d.data[d.off] != '-' {
+			d.off = save
+			return nil
+		}
+		d.off++
+		e := &Node{}
+		d.pushIndex(i)
+		if err := d.enter(name); err != nil {
+			return err
+		}
+		entryIndent, err := d.entryIndent(indent)
+		if err != nil {
+			return err
+		}
+		if err := d.node(name, e, entryIndent, stateListElem); err != nil {
+			return err
+		}
+		d.leave()
+		d.pop()
+		if !d.skipping {
+			n.Content = append(n.Content, e)
+		}
+		state = stateDefault
+	}
+}
+
+// mapping decodes the entries of a mapping node into n.
+// Unlike other mappings, the keys may be collections.
+func (d *Decoder) mapping(name string, n *Node, indent, state int) error {
+	if state == stateObjectValue {
+		d.nextLine()
+		var err error
+		if indent, err = d.blockIndent(indent - 2); err != nil {
+			return err
+		}
+	}
+
+	for {
+		save := d.off
+		ok, err := d.tryLine(indent, state)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			d.off = save
+			return nil
+		}
+		k := &Node{Kind: ScalarNode}
+		if d.indicator('?') {
+			k.Line, k.Column = d.line(d.off), d.column()+1
+			d.event(Key, k)
+			d.off++
+			if err := d.countKey(name); err != nil {
+				return err
+			}
+			if err := d.enter(name); err != nil {
+				return err
+			}
+			entryIndent, err := d.entryIndent(indent)
+			if err != nil {
+				return err
+			}
+			if err := d.node(name, k, entryIndent, stateListElem); err != nil {
+				return err
+			}
+			d.leave()
+			if err := d.explicitValue(indent); err != nil {
+				return err
+			}
+		} else {
+			k.Line, k.Column = d.line(d.off), d.column()+1
+			quoted := d.data[d.off] == '"' || d.data[d.off] == '\''
+			d.off = save
+			key, err := d.key(name, indent, state)
+			if err != nil {
+				return err
+			}
+			k.Value, k.Tag = key, resolveTag(d.schema, key)
+			if quoted {
+				k.Tag = tagStr
+			}
+			d.event(Key, k)
+		}
+
+		v := &Node{}
+		d.pushKey(k.Value)
+		if err := d.enter(k.Value); err != nil {
+			return err
+		}
+		if err := d.node(k.Value, v, indent+2, stateObjectValue); err != nil {
+			return err
+		}
+		d.leave()
+		d.pop()
+		if !d.skipping {
+			n.Content = append(n.Content, k, v)
+		}
+		state = stateDefault
+	}
+}
+
+// nodePos returns the position of the content of the node
+// at the current position.
+func (d *Decoder) nodePos(state int) (line, column int) {
+	save := d.off
+	defer func() { d.off = save }()
+
+	if state != stateDefault {
+		if rest, _ := d.peekLine(); len(bytes.TrimSpace(rest)) != 0 {
+			for d.data[d.off] == ' ' {
+				d.off++
+			}
+			return d.line(d.off), d.column() + 1
+		}
+		d.nextLine()
+	}
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			return d.line(d.off), d.column() + 1
+		}
+		if content := bytes.TrimLeft(line, " "); len(bytes.TrimSpace(content)) != 0 {
+			d.off += len(line) - len(content)
+			return d.line(d.off), d.column() + 1
+		}
+		d.off = pos
+	}
+}
+
+// resolveTag returns the tag of the plain scalar s.
+func resolveTag(schema Schema, s string) string {
+	v, err := resolve(schema, "", s)
+	if err != nil {
+		return tagStr
+	}
+	switch v.(type) {
+	case nil:
+		return tagNull
+	case bool:
+		return tagBool
+	case int:
+		return tagInt
+	case float64:
+		return tagFloat
+	}
+	return tagStr
+}
+
+// node encodes the node n.
+func (e *Encoder) node(n *Node, indent, state int) error {
+	if state == stateDefault && n.HeadComment != "" {
+		e.comment(n.HeadComment, indent)
+	}
+	pos := e.buf.Len()
+
+	if n.Anchor != "" {
+		e.buf.WriteString("&" + n.Anchor + " ")
+		defer func() {
+			if e.nodes == nil {
+				e.nodes = make(map[string]*Node)
+			}
+			e.nodes[n.Anchor] = n
+		}()
+	}
+
+	switch {
+	case e.nodeAlias(n):
+
+	case n.Kind == ScalarNode:
+		resolved := resolveTag(CoreSchema, n.Value)
+		switch {
+		case (n.Tag == "" || n.Tag == resolved) && resolved != tagStr:
+			// A plain null, bool or number.
+			e.buf.WriteString(n.Value)
+		case n.Tag == "" || n.Tag == tagStr:
+			e.string(n.Value, indent, state)
+		default:
+			e.buf.WriteString(n.Tag + " ")
+			e.string(n.Value, indent, state)
+		}
+		if n.Value == "" {
+			// An empty null ends its line, without the space
+			// following its key or its dash.
+			e.blockStart()
+		} else {
+			e.buf.WriteByte('\n')
+		}
+
+	case n.Kind == SequenceNode || n.Kind == MappingNode:
+		if len(n.Content) == 0 {
+			if n.Kind == SequenceNode {
+				e.buf.WriteString("[]\n")
+			} else {
+				e.buf.WriteString("{}\n")
+			}
+			break
+		}
+		if n.Tag != "" && n.Tag != tagSeq && n.Tag != tagMap {
+			e.buf.WriteString(n.Tag)
+			e.blockStart()
+			state = stateDefault
+		} else if n.Anchor != "" {
+			// The entries can not follow the anchor on its line.
+			e.blockStart()
+			if state != stateObjectValue {
+				state = stateDefault
+			}
+		} else if state == stateObjectValue {
+			e.blockStart()
+		}
+
+		if n.Kind == SequenceNode {
+			for i, c := range n.Content {
+				e.entryStart(i, indent, state, c.HeadComment)
+				e.buf.WriteString("- ")
+				start := e.buf.Len()
+				if err := e.node(c, indent+2, stateListElem); err != nil {
+					return err
+				}
+				e.lineComment(start, c.LineComment)
+				e.comment(c.FootComment, indent)
+			}
+			break
+		}
+
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != ScalarNode {
+				return e.error("unsupported complex key")
+			}
+			e.entryStart(i, indent, state, joinComments(k.HeadComment, v.HeadComment))
+			start := e.buf.Len()
+			if k.Tag != tagStr && resolveTag(CoreSchema, k.Value) != tagStr {
+				e.buf.WriteString(k.Value)
+			} else {
+				e.key(k.Value)
+			}
+			e.buf.WriteByte(':')
+			e.buf.WriteByte(' ')
+			if err := e.node(v, indent+e.step(), stateObjectValue); err != nil {
+				return err
+			}
+			e.lineComment(start, joinComments(k.LineComment, v.LineComment))
+			e.comment(joinComments(k.FootComment, v.FootComment), indent)
+		}
+
+	default:
+		return e.error("invalid node kind")
+	}
+
+	if state == stateDefault {
+		e.lineComment(pos, n.LineComment)
+		e.comment(n.FootComment, indent)
+	}
+	return nil
+}
+
+// nodeAlias writes the alias n, and reports whether n is written as an
+// alias: its anchor must be written, and n must have its value.
+func (e *Encoder) nodeAlias(n *Node) bool {
+	if n.Alias == nil || n.Alias.Anchor == "" {
+		return false
+	}
+	a := e.nodes[n.Alias.Anchor]
+	if a == nil || !nodesEqual(a, n) {
+		return false
+	}
+	e.buf.WriteString("*" + n.Alias.Anchor + "\n")
+	return true
+}
+
+// entryStart starts the i-th entry of a collection at indent,
+// after the head comment of the entry.
+func (e *Encoder) entryStart(i, indent, state int, head string) {
+	if head == "" {
+		if i != 0 || state != stateListElem {
+			e.indent(indent)
+		}
+		return
+	}
+	if i == 0 && state == stateListElem {
+		// The entry can not follow "- " on its line.
+		e.blockStart()
+	}
+	e.comment(head, indent)
+	e.indent(indent)
+}
+
+// comment writes the lines of the comment text at indent.
+func (e *Encoder) comment(text string, indent int) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		e.indent(indent)
+		e.buf.WriteString("# ")
+		e.buf.WriteString(line)
+		e.buf.WriteByte('\n')
+	}
+}
+
+// lineComment writes the comment text at the end
+// of the first line written from pos.
+func (e *Encoder) lineComment(pos int, text string) {
+	if text == "" {
+		return
+	}
+	b := e.buf.Bytes()
+	i := bytes.IndexByte(b[pos:], '\n')
+	if i == -1 {
+		i = len(b) - pos
+	}
+	tail := append([]byte(" # "+strings.ReplaceAll(text, "\n", " ")), b[pos+i:]...)
+	e.buf.Truncate(pos + i)
+	e.buf.Write(tail)
+}
+
+func joinComments(x, y string) string {
+	if x == "" || y == "" {
+		return x + y
+	}
+	return x + "\n" + y
+}
@@ -0,0 +1,196 @@
+package yaml
+
+import "text/template"
+
+// An Option configures a Decoder or an Encoder when it is created:
+//
+//	e := yaml.NewEncoder(yaml.WithIndent(4), yaml.WithCanonical(true))
+//
+// Each option is the equivalent of a method of the decoder or the
+// encoder. An option of the decoder is ignored by an encoder, and
+// the reverse: NewEncoder(WithStrict(true)) returns an encoder as
+// NewEncoder() does, without error, so that the options of both may
+// be kept in a single list.
+type Option struct {
+	dec func(*Decoder)
+	enc func(*Encoder)
+}
+
+// WithSchema is the option of Decoder.SetSchema.
+func WithSchema(s Schema) Option {
+	return Option{dec: func(d *Decoder) { d.SetSchema(s) }}
+}
+
+// WithEnv is the option of Decoder.AllowEnv.
+func WithEnv() Option {
+	return Option{dec: func(d *Decoder) { d.AllowEnv() }}
+}
+
+// WithExpandEnv is the option of Decoder.ExpandEnv.
+func WithExpandEnv(allow, deny []string) Option {
+	return Option{dec: func(d *Decoder) { d.ExpandEnv(allow, deny) }}
+}
+
+// WithTemplate is the option of Decoder.SetTemplate.
+func WithTemplate(funcs template.FuncMap, data interface{}) Option {
+	return Option{dec: func(d *Decoder) { d.SetTemplate(funcs, data) }}
+}
+
+// WithInclude is the option of Decoder.AllowInclude.
+func WithInclude(root string) Option {
+	return Option{dec: func(d *Decoder) { d.AllowInclude(root) }}
+}
+
+// WithExpandTabs is the option of Decoder.ExpandTabs.
+func WithExpandTabs(width int) Option {
+	return Option{dec: func(d *Decoder) { d.ExpandTabs(width) }}
+}
+
+// WithStrict is the option of Decoder.SetStrict.
+func WithStrict(on bool) Option {
+	return Option{dec: func(d *Decoder) { d.SetStrict(on) }}
+}
+
+// WithWeak is the option of Decoder.SetWeak.
+func WithWeak(on bool) Option {
+	return Option{dec: func(d *Decoder) { d.SetWeak(on) }}
+}
+
+// WithJSONCompatible is the option of Decoder.SetJSONCompatible.
+func WithJSONCompatible(on bool) Option {
+	return Option{dec: func(d *Decoder) { d.SetJSONCompatible(on) }}
+}
+
+// WithZeroCopy is the option of Decoder.SetZeroCopy.
+func WithZeroCopy(on bool) Option {
+	return Option{dec: func(d *Decoder) { d.SetZeroCopy(on) }}
+}
+
+// WithValidUTF8 is the option of Decoder.SetValidUTF8.
+func WithValidUTF8(on bool) Option {
+	return Option{dec: func(d *Decoder) { d.SetValidUTF8(on) }}
+}
+
+// WithAllErrors is the option of Decoder.SetAllErrors.
+func WithAllErrors(on bool) Option {
+	return Option{dec: func(d *Decoder) { d.SetAllErrors(on) }}
+}
+
+// WithWarningFunc is the option of Decoder.SetWarningFunc.
+func WithWarningFunc(f func(*Warning)) Option {
+	return Option{dec: func(d *Decoder) { d.SetWarningFunc(f) }}
+}
+
+// WithHook is the option of Decoder.RegisterHook.
+func WithHook(h DecodeHook) Option {
+	return Option{dec: func(d *Decoder) { d.RegisterHook(h) }}
+}
+
+// WithLimits is the option of Decoder.SetLimits.
+func WithLimits(l Limits) Option {
+	return Option{dec: func(d *Decoder) { d.SetLimits(l) }}
+}
+
+// WithEmptyFlow is the option of Encoder.SetEmptyFlow.
+func WithEmptyFlow(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetEmptyFlow(on) }}
+}
+
+// WithNilStyle is the option of Encoder.SetNilStyle.
+func WithNilStyle(s NilStyle) Option {
+	return Option{enc: func(e *Encoder) { e.SetNilStyle(s) }}
+}
+
+// WithDocumentStart is the option of Encoder.SetDocumentStart.
+func WithDocumentStart(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetDocumentStart(on) }}
+}
+
+// WithDocumentEnd is the option of Encoder.SetDocumentEnd.
+func WithDocumentEnd(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetDocumentEnd(on) }}
+}
+
+// WithIndent is the option of Encoder.SetIndent.
+func WithIndent(n int) Option {
+	return Option{enc: func(e *Encoder) { e.SetIndent(n) }}
+}
+
+// WithCanonical is the option of Encoder.SetCanonical.
+func WithCanonical(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetCanonical(on) }}
+}
+
+// WithSortKeys is the option of Encoder.SetSortKeys.
+func WithSortKeys(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetSortKeys(on) }}
+}
+
+// WithQuoteAll is the option of Encoder.SetQuoteAll.
+func WithQuoteAll(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetQuoteAll(on) }}
+}
+
+// WithLineWidth is the option of Encoder.SetLineWidth.
+func WithLineWidth(width int) Option {
+	return Option{enc: func(e *Encoder) { e.SetLineWidth(width) }}
+}
+
+// WithFloatFormat is the option of Encoder.SetFloatFormat.
+func WithFloatFormat(fmt byte, prec int) Option {
+	return Option{enc: func(e *Encoder) { e.SetFloatFormat(fmt, prec) }}
+}
+
+// WithFloatPoint is the option of Encoder.SetFloatPoint.
+func WithFloatPoint(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetFloatPoint(on) }}
+}
+
+// WithAnchors is the option of Encoder.SetAnchors.
+func WithAnchors(on bool) Option {
+	return Option{enc: func(e *Encoder) { e.SetAnchors(on) }}
+}
+
+// WithCrypto is the option of Decoder.SetCrypto and Encoder.SetCrypto.
+func WithCrypto(c *Crypto) Option {
+	return Option{
+		dec: func(d *Decoder) { d.SetCrypto(c) },
+		enc: func(e *Encoder) { e.SetCrypto(c) },
+	}
+}
+
+// WithSecretStyle is the option of Encoder.SetSecretStyle.
+func WithSecretStyle(s SecretStyle) Option {
+	return Option{enc: func(e *Encoder) { e.SetSecretStyle(s) }}
+}
+
+// WithEscape is the option of Encoder.SetEscape.
+func WithEscape(style EscapeStyle) Option {
+	return Option{enc: func(e *Encoder) { e.SetEscape(style) }}
+}
+
+// WithTimeLayout is the option of Encoder.SetTimeLayout.
+func WithTimeLayout(layout string) Option {
+	return Option{enc: func(e *Encoder) { e.SetTimeLayout(layout) }}
+}
+
+// WithDurationStyle is the option of Encoder.SetDurationStyle.
+func WithDurationStyle(s DurationStyle) Option {
+	return Option{enc: func(e *Encoder) { e.SetDurationStyle(s) }}
+}
+
+func (d *Decoder) apply(opts []Option) {
+	for _, o := range opts {
+		if o.dec != nil {
+			o.dec(d)
+		}
+	}
+}
+
+func (e *Encoder) apply(opts []Option) {
+	for _, o := range opts {
+		if o.enc != nil {
+			o.enc(e)
+		}
+	}
+}
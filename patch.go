@@ -0,0 +1,502 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A PatchKind is the format of a patch applied by ApplyPatch.
+type PatchKind int
+
+const (
+	// JSONPatch is a sequence of operations of RFC 6902, like
+	// [{"op": "replace", "path": "/spec/replicas", "value": 3}].
+	JSONPatch PatchKind = iota + 1
+
+	// StrategicMergePatch is a document merged into the document
+	// patched, as the strategic merge patches of Kubernetes: the
+	// mappings are merged deeply, a null value removes its key, and
+	// the sequences of mappings having a merge key, like "name", are
+	// merged entry by entry. Other sequences and values are replaced.
+	// A mapping with the key "$patch" set to "replace" replaces the
+	// value patched rather than being merged into it, and one set to
+	// "delete" removes it, or removes the entry of a sequence with
+	// the same merge key.
+	StrategicMergePatch
+)
+
+// strategicMergeKeys are the keys tried in turn as merge key of the
+// sequences of a strategic merge patch. A key is used when all the
+// entries of both sequences are mappings holding it.
+var strategicMergeKeys = []string{"name", "mountPath", "containerPort", "devicePath", "key", "id"}
+
+// ApplyPatch applies patch, of the format kind, to the first document
+// of doc and returns the text of the result. doc and patch may be
+// written in YAML or in JSON. The comments of doc are kept, as by
+// Format, and the value replacing a node keeps the comments of the
+// node.
+func ApplyPatch(doc, patch []byte, kind PatchKind) ([]byte, error) {
+	var n *Node
+	var err error
+	if t := bytes.TrimSpace(doc); len(t) != 0 && (t[0] == '[' || t[0] == '{') {
+		n, err = parseJSON(t)
+	} else {
+		d := NewDecoderBytes(doc)
+		n, _, err = decodeCommented(d, scanComments(d.data))
+	}
+	if err != nil {
+		return nil, err
+	}
+	p, err := parseNode(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case JSONPatch:
+		n, err = applyJSONPatch(n, p)
+	case StrategicMergePatch:
+		n, err = strategicMerge(n, p)
+		if n == nil {
+			n = &Node{Kind: ScalarNode, Tag: tagNull, Value: "null"}
+		}
+	default:
+		err = fmt.Errorf("yaml: invalid patch kind %d", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Marshal(n)
+}
+
+func applyJSONPatch(doc, patch *Node) (*Node, error) {
+	if patch.Kind != SequenceNode {
+		return nil, errors.New("yaml: JSON patch is not a sequence of operations")
+	}
+	for i, op := range patch.Content {
+		var err error
+		if doc, err = applyOperation(doc, op); err != nil {
+			return nil, fmt.Errorf("yaml: JSON patch operation %d: %w", i, err)
+		}
+	}
+	return doc, nil
+}
+
+// applyOperation applies the operation op of a JSON patch to doc,
+// and returns the resulting document.
+func applyOperation(doc, op *Node) (*Node, error) {
+	if op.Kind != MappingNode {
+		return nil, errors.New("operation is not a mapping")
+	}
+	member := func(name string) *Node {
+		if i := nodeKey(op.Content, &Node{Kind: ScalarNode, Value: name}); i != -1 {
+			return op.Content[i+1]
+		}
+		return nil
+	}
+	pointer := func(name string) ([]string, error) {
+		m := member(name)
+		if m == nil || m.Kind != ScalarNode {
+			return nil, fmt.Errorf("missing %s", name)
+		}
+		return parsePointer(m.Value)
+	}
+
+	name := member("op")
+	if name == nil || name.Kind != ScalarNode {
+		return nil, errors.New("missing op")
+	}
+	path, err := pointer("path")
+	if err != nil {
+		return nil, err
+	}
+	value := member("value")
+	switch name.Value {
+	case "add", "replace", "test":
+		if value == nil {
+			return nil, errors.New("missing value")
+		}
+	}
+
+	switch name.Value {
+	case "add":
+		return addNode(doc, path, value)
+
+	case "remove":
+		doc, _, err = removeNode(doc, path)
+		return doc, err
+
+	case "replace":
+		parent, i, err := locateNode(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return keepComments(doc, value), nil
+		}
+		parent.Content[i] = keepComments(parent.Content[i], value)
+		return doc, nil
+
+	case "move", "copy":
+		from, err := pointer("from")
+		if err != nil {
+			return nil, err
+		}
+		if name.Value == "copy" {
+			v, err := lookupNode(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return addNode(doc, path, copyNode(v))
+		}
+		if len(from) < len(path) && equalTokens(from, path[:len(from)]) {
+			return nil, errors.New("can not move a node into itself")
+		}
+		doc, v, err := removeNode(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addNode(doc, path, v)
+
+	case "test":
+		v, err := lookupNode(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !nodesEqual(v, value) {
+			return nil, fmt.Errorf("test failed at %s", pointerText(path))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("invalid op %q", name.Value)
+}
+
+// parsePointer returns the reference tokens of the JSON pointer s
+// of RFC 6901, like "/spec/containers/0".
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func pointerText(tokens []string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(t, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+func equalTokens(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// locateNode returns the parent of the node at path in doc and the
+// index of the node in the content of the parent, or a nil parent
+// for the document itself.
+func locateNode(doc *Node, path []string) (*Node, int, error) {
+	if len(path) == 0 {
+		return nil, 0, nil
+	}
+	parent, err := lookupNode(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, 0, err
+	}
+	i, err := childIndex(parent, path[len(path)-1], false)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w at %s", err, pointerText(path))
+	}
+	return parent, i, nil
+}
+
+// lookupNode returns the node at path in doc.
+func lookupNode(doc *Node, path []string) (*Node, error) {
+	n := doc
+	for i, t := range path {
+		j, err := childIndex(n, t, false)
+		if err != nil {
+			return nil, fmt.Errorf("%w at %s", err, pointerText(path[:i+1]))
+		}
+		n = n.Content[j]
+	}
+	return n, nil
+}
+
+// childIndex returns the index in the content of n of the value of
+// the key t, or of the entry t. When adding, the index may be the
+// one following the last entry, which "-" stands for.
+func childIndex(n *Node, t string, adding bool) (int, error) {
+	switch n.Kind {
+	case MappingNode:
+		if i := nodeKey(n.Content, &Node{Kind: ScalarNode, Value: t}); i != -1 {
+			return i + 1, nil
+		}
+	case SequenceNode:
+		if t == "-" && adding {
+			return len(n.Content), nil
+		}
+		i, err := strconv.Atoi(t)
+		if err != nil || i < 0 || t != strconv.Itoa(i) {
+			return 0, fmt.Errorf("invalid index %q", t)
+		}
+		if i < len(n.Content) || adding && i == len(n.Content) {
+			return i, nil
+		}
+		return 0, fmt.Errorf("index %d out of range", i)
+	}
+	return 0, ErrNotFound
+}
+
+// addNode adds value at path in doc, replacing the value of an
+// existing key, and returns the resulting document.
+func addNode(doc *Node, path []string, value *Node) (*Node, error) {
+	if len(path) == 0 {
+		return keepComments(doc, value), nil
+	}
+	parent, err := lookupNode(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	t := path[len(path)-1]
+	if parent.Kind == MappingNode {
+		if i, err := childIndex(parent, t, true); err == nil {
+			parent.Content[i] = keepComments(parent.Content[i], value)
+		} else {
+			parent.Content = append(parent.Content, &Node{Kind: ScalarNode, Tag: tagStr, Value: t}, value)
+		}
+		return doc, nil
+	}
+	i, err := childIndex(parent, t, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w at %s", err, pointerText(path))
+	}
+	parent.Content = append(parent.Content, nil)
+	copy(parent.Content[i+1:], parent.Content[i:])
+	parent.Content[i] = value
+	return doc, nil
+}
+
+// removeNode removes the node at path in doc, and returns the
+// resulting document and the node.
+func removeNode(doc *Node, path []string) (*Node, *Node, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("can not remove the document")
+	}
+	parent, i, err := locateNode(doc, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	n := parent.Content[i]
+	if parent.Kind == MappingNode {
+		parent.Content = append(parent.Content[:i-1], parent.Content[i+1:]...)
+	} else {
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+	}
+	return doc, n, nil
+}
+
+// keepComments returns n, replacing old, with the comments of old
+// when it has none.
+func keepComments(old, n *Node) *Node {
+	if n.HeadComment == "" && n.LineComment == "" && n.FootComment == "" {
+		n.HeadComment, n.LineComment, n.FootComment = old.HeadComment, old.LineComment, old.FootComment
+	}
+	return n
+}
+
+func copyNode(n *Node) *Node {
+	c := *n
+	c.Content = make([]*Node, len(n.Content))
+	for i, e := range n.Content {
+		c.Content[i] = copyNode(e)
+	}
+	return &c
+}
+
+// nodesEqual reports whether x and y have the same value, as compared
+// by Diff.
+func nodesEqual(x, y *Node) bool {
+	var d differ
+	d.node(x, y)
+	return len(d.changes) == 0
+}
+
+// strategicMerge returns the merge of the strategic merge patch into
+// base, or nil when the patch deletes base. base is modified.
+func strategicMerge(base, patch *Node) (*Node, error) {
+	switch patch.Kind {
+	case MappingNode:
+		directive, err := patchDirective(patch)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case directive == "delete":
+			return nil, nil
+		case directive == "replace" || base.Kind != MappingNode:
+			return keepComments(base, stripDirectives(patch)), nil
+		}
+		for i := 0; i+1 < len(patch.Content); i += 2 {
+			k, v := patch.Content[i], patch.Content[i+1]
+			if k.Value == "$patch" {
+				continue
+			}
+			j := nodeKey(base.Content, k)
+			if isNullNode(v) {
+				if j != -1 {
+					base.Content = append(base.Content[:j], base.Content[j+2:]...)
+				}
+				continue
+			}
+			if j == -1 {
+				if d, _ := patchDirective(v); d != "delete" {
+					base.Content = append(base.Content, k, stripDirectives(v))
+				}
+				continue
+			}
+			merged, err := strategicMerge(base.Content[j+1], v)
+			if err != nil {
+				return nil, err
+			}
+			if merged == nil {
+				base.Content = append(base.Content[:j], base.Content[j+2:]...)
+			} else {
+				base.Content[j+1] = merged
+			}
+		}
+		return base, nil
+
+	case SequenceNode:
+		if base.Kind != SequenceNode {
+			return keepComments(base, stripDirectives(patch)), nil
+		}
+		return strategicMergeSequence(base, patch)
+	}
+	return keepComments(base, patch), nil
+}
+
+// strategicMergeSequence returns the merge of the sequence patch
+// of a strategic merge patch into the sequence base.
+func strategicMergeSequence(base, patch *Node) (*Node, error) {
+	var entries []*Node
+	replace := false
+	for _, e := range patch.Content {
+		switch d, err := patchDirective(e); {
+		case err != nil:
+			return nil, err
+		case d == "replace" && len(e.Content) == 2:
+			// {$patch: replace} alone replaces the sequence.
+			replace = true
+		default:
+			entries = append(entries, e)
+		}
+	}
+
+	key := strategicMergeKey(base.Content, entries)
+	if replace || key == "" {
+		n := keepComments(base, &Node{Kind: SequenceNode, Tag: tagSeq})
+		for _, e := range entries {
+			if d, _ := patchDirective(e); d != "delete" {
+				n.Content = append(n.Content, stripDirectives(e))
+			}
+		}
+		return n, nil
+	}
+
+	m := &merger{key: key}
+	for _, e := range entries {
+		i := m.nodeEntry(base.Content, e)
+		if i == -1 {
+			if d, _ := patchDirective(e); d != "delete" {
+				base.Content = append(base.Content, stripDirectives(e))
+			}
+			continue
+		}
+		merged, err := strategicMerge(base.Content[i], e)
+		if err != nil {
+			return nil, err
+		}
+		if merged == nil {
+			base.Content = append(base.Content[:i], base.Content[i+1:]...)
+		} else {
+			base.Content[i] = merged
+		}
+	}
+	return base, nil
+}
+
+// strategicMergeKey returns the merge key of the sequences whose
+// entries are base and patch, or "" when they are not merged.
+func strategicMergeKey(base, patch []*Node) string {
+	if len(base) == 0 || len(patch) == 0 {
+		return ""
+	}
+KEYS:
+	for _, key := range strategicMergeKeys {
+		for _, entries := range [][]*Node{base, patch} {
+			for _, e := range entries {
+				if mergeKeyNode(e, key) == nil {
+					continue KEYS
+				}
+			}
+		}
+		return key
+	}
+	return ""
+}
+
+// patchDirective returns the value of the $patch key of n, if any.
+func patchDirective(n *Node) (string, error) {
+	if n.Kind != MappingNode {
+		return "", nil
+	}
+	i := nodeKey(n.Content, &Node{Kind: ScalarNode, Value: "$patch"})
+	if i == -1 {
+		return "", nil
+	}
+	switch v := n.Content[i+1].Value; v {
+	case "replace", "delete", "merge":
+		return v, nil
+	}
+	return "", fmt.Errorf("yaml: invalid $patch directive %q", n.Content[i+1].Value)
+}
+
+// stripDirectives removes the $patch keys of the mappings of n.
+func stripDirectives(n *Node) *Node {
+	if n.Kind == MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == "$patch" {
+				n.Content = append(n.Content[:i], n.Content[i+2:]...)
+				i -= 2
+			}
+		}
+	}
+	for _, c := range n.Content {
+		stripDirectives(c)
+	}
+	return n
+}
+
+func isNullNode(n *Node) bool {
+	return n.Kind == ScalarNode && n.Tag == tagNull
+}
@@ -0,0 +1,191 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when the path is not in the document.
+var ErrNotFound = errors.New("yaml: path not found")
+
+// Get decodes the node at path in the document data into v, skipping
+// the rest of the document. A path is a list of keys separated by dots
+// and of sequence indexes in brackets, like "server.listeners[0].port".
+func Get(data []byte, path string, v interface{}) error {
+	return NewDecoderBytes(data).decodePath(path, v)
+}
+
+// A pathElem is a key, or an index when key is empty.
+type pathElem struct {
+	key   string
+	index int
+}
+
+func parsePath(path string) ([]pathElem, error) {
+	var elems []pathElem
+	for path != "" {
+		switch path[0] {
+		case '.':
+			path = path[1:]
+		case '[':
+			i := strings.IndexByte(path, ']')
+			if i == -1 {
+				return nil, errors.New("yaml: missing ] in path")
+			}
+			n, err := strconv.Atoi(path[1:i])
+			if err != nil || n < 0 {
+				return nil, errors.New("yaml: invalid index " + path[:i+1])
+			}
+			elems = append(elems, pathElem{index: n})
+			path = path[i+1:]
+		default:
+			i := strings.IndexAny(path, ".[")
+			if i == -1 {
+				i = len(path)
+			}
+			elems = append(elems, pathElem{key: path[:i]})
+			path = path[i:]
+		}
+	}
+	return elems, nil
+}
+
+func (d *Decoder) decodePath(path string, i interface{}) (err error) {
+	defer d.collected(&err)
+
+	elems, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	val, err := d.begin(i)
+	if err != nil {
+		return err
+	}
+
+	indent, state := 0, stateDefault
+	for _, elem := range elems {
+		var ok bool
+		if elem.key != "" {
+			indent, ok, err = d.seekKey(path, elem.key, indent, state)
+			state = stateObjectValue
+		} else {
+			indent, ok, err = d.seekEntry(path, elem.index, indent, state)
+			state = stateListElem
+		}
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrNotFound, path)
+		}
+	}
+	d.path = append(d.path, elems...)
+	return d.value(path, val, indent, state)
+}
+
+func (d *Decoder) pushKey(key string) {
+	d.path = append(d.path, pathElem{key: key})
+}
+
+func (d *Decoder) pushIndex(i int) {
+	d.path = append(d.path, pathElem{index: i})
+}
+
+func (d *Decoder) pop() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+// field returns the path of the node being decoded, like
+// "servers[2].tls.cert_file", or name at the root of the document.
+func (d *Decoder) field(name string) string {
+	if len(d.path) == 0 {
+		return name
+	}
+	return formatPath(d.path)
+}
+
+// formatPath returns the text of the path elems, as parsed by parsePath.
+func formatPath(elems []pathElem) string {
+	var b strings.Builder
+	for i, elem := range elems {
+		if elem.key == "" {
+			b.WriteByte('[')
+			b.WriteString(strconv.Itoa(elem.index))
+			b.WriteByte(']')
+			continue
+		}
+		if i != 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(elem.key)
+	}
+	return b.String()
+}
+
+// seekKey moves to the value of key in the mapping at the current
+// position, and returns the indentation of the value.
+func (d *Decoder) seekKey(name, key string, indent, state int) (int, bool, error) {
+	if state == stateObjectValue {
+		d.nextLine()
+		var err error
+		if indent, err = d.blockIndent(indent - 2); err != nil {
+			return 0, false, err
+		}
+	}
+	for {
+		k, err := d.key(name, indent, state)
+		if err != nil || k == "" {
+			return 0, false, err
+		}
+		if k == key {
+			return indent + 2, true, nil
+		}
+		d.skipValue(indent)
+		state = stateDefault
+	}
+}
+
+// seekEntry moves to the entry n of the sequence at the current
+// position, and returns the indentation of the entry.
+func (d *Decoder) seekEntry(name string, n, indent, state int) (int, bool, error) {
+	if state == stateObjectValue {
+		d.nextLine()
+		var err error
+		if indent, err = d.sequenceIndent(indent); err != nil {
+			return 0, false, err
+		}
+	}
+	for i := 0; ; i++ {
+		ok, err := d.tryLine(indent, state)
+		if err != nil || !ok || !d.indicator('-') {
+			return 0, false, err
+		}
+		d.off++
+		if i == n {
+			break
+		}
+		d.skipEntry(indent)
+		state = stateDefault
+	}
+
+	indent, err := d.entryIndent(indent)
+	return indent, err == nil, err
+}
+
+// skipEntry skips the rest of a sequence entry at column indent.
+func (d *Decoder) skipEntry(indent int) {
+	d.nextLine()
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			return
+		}
+		if n := len(line) - len(bytes.TrimLeft(line, " ")); n < len(line) && n <= indent {
+			return
+		}
+		d.off = pos
+	}
+}
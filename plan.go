@@ -0,0 +1,114 @@
+package yaml
+
+import (
+	"reflect"
+	"sync"
+)
+
+// A planClass is the way the values of a type are decoded and encoded.
+type planClass int
+
+const (
+	planUnsupported planClass = iota
+	planRaw                   // RawMessage
+	planNode                  // Node
+	planMapSlice              // MapSlice
+	planTime                  // time.Time and time.Duration
+	planScalar                // numbers, strings and booleans
+	planPtr
+	planInterface
+	planSlice
+	planMap
+	planStruct
+)
+
+// A plan is what decoding and encoding the values of a type involves.
+// It is resolved once per type, with the plans of the types it
+// contains, so that decoding or encoding a value does not inspect its
+// type again, nor look up the plans of its elements or fields.
+type plan struct {
+	t      reflect.Type
+	class  planClass
+	elem   *plan       // of the elements of pointers, slices and maps
+	st     *structType // of structs
+	fields []*plan     // of the fields of structs, as in st
+	keys   []string    // of the fields of structs, as keyText writes them
+	sorted []int       // indexes of the fields of structs, sorted by key
+}
+
+var (
+	planMu sync.Mutex // serializes the building of plans
+	plans  sync.Map   // reflect.Type -> *plan
+)
+
+// planOf returns the plan of t.
+func planOf(t reflect.Type) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+
+	planMu.Lock()
+	defer planMu.Unlock()
+	building := make(map[reflect.Type]*plan)
+	p := buildPlan(t, building)
+	// The plans are complete, and may be used by other decoders and encoders.
+	for t, p := range building {
+		plans.Store(t, p)
+	}
+	return p
+}
+
+// buildPlan returns the plan of t. The plans being built are in
+// building, so that the plan of a recursive type refers to itself.
+func buildPlan(t reflect.Type, building map[reflect.Type]*plan) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+	if p, ok := building[t]; ok {
+		return p
+	}
+	p := &plan{t: t}
+	building[t] = p
+
+	switch {
+	case t == rawMessageType:
+		p.class = planRaw
+		return p
+	case t == nodeType:
+		p.class = planNode
+		return p
+	case t == mapSliceType:
+		p.class = planMapSlice
+		return p
+	case t == timeType || t == durationType:
+		p.class = planTime
+		return p
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		p.class = planScalar
+	case reflect.Ptr:
+		p.class = planPtr
+		p.elem = buildPlan(t.Elem(), building)
+	case reflect.Interface:
+		p.class = planInterface
+	case reflect.Slice:
+		p.class = planSlice
+		p.elem = buildPlan(t.Elem(), building)
+	case reflect.Map:
+		p.class = planMap
+		p.elem = buildPlan(t.Elem(), building)
+	case reflect.Struct:
+		p.class = planStruct
+		p.st = structFields(t)
+		for j, i := range p.st.index {
+			p.fields = append(p.fields, buildPlan(t.Field(i).Type, building))
+			p.keys = append(p.keys, keyText(p.st.tags[j].name))
+		}
+		p.sorted = keyOrder(p.keys)
+	}
+	return p
+}
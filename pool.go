@@ -0,0 +1,42 @@
+package yaml
+
+import "sync"
+
+// Marshal and Unmarshal reuse their encoders and decoders,
+// and the buffers of the encoders and line tables of the decoders.
+var (
+	encoderPool = sync.Pool{New: func() interface{} { return new(Encoder) }}
+	decoderPool = sync.Pool{New: func() interface{} { return new(Decoder) }}
+)
+
+// maxPooledBuffer is the capacity above which the buffer
+// of an encoder is not kept in the pool.
+const maxPooledBuffer = 64 << 10
+
+func getEncoder() *Encoder {
+	return encoderPool.Get().(*Encoder)
+}
+
+func putEncoder(e *Encoder) {
+	if e.buf.Cap() > maxPooledBuffer {
+		return
+	}
+	buf := e.buf
+	buf.Reset()
+	*e = Encoder{buf: buf}
+	encoderPool.Put(e)
+}
+
+func getDecoder(data []byte) *Decoder {
+	d := decoderPool.Get().(*Decoder)
+	d.Reset(data)
+	return d
+}
+
+func putDecoder(d *Decoder) {
+	// The line table keeps its storage, and the interned strings
+	// are shared by the next documents.
+	*d = Decoder{lines: d.lines, interned: d.interned, path: d.path[:0]}
+	d.lines.starts = d.lines.starts[:0]
+	decoderPool.Put(d)
+}
@@ -0,0 +1,150 @@
+package yaml
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// A RawMessage is a raw encoded YAML node. Decoding into a RawMessage
+// keeps the text of the node, comments included, as a document of its
+// own, to be decoded later. Encoding a RawMessage writes it verbatim.
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+func (d *Decoder) rawMessage(name string, val reflect.Value, indent, state int) {
+	// Skip the spaces after the key or the dash.
+	for d.off < len(d.data) && d.data[d.off] == ' ' {
+		d.off++
+	}
+	start, col := d.off, d.column()
+	d.off = d.rawEnd(indent, state)
+	text := d.data[start:d.off]
+
+	var buf bytes.Buffer
+	first := true
+	if i := bytes.IndexByte(text, '\n'); i == -1 || len(bytes.TrimSpace(text[:i])) != 0 {
+		// The node starts on the current line, and the following
+		// lines are relative to it, or to the key for a block scalar.
+		if i == -1 {
+			i = len(text)
+		}
+		buf.Write(bytes.TrimRight(text[:i], " \r"))
+		text = text[i:]
+		first = false
+		if state == stateObjectValue {
+			col = indent - 2
+			if col < 0 {
+				col = 0
+			}
+		}
+	} else {
+		// The node is a block, relative to its least indented line.
+		col = -1
+		for _, line := range bytes.Split(text[i+1:], []byte{'\n'}) {
+			if content := bytes.TrimLeft(line, " "); len(bytes.TrimSpace(content)) != 0 {
+				if n := len(line) - len(content); col == -1 || n < col {
+					col = n
+				}
+			}
+		}
+		text = text[i:]
+	}
+
+	lines := bytes.Split(bytes.TrimRight(text, " \r\n"), []byte{'\n'})[1:]
+	for _, line := range lines {
+		if !first {
+			buf.WriteByte('\n')
+		}
+		first = false
+		if len(line) > col {
+			buf.Write(line[col:])
+		}
+	}
+
+	if raw := bytes.TrimSpace(buf.Bytes()); len(raw) == 0 {
+		val.SetBytes(nil)
+		return
+	}
+	buf.WriteByte('\n')
+	val.SetBytes(buf.Bytes())
+}
+
+// rawEnd returns the end of the node at the current position, found by
+// the indentation of its lines rather than by decoding it, so that its
+// tags are decoded with the raw message, and only then.
+func (d *Decoder) rawEnd(indent, state int) int {
+	save := d.off
+	defer func() { d.off = save }()
+
+	// The lines of the node are more indented than its key or its
+	// dash, but for a sequence as indented as its key.
+	parent := -1
+	switch state {
+	case stateDefault:
+		return len(d.data)
+	case stateObjectValue:
+		parent = indent - 2
+	case stateListElem:
+		i := d.off - 1
+		for i >= 0 && d.data[i] == ' ' {
+			i--
+		}
+		parent = i - bytes.LastIndexByte(d.data[:i+1], '\n') - 1
+	}
+
+	_, end := d.peekLine()
+	d.off = end
+	for {
+		line, pos := d.peekLine()
+		if d.off == pos {
+			return end
+		}
+		text, _ := d.peekStringLine()
+		n := len(text) - len(bytes.TrimLeft(text, " "))
+		content := bytes.TrimSpace(line)
+		switch {
+		case len(bytes.TrimSpace(text)) == 0:
+		case len(content) == 0:
+			// A comment, or a line of a block scalar.
+			if n > parent {
+				end = pos
+			}
+		case n > parent, n == parent && state == stateObjectValue && isSequence(content):
+			end = pos
+		default:
+			return end
+		}
+		d.off = pos
+	}
+}
+
+func (e *Encoder) rawMessage(raw []byte, indent, state int) {
+	raw = bytes.TrimRight(raw, " \r\n")
+	if len(raw) == 0 {
+		e.buf.WriteByte('\n')
+		return
+	}
+
+	lines := bytes.Split(raw, []byte{'\n'})
+	inline := len(lines) == 1 || state == stateListElem || raw[0] == '|' || raw[0] == '>'
+	if inline && state == stateObjectValue {
+		// As in decoding, the following lines are relative to the key.
+		indent -= e.step()
+		if indent < 0 {
+			indent = 0
+		}
+	}
+	for i, line := range lines {
+		switch {
+		case i == 0 && inline:
+		case i == 0 && state == stateObjectValue:
+			e.blockStart()
+			e.indent(indent)
+		case len(line) != 0:
+			e.indent(indent)
+		}
+		e.buf.Write(line)
+		e.buf.WriteByte('\n')
+	}
+}
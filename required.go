@@ -0,0 +1,62 @@
+package yaml
+
+import (
+	"reflect"
+	"strings"
+)
+
+// A MissingFieldError describes the fields of a struct with the
+// "required" tag option, like `yaml:"token,required"`, which are
+// missing from the mapping it is decoded from.
+type MissingFieldError struct {
+	Field string   // path of the mapping
+	Keys  []string // of the missing fields
+	Type  reflect.Type
+
+	Line, Column, Offset int
+}
+
+func (e *MissingFieldError) Error() string {
+	msg := "missing required field " + e.Keys[0]
+	if len(e.Keys) > 1 {
+		msg = "missing required fields " + strings.Join(e.Keys, ", ")
+	}
+	return errorText(e.Field, msg+" of "+e.Type.String(), e.Line, e.Column)
+}
+
+func (e *MissingFieldError) Unwrap() error {
+	return ErrMissingField
+}
+
+// fieldSet returns the set of the fields of st read in a mapping,
+// by index, nil when st has no required field.
+func (d *Decoder) fieldSet(st *structType) []bool {
+	if len(st.required) == 0 {
+		return nil
+	}
+	return make([]bool, len(st.index))
+}
+
+// required fails on the required fields of st which are not in found,
+// for the mapping at offset off decoded into a struct of type t.
+// A nil found holds no field.
+func (d *Decoder) required(st *structType, found []bool, t reflect.Type, off int) error {
+	var missing []string
+	for _, i := range st.required {
+		if found == nil || !found[i] {
+			missing = append(missing, st.tags[i].name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	off = d.skipSpaces(off)
+	line, column := d.position(off)
+	err := &MissingFieldError{d.field(""), missing, t, line, column, d.offset(off)}
+	if d.allErrors {
+		d.errs = append(d.errs, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Tags of the core schema, in shorthand form.
+const (
+	tagNull  = "!!null"
+	tagBool  = "!!bool"
+	tagInt   = "!!int"
+	tagFloat = "!!float"
+	tagStr   = "!!str"
+	tagSeq   = "!!seq"
+	tagMap   = "!!map"
+)
+
+// isCoreTag reports whether tag is empty or a tag of the core schema.
+func isCoreTag(tag string) bool {
+	switch tag {
+	case "", tagNull, tagBool, tagInt, tagFloat, tagStr, tagSeq, tagMap:
+		return true
+	}
+	return false
+}
+
+// A Schema is a set of rules resolving the type of plain scalars.
+type Schema int
+
+const (
+	// CoreSchema recognizes null, booleans, integers and floats
+	// in the forms allowed by the YAML core schema, everything
+	// else is a string.
+	CoreSchema Schema = iota
+
+	// JSONSchema recognizes null, booleans and numbers only in
+	// their JSON forms, and rejects any other plain scalar.
+	JSONSchema
+
+	// FailsafeSchema resolves every plain scalar to a string.
+	FailsafeSchema
+)
+
+const longTagPrefix = "tag:yaml.org,2002:"
+
+// shortTag turns a verbatim tag such as !<tag:yaml.org,2002:str>
+// into its shorthand form !!str. Other tags are returned unchanged.
+func shortTag(tag string) string {
+	if strings.HasPrefix(tag, "!<") && strings.HasSuffix(tag, ">") {
+		tag = tag[2 : len(tag)-1]
+		if strings.HasPrefix(tag, longTagPrefix) {
+			return "!!" + tag[len(longTagPrefix):]
+		}
+	}
+	return tag
+}
+
+// resolve converts the plain scalar s to a value for an interface{}
+// target. Without a tag the type is guessed following the schema,
+// otherwise the scalar must be a valid value of the given tag.
+func resolve(schema Schema, tag, s string) (interface{}, error) {
+	if tag == "" {
+		switch schema {
+		case FailsafeSchema:
+			return s, nil
+		case JSONSchema:
+			return resolveJSON(s)
+		}
+		if isNull(s) {
+			return nil, nil
+		}
+		if b, ok := parseBool(s); ok {
+			return b, nil
+		}
+		if !isNumber(s) {
+			return s, nil
+		}
+		if i, err := strconv.ParseInt(s, 10, 0); err == nil {
+			return int(i), nil
+		}
+		if f, err := parseFloat(s); err == nil {
+			return f, nil
+		}
+		return s, nil
+	}
+
+	if schema == FailsafeSchema && tag != tagStr {
+		return nil, fmt.Errorf("unsupported tag %s", tag)
+	}
+	switch tag {
+	case tagStr:
+		return s, nil
+
+	case tagNull:
+		if isNull(s) {
+			return nil, nil
+		}
+
+	case tagBool:
+		if b, ok := parseBool(s); ok {
+			return b, nil
+		}
+
+	case tagInt:
+		if i, err := strconv.ParseInt(s, 10, 0); err == nil {
+			return int(i), nil
+		}
+
+	case tagFloat:
+		if f, err := parseFloat(s); err == nil {
+			return f, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported tag %s", tag)
+	}
+	return nil, fmt.Errorf("can not decode %q as %s", s, tag)
+}
+
+func resolveJSON(s string) (interface{}, error) {
+	switch s {
+	case "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if isJSONNumber(s) {
+		if i, err := strconv.ParseInt(s, 10, 0); err == nil {
+			return int(i), nil
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("%q is not a JSON value", s)
+}
+
+// isJSONNumber reports whether s is a number in JSON syntax.
+func isJSONNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	digits := func() int {
+		n := 0
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case digits() == 0:
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// isNumber reports whether s may be an int or float of the core schema.
+// It rules out words like "nan" or "Infinity" accepted by strconv.
+func isNumber(s string) bool {
+	switch s {
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF",
+		"-.inf", "-.Inf", "-.INF", ".nan", ".NaN", ".NAN":
+		return true
+	}
+	digit := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case '0' <= c && c <= '9':
+			digit = true
+		case c == '+' || c == '-' || c == '.' || c == 'e' || c == 'E':
+		default:
+			return false
+		}
+	}
+	return digit
+}
+
+func isNull(s string) bool {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return true
+	}
+	return false
+}
+
+func parseBool(s string) (bool, bool) {
+	switch s {
+	case "true", "True", "TRUE":
+		return true, true
+	case "false", "False", "FALSE":
+		return false, true
+	}
+	return false, false
+}
+
+func parseFloat(s string) (float64, error) {
+	switch s {
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1), nil
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1), nil
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN(), nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
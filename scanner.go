@@ -0,0 +1,241 @@
+package yaml
+
+import "bytes"
+
+// An EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	DocumentStart EventKind = iota + 1
+	DocumentEnd
+	MappingStart
+	MappingEnd
+	SequenceStart
+	SequenceEnd
+	Key
+	Scalar
+	Comment
+	Alias
+)
+
+var eventNames = [...]string{
+	DocumentStart: "DocumentStart",
+	DocumentEnd:   "DocumentEnd",
+	MappingStart:  "MappingStart",
+	MappingEnd:    "MappingEnd",
+	SequenceStart: "SequenceStart",
+	SequenceEnd:   "SequenceEnd",
+	Key:           "Key",
+	Scalar:        "Scalar",
+	Comment:       "Comment",
+	Alias:         "Alias",
+}
+
+func (k EventKind) String() string {
+	if 0 < k && int(k) < len(eventNames) {
+		return eventNames[k]
+	}
+	return "EventKind(?)"
+}
+
+// An Event is a syntactic element of a document.
+//
+// A Key event is followed by the events of the value of the key.
+// When the key is not a scalar, the events of the key itself come
+// in between.
+type Event struct {
+	Kind EventKind
+
+	// Tag is the tag of a scalar or collection, as in Node.
+	Tag string
+
+	// Anchor is the anchor of a scalar or collection, as in Node.
+	Anchor string
+
+	// Value is the value of a scalar or key, the anchor of an alias,
+	// or the text of a comment without the leading #.
+	Value string
+
+	// Line and Column are the 1-based position of the event in the
+	// input. The end events are at the position following their
+	// node, and a DocumentStart event at the start of the text of
+	// the document, before its directives.
+	Line, Column int
+}
+
+// A Scanner reads the events of the documents of its input:
+//
+//	s := yaml.NewScanner(data)
+//	for s.Scan() {
+//		e := s.Event()
+//		...
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// The events of a document are read when the scanner reaches it. On a
+// syntax error, the events read before the error are returned first.
+type Scanner struct {
+	d        *Decoder
+	comments []Event // of the documents left
+	events   []Event // of the document being read
+	next     int
+	docs     int // number of documents read
+	err      error
+}
+
+// NewScanner returns a scanner reading data, which may be encoded
+// in UTF-8 or UTF-16 and may start with a byte order mark.
+func NewScanner(data []byte) *Scanner {
+	d := NewDecoderBytes(data)
+	return &Scanner{d: d, comments: scanComments(d.data)}
+}
+
+// Scan advances to the next event. It returns false after the events
+// of the last document, or after the events preceding an error.
+func (s *Scanner) Scan() bool {
+	for s.next == len(s.events) {
+		if s.err != nil || s.docs > 0 && !hasContent(s.d.Buffered()) {
+			return false
+		}
+		s.document()
+	}
+	s.next++
+	return true
+}
+
+// Event returns the current event.
+func (s *Scanner) Event() Event {
+	return s.events[s.next-1]
+}
+
+// Err returns the error which stopped the scanner, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// document reads the events of the next document.
+func (s *Scanner) document() {
+	d := s.d
+	s.events, s.next = s.events[:0], 0
+	s.docs++
+
+	line, column := d.position(d.off)
+	s.events = append(s.events, Event{Kind: DocumentStart, Line: line, Column: column})
+	d.events = s.emit
+	var root Node
+	s.err = d.Decode(&root)
+	d.events = nil
+	if s.err != nil {
+		return
+	}
+	line, column = d.position(d.off)
+	end := Event{Kind: DocumentEnd, Line: line, Column: column}
+	s.flush(end)
+	s.events = append(s.events, end)
+}
+
+// emit appends the event e of the document, after the comments before
+// it, followed by the comment ending the line of a scalar or an alias.
+func (s *Scanner) emit(e Event) {
+	if e.Kind != MappingEnd && e.Kind != SequenceEnd {
+		s.flush(e)
+	}
+	s.events = append(s.events, e)
+	for (e.Kind == Scalar || e.Kind == Alias) && len(s.comments) > 0 && s.comments[0].Line == e.Line {
+		s.events = append(s.events, s.comments[0])
+		s.comments = s.comments[1:]
+	}
+}
+
+// flush appends the comments before the event e.
+func (s *Scanner) flush(e Event) {
+	for len(s.comments) > 0 && before(s.comments[0], e) {
+		s.events = append(s.events, s.comments[0])
+		s.comments = s.comments[1:]
+	}
+}
+
+func before(x, y Event) bool {
+	return x.Line < y.Line || x.Line == y.Line && x.Column < y.Column
+}
+
+// event sends the event of kind of the node n, being decoded, to the
+// scanner reading the document, if any.
+func (d *Decoder) event(kind EventKind, n *Node) {
+	if d.events == nil {
+		return
+	}
+	e := Event{Kind: kind, Line: n.Line, Column: n.Column}
+	switch kind {
+	case MappingEnd, SequenceEnd:
+		e.Line, e.Column = d.position(d.off)
+	case Key:
+		e.Value = n.Value
+	case Alias:
+		e.Value = n.Alias.Anchor
+	default:
+		e.Tag, e.Anchor, e.Value = n.Tag, n.Anchor, n.Value
+	}
+	d.events(e)
+}
+
+// scanComments returns the comments of data in order. The lines
+// of block scalars, more indented than the line of their header,
+// and the quoted scalars hold no comments.
+func scanComments(data []byte) []Event {
+	var comments []Event
+	block := -1 // indentation of the line of a block scalar header
+	for n, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		content := bytes.TrimLeft(line, " ")
+		indent := len(line) - len(content)
+		if block != -1 {
+			if len(content) == 0 || indent > block {
+				continue
+			}
+			block = -1
+		}
+
+		end := len(line)
+		var quote byte // of the quoted scalar being read, if any
+	LINE:
+		for i := 0; i < len(line); i++ {
+			switch c := line[i]; {
+			case quote != 0:
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+			case (c == '"' || c == '\'') && (i == 0 || isBlank(line, i-1)):
+				quote = c
+			case c == '#' && (i == 0 || isBlank(line, i-1)):
+				comments = append(comments, Event{
+					Kind:   Comment,
+					Value:  string(line[i+1:]),
+					Line:   n + 1,
+					Column: i + 1,
+				})
+				end = i
+				break LINE
+			}
+		}
+		if isBlockHeader(bytes.TrimSpace(line[:end])) {
+			block = indent
+		}
+	}
+	return comments
+}
+
+// isBlockHeader reports whether line ends with the header
+// of a block scalar.
+func isBlockHeader(line []byte) bool {
+	i := bytes.LastIndexAny(line, "|>")
+	if i == -1 || i > 0 && line[i-1] != ' ' {
+		return false
+	}
+	_, _, ok := blockHeader(line[i+1:])
+	return ok
+}
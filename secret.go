@@ -0,0 +1,49 @@
+package yaml
+
+import "reflect"
+
+// A SecretStyle is the way the fields with the "secret" tag option,
+// like `yaml:"password,secret"`, are written.
+type SecretStyle int
+
+const (
+	SecretMask SecretStyle = iota // as "****", unless they are zero
+	SecretOmit                    // not at all
+	SecretShow                    // as other fields
+)
+
+// secretMask is the text written for a masked secret.
+const secretMask = "****"
+
+// SetSecretStyle sets the way the fields with the "secret" tag option
+// are written, SecretMask by default, so that the values written can
+// be logged. SecretShow writes them, as when a document is saved.
+func (e *Encoder) SetSecretStyle(s SecretStyle) {
+	e.secrets = s
+}
+
+// MarshalRedacted returns the text of v without the fields with
+// the "secret" tag option, which Marshal writes as "****".
+func MarshalRedacted(v interface{}) ([]byte, error) {
+	return NewEncoder(WithSecretStyle(SecretOmit)).Encode(v)
+}
+
+// marshalSecrets is Marshal writing the secret fields, for the
+// documents which are decoded again.
+func marshalSecrets(v interface{}) ([]byte, error) {
+	return NewEncoder(WithSecretStyle(SecretShow)).Encode(v)
+}
+
+// secret returns the value written for the secret field v,
+// and false if it is omitted.
+func (e *Encoder) secret(v reflect.Value) (reflect.Value, bool) {
+	switch e.secrets {
+	case SecretOmit:
+		return v, false
+	case SecretMask:
+		if !isZeroValue(v) {
+			return reflect.ValueOf(secretMask), true
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,115 @@
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// snippetLines is the number of lines shown before and after
+// the line of an error.
+const snippetLines = 2
+
+// FormatError returns the text of err followed, when it has a position,
+// by the lines of data around it with a caret under the column of the
+// error, like:
+//
+//	port strconv.ParseInt: parsing "x": invalid syntax at line 3, column 7
+//	  1 | name: app
+//	  2 | host: example.com
+//	  3 | port: x
+//	    |       ^
+//	  4 | debug: true
+//
+// data is the document which was decoded. Each error of Errors
+// is formatted in turn.
+func FormatError(data []byte, err error) string {
+	var errs Errors
+	if errors.As(err, &errs) {
+		s := make([]string, len(errs))
+		for i, err := range errs {
+			s[i] = FormatError(data, err)
+		}
+		return strings.Join(s, "\n")
+	}
+
+	line, column, ok := errorPosition(err)
+	if !ok {
+		return err.Error()
+	}
+	lines := bytes.Split(data, []byte{'\n'})
+	if line < 1 || line > len(lines) {
+		return err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString(err.Error())
+	b.WriteByte('\n')
+	first, last := line-snippetLines, line+snippetLines
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+	if last == len(lines) && len(lines[last-1]) == 0 && last > line {
+		last-- // the end of the final line
+	}
+	width := len(fmt.Sprint(last))
+	for n := first; n <= last; n++ {
+		text := bytes.TrimRight(lines[n-1], "\r")
+		fmt.Fprintf(&b, "%*d | %s\n", width+2, n, text)
+		if n == line {
+			fmt.Fprintf(&b, "%*s | %s^\n", width+2, "", caretPadding(text, column))
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// errorPosition returns the line and column of err.
+func errorPosition(err error) (line, column int, ok bool) {
+	var (
+		se *SyntaxError
+		te *TypeError
+		ue *UnknownFieldError
+		me *MissingFieldError
+		re *RangeError
+	)
+	switch {
+	case errors.As(err, &se):
+		return se.Line, se.Column, true
+	case errors.As(err, &te):
+		return te.Line, te.Column, true
+	case errors.As(err, &ue):
+		return ue.Line, ue.Column, true
+	case errors.As(err, &me):
+		return me.Line, me.Column, true
+	case errors.As(err, &re):
+		return re.Line, re.Column, true
+	}
+	return 0, 0, false
+}
+
+// caretPadding returns the blank text which puts a caret under
+// the byte column of text, keeping its tabs.
+func caretPadding(text []byte, column int) string {
+	if column < 1 {
+		column = 1
+	}
+	if column-1 < len(text) {
+		text = text[:column-1]
+	}
+	var b strings.Builder
+	for _, r := range string(text) {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	if column-1 > len(text) {
+		b.WriteString(strings.Repeat(" ", column-1-len(text)))
+	}
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// A splitter reads the documents of a stream one at a time, separated
+// by --- and ... markers, so that the memory used is the one of the
+// largest document rather than the one of the stream.
+type splitter struct {
+	r    io.Reader
+	br   *bufio.Reader
+	line int   // line of the next line read, from 1
+	off  int64 // offset of the next line read
+
+	// pending is the --- line starting the next document, read
+	// at the end of the previous one, at pendingLine and pendingOff.
+	pending     []byte
+	pendingLine int
+	pendingOff  int64
+
+	// continued tells whether the last document returned starts with
+	// the --- line which ended the previous one.
+	continued bool
+}
+
+func newSplitter(r io.Reader) *splitter {
+	return &splitter{r: r, line: 1}
+}
+
+// start detects the encoding of the stream. A stream in UTF-16 is read
+// at once, to be transcoded; the offsets are then the ones of UTF-8.
+func (s *splitter) start() error {
+	s.br = bufio.NewReader(s.r)
+	b, err := s.br.Peek(3)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch {
+	case len(b) == 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		s.br.Discard(3)
+	case len(b) >= 2 && (b[0] == 0 || b[1] == 0 || b[0] == 0xFE && b[1] == 0xFF || b[0] == 0xFF && b[1] == 0xFE):
+		data, err := ioutil.ReadAll(s.br)
+		if err != nil {
+			return err
+		}
+		s.br = bufio.NewReader(bytes.NewReader(toUTF8(data)))
+	}
+	return nil
+}
+
+// next returns the next document, holding more than directives and
+// comments, with the line and the offset of the stream where it starts.
+// The document holds its ... end marker, if any.
+// It returns io.EOF after the last document, and an error when the
+// document is larger than max bytes, unless max is negative.
+func (s *splitter) next(max int) (doc []byte, line int, off int64, err error) {
+	if s.br == nil {
+		if err := s.start(); err != nil {
+			return nil, 0, 0, err
+		}
+	}
+
+	var buf []byte
+	content := false
+	s.continued = s.pending != nil
+	if s.pending != nil {
+		buf, line, off, content = s.pending, s.pendingLine, s.pendingOff, true
+		s.pending = nil
+	}
+	for {
+		l, err := s.br.ReadBytes('\n')
+		if len(l) > 0 {
+			n, o := s.line, s.off
+			s.line++
+			s.off += int64(len(l))
+
+			t := bytes.TrimRight(l, " \t\r\n")
+			switch {
+			case string(t) == "---":
+				if content {
+					s.pending, s.pendingLine, s.pendingOff = l, n, o
+					return buf, line, off, nil
+				}
+				if len(buf) == 0 {
+					line, off = n, o
+				}
+				buf = append(buf, l...)
+				content = true
+
+			case string(t) == "...":
+				if content {
+					return append(buf, l...), line, off, nil
+				}
+				buf = nil
+
+			default:
+				if len(buf) == 0 {
+					line, off = n, o
+				}
+				buf = append(buf, l...)
+				if max >= 0 && len(buf) > max {
+					return nil, 0, 0, fmt.Errorf("document larger than %d bytes at line %d", max, line)
+				}
+				t = bytes.TrimSpace(t)
+				if len(t) != 0 && t[0] != '#' && t[0] != '%' {
+					content = true
+				}
+			}
+		}
+
+		if err == io.EOF {
+			if content {
+				return buf, line, off, nil
+			}
+			return nil, 0, 0, io.EOF
+		}
+		if err != nil {
+			return nil, 0, 0, err
+		}
+	}
+}
+
+// buffered returns the text read from the stream and not returned yet.
+func (s *splitter) buffered() []byte {
+	rest := append([]byte(nil), s.pending...)
+	if s.br != nil {
+		b, _ := s.br.Peek(s.br.Buffered())
+		rest = append(rest, b...)
+	}
+	return rest
+}
+
+// SplitDocuments returns the documents of the stream data, separated by
+// --- and ... markers, without decoding them. Each document holds its
+// directives and its start marker, if any, and the documents holding
+// only comments are left out. As in the YAML specification, a marker is
+// a line starting at the first column, which ends the document even
+// within a block scalar or a quoted scalar: the lines of these scalars
+// are indented, or can not be the text of a marker.
+//
+// The documents are slices of data, unless data is in UTF-16.
+func SplitDocuments(data []byte) [][]byte {
+	data = toUTF8(data)
+
+	var docs [][]byte
+	start, content := 0, false
+	for i := 0; i < len(data); {
+		j := bytes.IndexByte(data[i:], '\n') + 1
+		if j == 0 {
+			j = len(data)
+		} else {
+			j += i
+		}
+
+		t := bytes.TrimRight(data[i:j], " \t\r\n")
+		switch {
+		case string(t) == "---":
+			if content {
+				docs = append(docs, data[start:i])
+				start = i
+			}
+			content = true
+
+		case string(t) == "...":
+			if content {
+				docs = append(docs, data[start:j])
+			}
+			start, content = j, false
+
+		default:
+			t = bytes.TrimSpace(t)
+			if len(t) != 0 && t[0] != '#' && t[0] != '%' {
+				content = true
+			}
+		}
+		i = j
+	}
+	if content {
+		docs = append(docs, data[start:])
+	}
+	return docs
+}
@@ -0,0 +1,83 @@
+package yaml
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// A Document is a document read from a stream.
+type Document struct {
+	// Data is the text of the document, with its directives
+	// and its start marker if any.
+	Data []byte
+
+	// Line is the line of the stream where the document starts.
+	Line int
+
+	d *Decoder // options
+}
+
+// Decode decodes the document into v, with the options
+// of the decoder which read the stream.
+func (doc Document) Decode(v interface{}) error {
+	d := doc.d.clone()
+	d.Reset(doc.Data)
+	return d.Decode(v)
+}
+
+// Stream reads the documents of the input of d not decoded yet,
+// separated by --- and ... markers, in a goroutine, and sends them on
+// the returned channel as soon as they are read, so that a long stream
+// is processed while it is being read. The decoder must not be used
+// after Stream.
+//
+// Both channels are closed at the end of the input. An error reading
+// it, or the error of ctx when it is done, is sent before the end.
+func (d *Decoder) Stream(ctx context.Context) (<-chan Document, <-chan error) {
+	docs := make(chan Document)
+	errc := make(chan error, 1)
+	opts := d.clone()
+
+	// The documents follow the last one decoded, on the line of
+	// InputOffset.
+	line, _ := d.position(d.off)
+	var r io.Reader = bytes.NewReader(d.data[d.off:])
+	if s := d.split; s != nil {
+		pending := s.pending
+		if d.markerRead() {
+			line, pending = s.pendingLine+1, nil
+		}
+		// The reader of the splitter holds the input transcoded.
+		rest := s.r
+		if s.br != nil {
+			rest = s.br
+		}
+		r = io.MultiReader(r, bytes.NewReader(pending), rest)
+	}
+	s := newSplitter(r)
+	s.line = line
+
+	go func() {
+		defer close(errc)
+		defer close(docs)
+
+		for {
+			data, line, _, err := s.next(opts.maxDocumentSize())
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case docs <- Document{Data: data, Line: line, d: opts}:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+		}
+	}()
+	return docs, errc
+}
@@ -0,0 +1,61 @@
+package yaml
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// SetStrict makes the decoder reject the duplicate keys of mappings,
+// the quoted scalars decoded into numbers and booleans, and the plain
+// scalars which are not strings, such as 1.10 or true, decoded into
+// strings. Undefined fields are always rejected.
+func (d *Decoder) SetStrict(on bool) {
+	d.strict = on
+}
+
+// keySet returns the set of the keys read in a mapping,
+// nil when the decoder is not strict.
+func (d *Decoder) keySet() map[string]bool {
+	if !d.strict {
+		return nil
+	}
+	return make(map[string]bool)
+}
+
+// duplicate fails on key if it is in seen, and adds it to seen.
+func (d *Decoder) duplicate(name string, seen map[string]bool, key string) error {
+	if seen == nil {
+		return nil
+	}
+	if seen[key] {
+		return d.syntaxError(name, "duplicate key "+key, ErrDuplicateKey)
+	}
+	seen[key] = true
+	return nil
+}
+
+// coerced fails on the scalar str, read at offset start, when its
+// type is not the one of val. The checks apply to strict decoders,
+// and to untagged scalars.
+func (d *Decoder) coerced(name string, val reflect.Value, tag, str string, start int) error {
+	if !d.strict || d.weak || tag != "" {
+		return nil
+	}
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Bool:
+		if d.quoted {
+			return d.typeError(name, "quoted scalar "+str+" for "+val.Type().String(), nil, val.Type(), start)
+		}
+	case reflect.String:
+		plain := !d.quoted
+		if s := bytes.TrimLeft(d.data[start:], " "); len(s) > 0 && (s[0] == '|' || s[0] == '>') {
+			plain = false
+		}
+		if plain && resolveTag(d.schema, str) != tagStr {
+			return d.typeError(name, "scalar "+str+" is not a string", nil, val.Type(), start)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	tagEnv     = "!env"
+	tagInclude = "!include"
+)
+
+// A TagFunc decodes the scalar value of a node tagged with a custom tag
+// into target. target is always settable.
+type TagFunc func(value string, target reflect.Value) error
+
+var (
+	tagMu    sync.RWMutex
+	tagFuncs = make(map[string]TagFunc)
+)
+
+// RegisterTag makes f the decoder of scalars tagged with tag, such as
+// "!ref" or "!base64". If RegisterTag is called twice with the same
+// tag, or if f is nil, it panics.
+func RegisterTag(tag string, f TagFunc) {
+	tagMu.Lock()
+	defer tagMu.Unlock()
+	if f == nil {
+		panic("yaml: RegisterTag func is nil")
+	}
+	if _, dup := tagFuncs[tag]; dup {
+		panic("yaml: RegisterTag called twice for tag " + tag)
+	}
+	tagFuncs[tag] = f
+}
+
+func lookupTag(tag string) TagFunc {
+	tagMu.RLock()
+	defer tagMu.RUnlock()
+	return tagFuncs[tag]
+}
+
+// env returns the value of the environment variable key for a !env tag.
+func (d *Decoder) env(name, key string) (string, error) {
+	if !d.allowEnv {
+		return "", d.error(name, "tag "+tagEnv+" is not allowed")
+	}
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return "", d.error(name, "environment variable "+key+" is not set")
+	}
+	return v, nil
+}
+
+// include decodes the file named by an !include tag into val.
+func (d *Decoder) include(name string, val reflect.Value, file string) error {
+	if d.includeRoot == "" {
+		return d.error(name, "tag "+tagInclude+" is not allowed")
+	}
+	path, err := includePath(d.includeRoot, file)
+	if err != nil {
+		return d.error(name, err.Error())
+	}
+	for _, p := range d.includes {
+		if p == path {
+			return d.error(name, "include cycle through "+file)
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return d.error(name, err.Error())
+	}
+
+	// The first document of the file is decoded, as by Decode.
+	sub := d.clone()
+	sub.path = append(sub.path, d.path...)
+	sub.Reset(data)
+	sub.includes = append(sub.includes, path)
+	err = sub.prepare()
+	if err == nil {
+		end, _ := documentEnd(sub.data, sub.off)
+		sub.data = sub.data[:end]
+		err = sub.value(name, val, 0, stateDefault)
+	}
+	// The limits apply to the document with its includes.
+	d.keys, d.aliases = sub.keys, sub.aliases
+	for _, e := range sub.errs {
+		d.errs = append(d.errs, fmt.Errorf("%s: %w", file, e))
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+// includePath returns the path of the file named by an !include tag,
+// with its symbolic links resolved, which must be in root.
+func includePath(root, file string) (string, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(root, filepath.Clean("/"+file)))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("file " + file + " is outside the include root")
+	}
+	return path, nil
+}
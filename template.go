@@ -0,0 +1,78 @@
+package yaml
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"text/template"
+)
+
+// templateName is the name of the template of a document, in the
+// errors of text/template.
+const templateName = "yaml"
+
+type templateConfig struct {
+	funcs template.FuncMap
+	data  interface{}
+}
+
+// SetTemplate makes the decoder execute each document as a template of
+// text/template, with the functions funcs and the data data, before
+// decoding its output. With the funcs template.FuncMap{"env": os.Getenv}:
+//
+//	host: {{ .Host }}
+//	port: {{ env "PORT" }}
+//
+// A template error is a SyntaxError at its line and column in the
+// document. As the output of the template is decoded, the positions of
+// the other errors are the ones of the output.
+func (d *Decoder) SetTemplate(funcs template.FuncMap, data interface{}) {
+	d.tmpl = &templateConfig{funcs, data}
+}
+
+// templateError matches the errors of text/template, for the
+// template of a document.
+var templateError = regexp.MustCompile(`(?s)^template: ` + templateName + `:(\d+)(?::(\d+))?: (.*)$`)
+
+// execTemplate replaces the text of the document with the output
+// of its template.
+func (d *Decoder) execTemplate() error {
+	t, err := template.New(templateName).Option("missingkey=error").Funcs(d.tmpl.funcs).Parse(string(d.data))
+	var out bytes.Buffer
+	if err == nil {
+		err = t.Execute(&out, d.tmpl.data)
+	}
+	if err != nil {
+		msg := err.Error()
+		if m := templateError.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			column, _ := strconv.Atoi(m[2])
+			d.off = lineOffset(d.data, line, column)
+			msg = m[3]
+		}
+		return d.syntaxError("", "template: "+msg, err)
+	}
+	d.data = out.Bytes()
+	return nil
+}
+
+// lineOffset returns the offset of the 1-based line and the 0-based
+// column of data, or the nearest one.
+func lineOffset(data []byte, line, column int) int {
+	off := 0
+	for n := 1; n < line; n++ {
+		i := bytes.IndexByte(data[off:], '\n')
+		if i == -1 {
+			return len(data)
+		}
+		off += i + 1
+	}
+	end := bytes.IndexByte(data[off:], '\n')
+	if end == -1 {
+		end = len(data) - off
+	}
+	if column > end {
+		column = end
+	}
+	return off + column
+}
@@ -0,0 +1,102 @@
+package yaml
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// timeLayouts are the layouts of the times decoded,
+// those of the YAML timestamps first.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02t15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999 -07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// time decodes the time or duration str, read at offset start, into val.
+// A duration is either written as in time.ParseDuration, or is a number
+// of seconds.
+func (d *Decoder) time(name string, val reflect.Value, str string, start int) error {
+	return d.fieldError(d.setTime(name, val, str, start))
+}
+
+func (d *Decoder) setTime(name string, val reflect.Value, str string, start int) error {
+	if val.Type() == durationType {
+		if dur, err := time.ParseDuration(str); err == nil {
+			val.SetInt(int64(dur))
+			return nil
+		}
+		f, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return d.typeError(name, "invalid duration "+str, nil, val.Type(), start)
+		}
+		if f*float64(time.Second) > float64(1<<63-1) || f*float64(time.Second) < -float64(1<<63) {
+			return d.rangeError(name, str, val.Type(), start)
+		}
+		val.SetInt(int64(f * float64(time.Second)))
+		return nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, str); err == nil {
+			val.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	return d.typeError(name, "invalid time "+str, nil, val.Type(), start)
+}
+
+// A DurationStyle is the way time.Duration values are written.
+type DurationStyle int
+
+const (
+	DurationString  DurationStyle = iota // like 1h30m0s
+	DurationSeconds                      // as a number of seconds, like 5400
+)
+
+// SetTimeLayout sets the layout of time.Time values, as in time.Format.
+// The default is time.RFC3339Nano.
+func (e *Encoder) SetTimeLayout(layout string) {
+	e.timeLayout = layout
+}
+
+// SetDurationStyle sets the way time.Duration values are written.
+func (e *Encoder) SetDurationStyle(s DurationStyle) {
+	e.durationStyle = s
+}
+
+// timeText returns the text of val if it is a time or a duration.
+func (e *Encoder) timeText(val reflect.Value) (string, bool) {
+	if !val.IsValid() {
+		return "", false
+	}
+	switch val.Type() {
+	case timeType:
+		layout := e.timeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		s := val.Interface().(time.Time).Format(layout)
+		if !isNumber(s) && needsQuote(s) {
+			s = strconv.Quote(s)
+		}
+		return s, true
+
+	case durationType:
+		dur := time.Duration(val.Int())
+		if e.durationStyle == DurationSeconds {
+			return strconv.FormatFloat(dur.Seconds(), 'f', -1, 64), true
+		}
+		return dur.String(), true
+	}
+	return "", false
+}
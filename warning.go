@@ -0,0 +1,42 @@
+package yaml
+
+// A Warning describes a part of a document which is decoded,
+// but should be changed, such as a deprecated field.
+type Warning struct {
+	Msg   string
+	Field string // path of the node
+
+	Line, Column, Offset int
+}
+
+func (w *Warning) String() string {
+	return errorText(w.Field, w.Msg, w.Line, w.Column)
+}
+
+// SetWarningFunc makes the decoder call f with the warnings of the
+// documents, such as the use of the fields with the "deprecated" tag
+// option, like `yaml:"old_name,deprecated=use new_name"`. The note
+// following "deprecated=" is added to the warning, and can not hold
+// a comma. The warnings are dropped when f is nil.
+func (d *Decoder) SetWarningFunc(f func(*Warning)) {
+	d.warn = f
+}
+
+// deprecated warns of the use of the deprecated field of tag,
+// whose key is at offset off.
+func (d *Decoder) deprecated(tag fieldTag, off int) {
+	msg := "is deprecated"
+	if tag.deprecation != "" {
+		msg += ": " + tag.deprecation
+	}
+	d.warning(msg, off)
+}
+
+func (d *Decoder) warning(msg string, off int) {
+	if d.warn == nil {
+		return
+	}
+	off = d.skipSpaces(off)
+	line, column := d.position(off)
+	d.warn(&Warning{msg, d.field(""), line, column, d.offset(off)})
+}
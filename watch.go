@@ -0,0 +1,87 @@
+package yaml
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// watchInterval is the time between two checks of a watched file.
+var watchInterval = time.Second
+
+// Watch decodes the file filename into v, and then checks the file
+// every second: when its size or its modification time change, it
+// decodes the file again into a new value of the type v points to, and
+// calls onChange with a pointer to it, or with the error. The new value
+// is complete and no longer written when onChange gets it, and v is
+// not written after Watch returns, so that onChange may publish the
+// value, for instance with an atomic.Value, without other lock. An
+// error is reported once until the file changes again.
+//
+// stop stops watching the file, and returns after the last call to
+// onChange; it must not be called from onChange.
+func Watch(filename string, v interface{}, onChange func(v interface{}, err error)) (stop func(), err error) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return nil, errors.New("yaml: Watch expects a pointer")
+	}
+	if onChange == nil {
+		return nil, errors.New("yaml: Watch expects an onChange function")
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := ReadFile(filename, v); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		last := info
+		var lastErr error
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(filename)
+			if err == nil {
+				if last != nil && info.Size() == last.Size() && info.ModTime().Equal(last.ModTime()) {
+					continue
+				}
+				last = info
+				nv := reflect.New(val.Elem().Type()).Interface()
+				if err = ReadFile(filename, nv); err == nil {
+					lastErr = nil
+					onChange(nv, nil)
+					continue
+				}
+			} else {
+				last = nil
+			}
+			if lastErr == nil || err.Error() != lastErr.Error() {
+				lastErr = err
+				onChange(nil, err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(done)
+			<-exited
+		})
+	}
+	return stop, nil
+}
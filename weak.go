@@ -0,0 +1,80 @@
+package yaml
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SetWeak makes the decoder coerce the scalars to the type of their
+// field when the conversion is unambiguous: yes, no, on, off and the
+// numbers into booleans, with 0 being false, true and false into
+// numbers, the null and empty scalars into zero numbers, and a scalar
+// into a sequence of one entry. Any scalar is always decoded into a
+// string, and a quoted number into a number. The checks of strict
+// decoders on the types of scalars are not made.
+func (d *Decoder) SetWeak(on bool) {
+	d.weak = on
+}
+
+// weakScalar returns the scalar str, decoded into a value of kind k,
+// converted to the text of its value for k.
+func weakScalar(k reflect.Kind, str string) string {
+	switch k {
+	case reflect.Bool:
+		switch strings.ToLower(str) {
+		case "yes", "y", "on":
+			return "true"
+		case "no", "n", "off":
+			return "false"
+		}
+		if f, err := parseFloat(str); err == nil {
+			return strconv.FormatBool(f != 0)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		switch {
+		case isNull(str):
+			return "0"
+		case str == "true":
+			return "1"
+		case str == "false":
+			return "0"
+		}
+	}
+	return str
+}
+
+// weakSlice decodes the scalar at the current position into val,
+// a slice whose entries have the plan elem, as a sequence of one
+// entry, and reports whether it did.
+func (d *Decoder) weakSlice(name string, val reflect.Value, elem *plan, indent, state int) (bool, error) {
+	if state != stateObjectValue || d.nodeKind(indent, state) != reflect.String ||
+		elem.class != planScalar && elem.class != planTime {
+		return false, nil
+	}
+	str, _, start, err := d.scalarText(name, "", indent, false)
+	if err != nil {
+		return false, err
+	}
+	if isNull(str) && !d.quoted {
+		d.off = start
+		return false, nil
+	}
+
+	e := reflect.New(elem.t).Elem()
+	d.pushIndex(0)
+	if elem.class == planTime {
+		err = d.time(name, e, str, start)
+	} else {
+		err = d.scalar(name, e, "", str, start)
+	}
+	d.pop()
+	if err != nil {
+		return false, err
+	}
+	val.Set(reflect.Append(reflect.MakeSlice(val.Type(), 0, 1), e))
+	return true, nil
+}
@@ -0,0 +1,322 @@
+/*
+Package yaml2go generates the Go types of the documents like some
+example documents, with the yaml tags of their fields:
+
+	name: app                 type Config struct {
+	port: 8080                    Name    string   `yaml:"name"`
+	servers:                      Port    int      `yaml:"port"`
+	  - host: a.example.com       Servers []Server `yaml:"servers"`
+	    tls: true             }
+
+	                          type Server struct {
+	                              Host string `yaml:"host"`
+	                              TLS  bool   `yaml:"tls"`
+	                          }
+
+The types of the values are merged across the examples: a field
+missing from some of the mappings gets the omitempty option, ints and
+floats are float64, and values of different kinds are interface{}.
+*/
+package yaml2go
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/J5ive/yaml"
+)
+
+// Options are the options of Generate.
+type Options struct {
+	// Package is the name of the package of the generated file.
+	// Without it, only the types are generated.
+	Package string
+
+	// Name is the name of the type of the documents, "Config" by default.
+	Name string
+}
+
+// Generate returns the Go source of the types of the documents like
+// the documents of docs. Each element of docs may hold several
+// documents.
+func Generate(opts Options, docs ...[]byte) ([]byte, error) {
+	root := &shape{}
+	for _, data := range docs {
+		d := yaml.NewDecoderBytes(data)
+		for {
+			var n yaml.Node
+			if err := d.Decode(&n); err != nil {
+				return nil, err
+			}
+			root.add(&n)
+			if !d.More() {
+				break
+			}
+		}
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = "Config"
+	}
+	g := &generator{names: make(map[string]bool)}
+	if root.kinds() == 1 && root.mapping && len(root.fields) != 0 {
+		g.typeOf(root, "", name)
+	} else {
+		g.names[name] = true
+		fmt.Fprintf(&g.body, "type %s %s\n\n", name, g.typeOf(root, name, name+"Item"))
+	}
+	for len(g.pending) != 0 {
+		t := g.pending[0]
+		g.pending = g.pending[1:]
+		g.structType(t.name, t.shape)
+	}
+
+	var src bytes.Buffer
+	if opts.Package != "" {
+		fmt.Fprintf(&src, "package %s\n\n", opts.Package)
+	}
+	if g.time {
+		src.WriteString("import \"time\"\n\n")
+	}
+	src.Write(bytes.TrimSuffix(g.body.Bytes(), []byte("\n")))
+	return format.Source(src.Bytes())
+}
+
+// scalar kinds
+const (
+	kindNull = 1 << iota
+	kindBool
+	kindInt
+	kindFloat
+	kindString
+	kindTime
+)
+
+// A shape is the merged shape of the nodes at a place of the documents.
+type shape struct {
+	scalars int // set of scalar kinds
+
+	mapping bool
+	objects int // number of mappings
+	fields  []*field
+	index   map[string]*field
+
+	sequence bool
+	elem     *shape // nil for empty sequences
+}
+
+// A field is a key of the mappings of a shape.
+type field struct {
+	key   string
+	count int // number of mappings having it
+	shape *shape
+}
+
+func (s *shape) add(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		s.scalars |= scalarKind(n)
+
+	case yaml.SequenceNode:
+		s.sequence = true
+		for _, c := range n.Content {
+			if s.elem == nil {
+				s.elem = &shape{}
+			}
+			s.elem.add(c)
+		}
+
+	case yaml.MappingNode:
+		s.mapping = true
+		s.objects++
+		if s.index == nil {
+			s.index = make(map[string]*field)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Kind != yaml.ScalarNode {
+				continue
+			}
+			f := s.index[k.Value]
+			if f == nil {
+				f = &field{key: k.Value, shape: &shape{}}
+				s.index[k.Value] = f
+				s.fields = append(s.fields, f)
+			}
+			f.count++
+			f.shape.add(v)
+		}
+	}
+}
+
+func scalarKind(n *yaml.Node) int {
+	switch n.Tag {
+	case "!!null":
+		return kindNull
+	case "!!bool":
+		return kindBool
+	case "!!int":
+		return kindInt
+	case "!!float":
+		return kindFloat
+	}
+	if _, err := time.Parse(time.RFC3339, n.Value); err == nil {
+		return kindTime
+	}
+	return kindString
+}
+
+// kinds returns the number of kinds of nodes of s,
+// among scalars, mappings and sequences.
+func (s *shape) kinds() int {
+	n := 0
+	if s.scalars&^kindNull != 0 {
+		n++
+	}
+	if s.mapping {
+		n++
+	}
+	if s.sequence {
+		n++
+	}
+	return n
+}
+
+type generator struct {
+	body    bytes.Buffer
+	names   map[string]bool // of the types generated
+	pending []namedShape    // structs left to generate
+	time    bool            // whether the time package is used
+}
+
+type namedShape struct {
+	name  string
+	shape *shape
+}
+
+// typeOf returns the type of s, the shape of the values of the field
+// name of the struct parent, or of the documents when name is the
+// name of their type.
+func (g *generator) typeOf(s *shape, parent, name string) string {
+	if s.kinds() > 1 {
+		return "interface{}"
+	}
+	switch {
+	case s.mapping:
+		if len(s.fields) == 0 {
+			return "map[string]interface{}"
+		}
+		name = g.typeName(parent, name)
+		g.pending = append(g.pending, namedShape{name, s})
+		return name
+	case s.sequence:
+		if s.elem == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.typeOf(s.elem, parent, singular(name))
+	}
+
+	switch s.scalars &^ kindNull {
+	case kindBool:
+		return "bool"
+	case kindInt:
+		return "int"
+	case kindFloat, kindInt | kindFloat:
+		return "float64"
+	case kindString, kindString | kindTime:
+		return "string"
+	case kindTime:
+		g.time = true
+		return "time.Time"
+	}
+	return "interface{}"
+}
+
+// typeName returns an unused name of type for name, a field of the
+// struct parent.
+func (g *generator) typeName(parent, name string) string {
+	if g.names[name] && parent != "" {
+		name = parent + name
+	}
+	for i := 2; g.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), i)
+	}
+	g.names[name] = true
+	return name
+}
+
+// structType writes the declaration of the struct name of shape s.
+func (g *generator) structType(name string, s *shape) {
+	type line struct{ name, typ, tag string }
+	var lines []line
+	used := make(map[string]bool)
+	for _, f := range s.fields {
+		fname := exported(f.key)
+		for i := 2; used[fname]; i++ {
+			fname = fmt.Sprintf("%s%d", exported(f.key), i)
+		}
+		used[fname] = true
+
+		tag := f.key
+		if f.count < s.objects {
+			tag += ",omitempty"
+		}
+		lines = append(lines, line{fname, g.typeOf(f.shape, name, fname), tag})
+	}
+
+	fmt.Fprintf(&g.body, "type %s struct {\n", name)
+	for _, l := range lines {
+		fmt.Fprintf(&g.body, "%s %s `yaml:%q`\n", l.name, l.typ, l.tag)
+	}
+	g.body.WriteString("}\n\n")
+}
+
+// initialisms are written in upper case in the names of fields.
+var initialisms = map[string]bool{
+	"API": true, "CPU": true, "DNS": true, "HTTP": true, "HTTPS": true,
+	"ID": true, "IP": true, "JSON": true, "SQL": true, "SSH": true,
+	"TCP": true, "TLS": true, "TTL": true, "UDP": true, "UI": true,
+	"URI": true, "URL": true, "UUID": true, "XML": true, "YAML": true,
+}
+
+// exported returns the exported Go name of the key,
+// like MaxIdleConns for max_idle_conns.
+func exported(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for _, w := range words {
+		if up := strings.ToUpper(w); initialisms[up] {
+			b.WriteString(up)
+			continue
+		}
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "F" + name
+	}
+	return name
+}
+
+// singular returns the singular of the plural name,
+// like Server for Servers.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "ses") || strings.HasSuffix(name, "xes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 3:
+		return name[:len(name)-1]
+	}
+	return name
+}
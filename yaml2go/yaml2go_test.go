@@ -0,0 +1,83 @@
+package yaml2go
+
+import (
+	"reflect"
+	"testing"
+)
+
+func assertEqual(t *testing.T, x, y interface{}) {
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("Assert fail! \nExpect: %v\nObtain: %v\n", x, y)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	a := []byte(`
+name: app
+port: 8080
+ratio: 1
+api_url: http://example.com
+created: 2024-01-02T03:04:05Z
+servers:
+  - host: a
+    tls: true
+  - host: b
+tags:
+  - web
+metadata: {}
+extra: ~
+`)
+	b := []byte(`
+name: other
+port: 80
+ratio: 0.5
+api_url: http://example.org
+created: 2024-02-03T04:05:06Z
+servers: []
+value: 1
+---
+name: third
+value: x
+`)
+	src, err := Generate(Options{Package: "config"}, a, b)
+	assertEqual(t, err, nil)
+	assertEqual(t, string(src), `package config
+
+import "time"
+
+type Config struct {
+	Name     string                 `+"`yaml:\"name\"`"+`
+	Port     int                    `+"`yaml:\"port,omitempty\"`"+`
+	Ratio    float64                `+"`yaml:\"ratio,omitempty\"`"+`
+	APIURL   string                 `+"`yaml:\"api_url,omitempty\"`"+`
+	Created  time.Time              `+"`yaml:\"created,omitempty\"`"+`
+	Servers  []Server               `+"`yaml:\"servers,omitempty\"`"+`
+	Tags     []string               `+"`yaml:\"tags,omitempty\"`"+`
+	Metadata map[string]interface{} `+"`yaml:\"metadata,omitempty\"`"+`
+	Extra    interface{}            `+"`yaml:\"extra,omitempty\"`"+`
+	Value    interface{}            `+"`yaml:\"value,omitempty\"`"+`
+}
+
+type Server struct {
+	Host string `+"`yaml:\"host\"`"+`
+	TLS  bool   `+"`yaml:\"tls,omitempty\"`"+`
+}
+`)
+
+	src, err = Generate(Options{Name: "Items"}, []byte("- id: 1\n  sub:\n    x: 1\n- id: 2\n"))
+	assertEqual(t, err, nil)
+	assertEqual(t, string(src), `type Items []ItemsItem
+
+type ItemsItem struct {
+	ID  int `+"`yaml:\"id\"`"+`
+	Sub Sub `+"`yaml:\"sub,omitempty\"`"+`
+}
+
+type Sub struct {
+	X int `+"`yaml:\"x\"`"+`
+}
+`)
+
+	_, err = Generate(Options{}, []byte("a:\n\tb: 1\n"))
+	assertEqual(t, err != nil, true)
+}
@@ -0,0 +1,416 @@
+/*
+
+Package yamlpath queries the nodes of a document decoded into a yaml.Node,
+with JSONPath-like expressions:
+
+	$.spec.containers[*].image   images of all the containers
+	spec.containers[0]           the first container ($ may be omitted)
+	..password                   every value of a password key
+	items[?(@.kind == 'web')]    the items whose kind is web
+	items[?(@.port > 1024)]      the items with a port above 1024
+	items[?(@.tls)]              the items having a tls key
+	['key with spaces']          a key which is not a plain name
+
+The matched nodes carry their position in the document.
+
+*/
+package yamlpath
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/J5ive/yaml"
+)
+
+// segment kinds
+const (
+	segChild = iota
+	segWildcard
+	segIndex
+	segFilter
+)
+
+type segment struct {
+	kind      int
+	recursive bool
+	name      string
+	index     int
+	filter    *filter
+}
+
+// A filter keeps the nodes for which the value at path,
+// compared to value with op, is true. Without op, the nodes
+// having a value at path are kept.
+type filter struct {
+	path  []segment
+	op    string
+	value string
+}
+
+// A Path is a compiled query expression.
+type Path struct {
+	expr string
+	segs []segment
+}
+
+// Compile parses a query expression.
+func Compile(expr string) (*Path, error) {
+	p := &parser{s: expr}
+	segs, err := p.path(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.i != len(p.s) {
+		return nil, p.error("unexpected " + string(p.s[p.i]))
+	}
+	return &Path{expr, segs}, nil
+}
+
+// MustCompile is like Compile but panics if the expression
+// can not be parsed.
+func MustCompile(expr string) *Path {
+	p, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Find returns the nodes under root matched by the path.
+// A recursive segment matches parents before their descendants.
+func (p *Path) Find(root *yaml.Node) []*yaml.Node {
+	return find([]*yaml.Node{root}, p.segs)
+}
+
+// Find compiles expr and returns the nodes under root matched by it.
+func Find(root *yaml.Node, expr string) ([]*yaml.Node, error) {
+	p, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(root), nil
+}
+
+func find(nodes []*yaml.Node, segs []segment) []*yaml.Node {
+	for _, seg := range segs {
+		var next []*yaml.Node
+		for _, n := range nodes {
+			if seg.recursive {
+				walk(n, func(n *yaml.Node) {
+					next = seg.apply(n, next)
+				})
+			} else {
+				next = seg.apply(n, next)
+			}
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// walk calls f for n and all its descendants, values of mappings
+// included but not their keys.
+func walk(n *yaml.Node, f func(*yaml.Node)) {
+	f(n)
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			walk(c, f)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			walk(n.Content[i], f)
+		}
+	}
+}
+
+// apply appends the children of n matched by s to matched.
+func (s *segment) apply(n *yaml.Node, matched []*yaml.Node) []*yaml.Node {
+	switch s.kind {
+	case segChild:
+		if v := child(n, s.name); v != nil {
+			matched = append(matched, v)
+		}
+
+	case segIndex:
+		if n.Kind == yaml.SequenceNode {
+			i := s.index
+			if i < 0 {
+				i += len(n.Content)
+			}
+			if 0 <= i && i < len(n.Content) {
+				matched = append(matched, n.Content[i])
+			}
+		}
+
+	case segWildcard, segFilter:
+		var children []*yaml.Node
+		switch n.Kind {
+		case yaml.SequenceNode:
+			children = n.Content
+		case yaml.MappingNode:
+			for i := 1; i < len(n.Content); i += 2 {
+				children = append(children, n.Content[i])
+			}
+		}
+		for _, c := range children {
+			if s.kind == segWildcard || s.filter.match(c) {
+				matched = append(matched, c)
+			}
+		}
+	}
+	return matched
+}
+
+// child returns the value of key in the mapping n.
+func child(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.Kind == yaml.ScalarNode && k.Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func (f *filter) match(n *yaml.Node) bool {
+	nodes := find([]*yaml.Node{n}, f.path)
+	if f.op == "" {
+		return len(nodes) != 0
+	}
+	for _, v := range nodes {
+		if v.Kind == yaml.ScalarNode && compare(v.Value, f.op, f.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compare compares x and y as numbers if both are numbers,
+// as strings otherwise.
+func compare(x, op, y string) bool {
+	c := 0
+	fx, errx := strconv.ParseFloat(x, 64)
+	fy, erry := strconv.ParseFloat(y, 64)
+	switch {
+	case errx == nil && erry == nil:
+		switch {
+		case fx < fy:
+			c = -1
+		case fx > fy:
+			c = 1
+		}
+	default:
+		c = strings.Compare(x, y)
+	}
+
+	switch op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func (p *parser) error(info string) error {
+	return errors.New("yamlpath: " + info + " at " + strconv.Itoa(p.i) + " in " + p.s)
+}
+
+// path parses segments up to the end of the expression,
+// or up to an operator or ')' in a filter.
+func (p *parser) path(inFilter bool) ([]segment, error) {
+	var segs []segment
+	root := "$"
+	if inFilter {
+		root = "@"
+	}
+	if strings.HasPrefix(p.s[p.i:], root) {
+		p.i++
+	} else if !inFilter && p.i < len(p.s) && p.s[p.i] != '.' && p.s[p.i] != '[' {
+		// A leading name without $.
+		segs = append(segs, segment{kind: segChild, name: p.name()})
+	}
+
+	for p.i < len(p.s) {
+		var seg segment
+		switch c := p.s[p.i]; {
+		case strings.HasPrefix(p.s[p.i:], ".."):
+			p.i += 2
+			seg.recursive = true
+			if p.i < len(p.s) && p.s[p.i] == '[' {
+				if err := p.bracket(&seg); err != nil {
+					return nil, err
+				}
+			} else if err := p.dotted(&seg); err != nil {
+				return nil, err
+			}
+
+		case c == '.':
+			p.i++
+			if err := p.dotted(&seg); err != nil {
+				return nil, err
+			}
+
+		case c == '[':
+			if err := p.bracket(&seg); err != nil {
+				return nil, err
+			}
+
+		case inFilter:
+			return segs, nil
+
+		default:
+			return nil, p.error("unexpected " + string(c))
+		}
+		if inFilter && (seg.kind == segWildcard || seg.kind == segFilter || seg.recursive) {
+			return nil, p.error("unsupported segment in filter")
+		}
+		segs = append(segs, seg)
+	}
+	return segs, nil
+}
+
+func (p *parser) dotted(seg *segment) error {
+	if p.i < len(p.s) && p.s[p.i] == '*' {
+		p.i++
+		seg.kind = segWildcard
+		return nil
+	}
+	seg.kind, seg.name = segChild, p.name()
+	if seg.name == "" {
+		return p.error("expect name")
+	}
+	return nil
+}
+
+func (p *parser) name() string {
+	start := p.i
+	for p.i < len(p.s) && !strings.ContainsRune(".[]()=!<> ", rune(p.s[p.i])) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+func (p *parser) bracket(seg *segment) error {
+	p.i++ // [
+	switch {
+	case strings.HasPrefix(p.s[p.i:], "*"):
+		p.i++
+		seg.kind = segWildcard
+
+	case strings.HasPrefix(p.s[p.i:], "?("):
+		p.i += 2
+		f, err := p.filter()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(p.s[p.i:], ")") {
+			return p.error("expect )")
+		}
+		p.i++
+		seg.kind, seg.filter = segFilter, f
+
+	case strings.HasPrefix(p.s[p.i:], "'") || strings.HasPrefix(p.s[p.i:], "\""):
+		name, err := p.quoted()
+		if err != nil {
+			return err
+		}
+		seg.kind, seg.name = segChild, name
+
+	default:
+		start := p.i
+		for p.i < len(p.s) && p.s[p.i] != ']' {
+			p.i++
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(p.s[start:p.i]))
+		if err != nil {
+			p.i = start
+			return p.error("expect index")
+		}
+		seg.kind, seg.index = segIndex, n
+	}
+
+	if !strings.HasPrefix(p.s[p.i:], "]") {
+		return p.error("expect ]")
+	}
+	p.i++
+	return nil
+}
+
+func (p *parser) filter() (*filter, error) {
+	p.space()
+	if !strings.HasPrefix(p.s[p.i:], "@") {
+		return nil, p.error("expect @")
+	}
+	path, err := p.path(true)
+	if err != nil {
+		return nil, err
+	}
+	f := &filter{path: path}
+
+	p.space()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if strings.HasPrefix(p.s[p.i:], op) {
+			p.i += len(op)
+			f.op = op
+			break
+		}
+	}
+	if f.op == "" {
+		return f, nil
+	}
+
+	p.space()
+	if strings.HasPrefix(p.s[p.i:], "'") || strings.HasPrefix(p.s[p.i:], "\"") {
+		if f.value, err = p.quoted(); err != nil {
+			return nil, err
+		}
+	} else {
+		start := p.i
+		for p.i < len(p.s) && p.s[p.i] != ')' && p.s[p.i] != ' ' {
+			p.i++
+		}
+		f.value = p.s[start:p.i]
+	}
+	p.space()
+	return f, nil
+}
+
+func (p *parser) quoted() (string, error) {
+	q := p.s[p.i]
+	end := strings.IndexByte(p.s[p.i+1:], q)
+	if end == -1 {
+		return "", p.error("unterminated string")
+	}
+	s := p.s[p.i+1 : p.i+1+end]
+	p.i += end + 2
+	return s, nil
+}
+
+func (p *parser) space() {
+	for p.i < len(p.s) && p.s[p.i] == ' ' {
+		p.i++
+	}
+}
@@ -0,0 +1,59 @@
+package yamlpath
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/J5ive/yaml"
+)
+
+func assertEqual(t *testing.T, x, y interface{}) {
+	if !reflect.DeepEqual(x, y) {
+		t.Errorf("Assert fail! \nExpect: %v\nObtain: %v\n", x, y)
+	}
+}
+
+func TestFind(t *testing.T) {
+	data := []byte(`
+spec:
+  containers:
+  - name: web
+    image: nginx
+    port: 80
+  - name: db
+    image: postgres
+    port: 5432
+    env:
+      password: secret
+password: top
+`)
+
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	assertEqual(t, err, nil)
+
+	values := func(expr string) []string {
+		nodes, err := Find(&root, expr)
+		assertEqual(t, err, nil)
+		var s []string
+		for _, n := range nodes {
+			s = append(s, n.Value)
+		}
+		return s
+	}
+
+	assertEqual(t, values("spec.containers[*].image"), []string{"nginx", "postgres"})
+	assertEqual(t, values("$.spec.containers[-1].name"), []string{"db"})
+	assertEqual(t, values("..password"), []string{"top", "secret"})
+	assertEqual(t, values("spec.containers[?(@.name == 'web')].port"), []string{"80"})
+	assertEqual(t, values("spec.containers[?(@.port > 1024)].name"), []string{"db"})
+	assertEqual(t, values("spec.containers[?(@.env)].name"), []string{"db"})
+	assertEqual(t, values("spec['containers'][0].name"), []string{"web"})
+
+	nodes, _ := Find(&root, "..password")
+	assertEqual(t, nodes[0].Line, 12)
+	assertEqual(t, nodes[0].Column, 11)
+
+	_, err = Compile("spec[")
+	assertEqual(t, err != nil, true)
+}
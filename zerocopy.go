@@ -0,0 +1,19 @@
+package yaml
+
+import "unsafe"
+
+// SetZeroCopy makes the decoded strings share the memory of the data
+// rather than be copies, which saves an allocation per scalar of the
+// large documents. The data must then not be modified after Decode,
+// as the strings would change too.
+func (d *Decoder) SetZeroCopy(on bool) {
+	d.zeroCopy = on
+}
+
+// str returns b as a string, sharing its memory with zero copy.
+func (d *Decoder) str(b []byte) string {
+	if d.zeroCopy && len(b) > 0 {
+		return unsafe.String(&b[0], len(b))
+	}
+	return string(b)
+}